@@ -0,0 +1,34 @@
+// books-dedupe backfills catalog keys on books and merges duplicate rows,
+// re-pointing posts at the surviving row. Safe to re-run.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		log.Fatal("DB_DSN required")
+	}
+	ctx := context.Background()
+	store, err := db.Open(ctx, dsn)
+	if err != nil {
+		log.Fatalf("db open: %v", err)
+	}
+	defer store.Close()
+
+	n, err := store.MergeDuplicateBooks(ctx)
+	if err != nil {
+		log.Fatalf("merge books: %v", err)
+	}
+	log.Printf("books-dedupe: merged %d duplicate rows", n)
+}
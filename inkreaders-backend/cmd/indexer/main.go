@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -25,7 +28,8 @@ func main() {
 	// 🔑 Login with app account
 	agent, did := bootstrap.NewAppAgent(cfg)
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	store, err := db.Open(ctx, getenv("DB_DSN"))
 	if err != nil {
 		log.Fatalf("db open: %v", err)
@@ -36,6 +40,34 @@ func main() {
 
 	log.Printf("Logged in as DID=%s Handle=%s", did, cfg.Handle)
 	log.Printf("DB_DSN=%s", os.Getenv("DB_DSN"))
+	// Edits and deletes in the app account's repo are caught by a periodic
+	// full diff against listRecords, whatever the mode.
+	go reconcileLoop(ctx, ix, 15*time.Minute)
+
+	// Scores decay with time, so the trending rollup is refreshed on a clock
+	// rather than on writes.
+	go refreshTrendingLoop(ctx, store, 5*time.Minute)
+
+	// INDEXER_MODE: poll (default) reads only the app account's repo;
+	// jetstream and firehose stream com.inkreaders.* records from every DID.
+	switch mode := os.Getenv("INDEXER_MODE"); mode {
+	case "jetstream":
+		log.Println("indexer: jetstream mode")
+		if err := ix.RunJetstream(ctx, os.Getenv("JETSTREAM_URL")); err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("jetstream: %v", err)
+		}
+		return
+	case "firehose":
+		log.Println("indexer: firehose mode")
+		if err := ix.RunFirehose(ctx, os.Getenv("FIREHOSE_HOST")); err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("firehose: %v", err)
+		}
+		return
+	case "", "poll":
+	default:
+		log.Fatalf("unknown INDEXER_MODE %q (poll|jetstream|firehose)", mode)
+	}
+
 	log.Println("mini indexer: polling every 20s (books & articles)")
 
 	ticker := time.NewTicker(20 * time.Second)
@@ -49,7 +81,13 @@ func main() {
 		log.Println("poll article:", err)
 	}
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("mini indexer: shutting down")
+			return
+		case <-ticker.C:
+		}
 		if err := ix.PollBookPosts(ctx, 50); err != nil {
 			log.Println("poll book:", err)
 		}
@@ -59,6 +97,38 @@ func main() {
 	}
 }
 
+func reconcileLoop(ctx context.Context, ix *indexer.Indexer, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		for _, c := range []string{indexer.CollectionBookPost, indexer.CollectionArticlePost} {
+			if err := ix.Reconcile(ctx, c); err != nil {
+				log.Println("reconcile:", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func refreshTrendingLoop(ctx context.Context, store *db.Store, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if err := store.RefreshTrendingBooks(ctx); err != nil {
+			log.Println("refresh trending:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func getenv(k string) string {
 	if v := os.Getenv(k); v != "" {
 		return v
@@ -0,0 +1,36 @@
+// render-responses re-renders content_html from the Markdown in content
+// with the sanitizing renderer, for responses and their saved versions.
+// Safe to re-run; only rows whose HTML changes are written.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/render"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		log.Fatal("DB_DSN required")
+	}
+	ctx := context.Background()
+	store, err := db.Open(ctx, dsn)
+	if err != nil {
+		log.Fatalf("db open: %v", err)
+	}
+	defer store.Close()
+
+	n, err := store.RerenderResponses(ctx, render.Markdown, 500)
+	if err != nil {
+		log.Fatalf("render responses: %v (rewrote %d before failing)", err, n)
+	}
+	log.Printf("render-responses: rewrote %d rows", n)
+}
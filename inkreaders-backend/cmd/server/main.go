@@ -2,15 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/config"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/enrich"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/extract"
 	httph "github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/http"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/jobs"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/live"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/atproto"
 )
 
@@ -30,7 +40,10 @@ func main() {
 	log.Printf("App account AccessJwt: %s", agent.Auth.AccessJwt)
 
 	// --- Database ---
-	ctx := context.Background()
+	// ctx is cancelled on SIGINT/SIGTERM, which stops the background loops
+	// and the job worker and shuts the HTTP server down.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	dsn := os.Getenv("DB_DSN")
 	if dsn == "" {
 		log.Fatal("DB_DSN required")
@@ -41,34 +54,166 @@ func main() {
 	}
 	defer store.Close()
 
-	// --- AI Client (switchable via env) ---
+	// --- AI Client (switchable via env; see ai.ProviderConfigFromEnv) ---
 	var aiClient ai.Client
+	var llmCfg ai.ProviderConfig
 	if os.Getenv("AI_STUB") == "true" {
 		log.Println("[AI] Using Stub generator")
 		aiClient = ai.NewStub()
 	} else {
-		model := os.Getenv("OPENAI_MODEL")
-		if model == "" {
-			model = "gpt-4o-mini" // safe default
+		llmCfg, err = ai.ProviderConfigFromEnv()
+		if err != nil {
+			log.Fatalf("llm config: %v (or set AI_STUB=true)", err)
 		}
-		apiKey := os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" {
-			log.Fatal("OPENAI_API_KEY required (or set AI_STUB=true)")
+		log.Printf("[AI] Using provider=%s base=%s model=%s", llmCfg.Kind, llmCfg.BaseURL, llmCfg.Models.Default)
+		llm := ai.NewLLMClient(ai.NewProvider(llmCfg), llmCfg.Models)
+		llm.Debug = os.Getenv("AI_DEBUG") == "true"
+		if llm.Prices, err = ai.PricesFromEnv(); err != nil {
+			log.Fatalf("llm prices: %v", err)
 		}
-		log.Printf("[AI] Using OpenAI model=%s", model)
-		aiClient = ai.NewOpenAI(model, apiKey)
+		// Meter every model call; quotas in the HTTP layer sum these rows.
+		llm.OnUsage = func(ctx context.Context, rec ai.UsageRecord) {
+			u := db.AIUsage{
+				Op:               rec.Op,
+				Model:            rec.Model,
+				PromptTokens:     rec.Usage.PromptTokens,
+				CompletionTokens: rec.Usage.CompletionTokens,
+				CostUSD:          rec.CostUSD,
+			}
+			if rec.UserID != uuid.Nil {
+				u.UserID = &rec.UserID
+			}
+			if err := store.RecordAIUsage(context.WithoutCancel(ctx), u); err != nil {
+				log.Printf("[AI] record usage op=%s: %v", rec.Op, err)
+			}
+		}
+		aiClient = llm
+	}
+
+	// --- AI result cache: repeat generate/remix/explain calls are served
+	// from Postgres for AI_CACHE_TTL (default 7 days; 0 disables) ---
+	cacheTTL := 7 * 24 * time.Hour
+	if v := os.Getenv("AI_CACHE_TTL"); v != "" {
+		if cacheTTL, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("AI_CACHE_TTL: %v", err)
+		}
+	}
+	if cacheTTL > 0 {
+		aiClient = ai.NewCached(aiClient, store, cacheTTL)
+		go purgeAICacheLoop(ctx, store, time.Hour)
+	}
+
+	// --- Embeddings: OpenAI's text-embedding-3-small against api.openai.com.
+	// Other servers only get an embeddings model if EMBED_MODEL names one,
+	// and it must return ai.EmbeddingDims-dim vectors to fit the columns;
+	// otherwise, in stub mode, or when asked for, vectors are local hashes ---
+	model := os.Getenv("EMBED_MODEL")
+	if model == "" {
+		model = os.Getenv("OPENAI_EMBED_MODEL")
+	}
+	hostedOpenAI := llmCfg.Kind == ai.ProviderOpenAI && strings.Contains(llmCfg.BaseURL, "api.openai.com")
+	if model == "" && hostedOpenAI {
+		model = "text-embedding-3-small"
+	}
+	var embedder ai.Embedder
+	if os.Getenv("AI_STUB") == "true" || os.Getenv("EMBEDDER") == "hash" || llmCfg.Kind == ai.ProviderAnthropic || model == "" {
+		log.Println("[AI] Using local hash embedder")
+		embedder = ai.NewHashEmbedder()
+	} else {
+		emb := ai.NewOpenAIEmbedder(model, llmCfg.APIKey)
+		emb.BaseURL, emb.Headers = llmCfg.BaseURL, llmCfg.Headers
+		log.Printf("[AI] Using embeddings model=%s base=%s", model, emb.BaseURL)
+		embedder = emb
 	}
 
 	// --- Other deps ---
 	pub := httph.NewAtprotoPublisher(agent, did)           // real publisher
 	storage := httph.NewLocalStorage("./data/uploads")     // local disk storage
-	extract := httph.NewExtractor()                        // stub extractor for PDF/text
+	extractor := extract.New()                             // PDF/DOCX/EPUB/HTML/Markdown text
+
+	// --- Background jobs ---
+	worker := jobs.NewWorker(store)
+	hub := live.NewHub() // streams in-progress responses to SSE clients
+	worker.Register(jobs.TypeTopicResponse, jobs.TopicResponse(store, aiClient, hub))
+	worker.Register(jobs.TypeResponseEmbed, jobs.ResponseEmbed(store, embedder))
+
+	// Sweep for responses that missed their embed job, predate it, or were
+	// embedded by a different model.
+	go enqueueLoop(ctx, store, "embed", 15*time.Minute, jobs.TypeResponseEmbed, jobs.ResponseEmbedPayload{})
+
+	// Book metadata enrichment: an offline Open Library dump if one is
+	// configured, otherwise the Open Library API when enabled.
+	var enrichSrc enrich.Source
+	if path := os.Getenv("OPENLIBRARY_DUMP"); path != "" {
+		enrichSrc = enrich.NewDumpSource(path)
+	} else if os.Getenv("OPENLIBRARY_API") == "true" {
+		enrichSrc = enrich.NewHTTPSource(os.Getenv("OPENLIBRARY_URL"))
+	}
+	if enrichSrc != nil {
+		log.Printf("[enrich] using %s", enrichSrc.Name())
+		worker.Register(jobs.TypeBookEnrich, jobs.BookEnrich(store, enrichSrc))
+		go enqueueLoop(ctx, store, "enrich", time.Hour, jobs.TypeBookEnrich, jobs.BookEnrichPayload{})
+	}
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		worker.Run(ctx)
+	}()
 
 	// --- Router ---
-	r := httph.NewRouter(agent, did, store, aiClient, pub, storage, extract)
+	r := httph.NewRouter(agent, did, store, aiClient, embedder, hub, pub, storage, extractor)
 
 	log.Printf("Logged in as DID=%s Handle=%s", did, cfg.Handle)
 	log.Printf("DB_DSN=%s", os.Getenv("DB_DSN"))
 	log.Printf("server listening on :%s", cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown: %v", err)
+		}
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-workerDone
+	log.Println("server stopped")
+}
+
+// enqueueLoop enqueues a sweep job now and then every interval until ctx is
+// done, skipping a tick while the previous sweep is still queued or running.
+func enqueueLoop(ctx context.Context, store *db.Store, name string, every time.Duration, jobType string, payload any) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if _, _, err := store.EnqueueJobIfIdle(ctx, jobType, payload, 3); err != nil && ctx.Err() == nil {
+			log.Printf("[%s] enqueue: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeAICacheLoop deletes expired AI cache entries now and then every
+// interval until ctx is done.
+func purgeAICacheLoop(ctx context.Context, store *db.Store, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if n, err := store.PurgeAICache(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[AI cache] purge: %v", err)
+		} else if n > 0 {
+			log.Printf("[AI cache] purged %d expired entries", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// CacheStore keeps cached results by key until they expire. *db.Store
+// implements it on the ai_cache table.
+type CacheStore interface {
+	GetAICache(ctx context.Context, key string) ([]byte, bool, error)
+	PutAICache(ctx context.Context, key, op string, value []byte, ttl time.Duration) error
+}
+
+// Fingerprinter is implemented by clients whose output for an op depends on
+// more than its input, e.g. the model and system prompt. The fingerprint is
+// part of the cache key, so changing either misses the old entries.
+type Fingerprinter interface {
+	CacheFingerprint(op string) string
+}
+
+type freshKey struct{}
+
+// WithFresh makes Cached skip lookups for calls made with ctx. Results
+// are still stored.
+func WithFresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+func isFresh(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshKey{}).(bool)
+	return fresh
+}
+
+// Cached wraps a Client and serves repeat Generate, Remix and Explain calls
+// from a CacheStore. Notebook responses are conversational and streamed, so
+// they always go to Next.
+type Cached struct {
+	Next  Client
+	Store CacheStore
+	TTL   time.Duration
+}
+
+func NewCached(next Client, store CacheStore, ttl time.Duration) *Cached {
+	return &Cached{Next: next, Store: store, TTL: ttl}
+}
+
+// cacheKey is the hex SHA-256 of the op, the client's fingerprint and the
+// JSON of the input.
+func (c *Cached) cacheKey(op string, input any) string {
+	fp := fmt.Sprintf("%T", c.Next)
+	if f, ok := c.Next.(Fingerprinter); ok {
+		fp = f.CacheFingerprint(op)
+	}
+	in, _ := json.Marshal(input)
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(op), []byte(fp), in} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cached returns the stored result for key, or runs call and stores what
+// it returns. Cache failures are logged and never fail the call.
+func cached[T any](ctx context.Context, c *Cached, op, key string, call func() (T, error)) (T, error) {
+	if !isFresh(ctx) {
+		b, ok, err := c.Store.GetAICache(ctx, key)
+		if err != nil {
+			log.Printf("[AI cache] get op=%s: %v", op, err)
+		} else if ok {
+			var out T
+			if err := json.Unmarshal(b, &out); err == nil {
+				return out, nil
+			}
+			log.Printf("[AI cache] bad entry op=%s key=%s: %v", op, key, err)
+		}
+	}
+	out, err := call()
+	if err != nil {
+		return out, err
+	}
+	if b, err := json.Marshal(out); err == nil {
+		if err := c.Store.PutAICache(ctx, key, op, b, c.TTL); err != nil {
+			log.Printf("[AI cache] put op=%s: %v", op, err)
+		}
+	}
+	return out, nil
+}
+
+func (c *Cached) Generate(ctx context.Context, p GenerateParams) (GenerateOut, error) {
+	return cached(ctx, c, OpGenerate, c.cacheKey(OpGenerate, p), func() (GenerateOut, error) {
+		return c.Next.Generate(ctx, p)
+	})
+}
+
+func (c *Cached) Remix(ctx context.Context, p RemixParams) (db.ExerciseSet, error) {
+	return cached(ctx, c, OpRemix, c.cacheKey(OpRemix, p), func() (db.ExerciseSet, error) {
+		return c.Next.Remix(ctx, p)
+	})
+}
+
+func (c *Cached) Explain(ctx context.Context, questionID string, prompt string, answer any) (string, error) {
+	key := c.cacheKey(OpExplain, []any{questionID, prompt, answer})
+	return cached(ctx, c, OpExplain, key, func() (string, error) {
+		return c.Next.Explain(ctx, questionID, prompt, answer)
+	})
+}
+
+func (c *Cached) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return c.Next.GenerateResponse(ctx, prompt)
+}
+
+func (c *Cached) GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	return c.Next.GenerateResponseStream(ctx, prompt, onDelta)
+}
+
+func (c *Cached) GenerateFollowUp(ctx context.Context, history []Turn, prompt string, onDelta func(string)) (string, error) {
+	return c.Next.GenerateFollowUp(ctx, history, prompt, onDelta)
+}
@@ -0,0 +1,165 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memCache is a CacheStore in a map that counts lookups and writes.
+type memCache struct {
+	entries    map[string][]byte
+	gets, puts int
+}
+
+func newMemCache() *memCache { return &memCache{entries: map[string][]byte{}} }
+
+func (m *memCache) GetAICache(ctx context.Context, key string) ([]byte, bool, error) {
+	m.gets++
+	b, ok := m.entries[key]
+	return b, ok, nil
+}
+
+func (m *memCache) PutAICache(ctx context.Context, key, op string, value []byte, ttl time.Duration) error {
+	m.puts++
+	m.entries[key] = value
+	return nil
+}
+
+// countingClient answers Explain with a numbered reply so a cached answer
+// can be told from a fresh one. Other methods are not used.
+type countingClient struct {
+	Client
+	calls int
+}
+
+func (c *countingClient) Explain(ctx context.Context, questionID string, prompt string, answer any) (string, error) {
+	c.calls++
+	return fmt.Sprintf("explanation %d", c.calls), nil
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	next := &countingClient{}
+	store := newMemCache()
+	c := NewCached(next, store, time.Hour)
+
+	// Miss: the call goes through and is stored.
+	got, err := c.Explain(ctx, "q1", "2+2?", 4)
+	if err != nil || got != "explanation 1" {
+		t.Fatalf("first call = %q, %v", got, err)
+	}
+	if next.calls != 1 || store.puts != 1 {
+		t.Fatalf("miss: calls=%d puts=%d, want 1 and 1", next.calls, store.puts)
+	}
+
+	// Hit: served from the store.
+	if got, _ := c.Explain(ctx, "q1", "2+2?", 4); got != "explanation 1" {
+		t.Errorf("repeat call = %q, want the cached reply", got)
+	}
+	if next.calls != 1 {
+		t.Errorf("hit reached the client")
+	}
+
+	// Another input misses.
+	if got, _ := c.Explain(ctx, "q1", "2+2?", 5); got != "explanation 2" {
+		t.Errorf("different answer = %q, want a fresh reply", got)
+	}
+
+	// WithFresh skips the lookup but stores the new result.
+	gets := store.gets
+	if got, _ := c.Explain(WithFresh(ctx), "q1", "2+2?", 4); got != "explanation 3" {
+		t.Errorf("fresh call = %q, want a new reply", got)
+	}
+	if store.gets != gets {
+		t.Error("fresh call looked up the cache")
+	}
+	if got, _ := c.Explain(ctx, "q1", "2+2?", 4); got != "explanation 3" {
+		t.Errorf("after fresh call = %q, want the refreshed reply", got)
+	}
+}
+
+func TestCachedCorruptEntry(t *testing.T) {
+	ctx := context.Background()
+	next := &countingClient{}
+	store := newMemCache()
+	c := NewCached(next, store, time.Hour)
+
+	key := c.cacheKey(OpExplain, []any{"q1", "2+2?", 4})
+	store.entries[key] = []byte("{not json")
+	got, err := c.Explain(ctx, "q1", "2+2?", 4)
+	if err != nil || got != "explanation 1" {
+		t.Fatalf("corrupt entry: got %q, %v; want a fresh reply", got, err)
+	}
+	if string(store.entries[key]) != `"explanation 1"` {
+		t.Errorf("corrupt entry not replaced: %s", store.entries[key])
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	llm := NewLLMClient(nil, Models{Default: "model-a"})
+	c := NewCached(llm, newMemCache(), time.Hour)
+	in := []any{"q1", "2+2?", 4}
+	base := c.cacheKey(OpExplain, in)
+
+	if c.cacheKey(OpExplain, []any{"q1", "2+2?", 4}) != base {
+		t.Error("same op and input gave a different key")
+	}
+	if c.cacheKey(OpGenerate, in) == base {
+		t.Error("op does not change the key")
+	}
+	if c.cacheKey(OpExplain, []any{"q1", "2+2?", 5}) == base {
+		t.Error("input does not change the key")
+	}
+
+	llm.Models = Models{Default: "model-a", PerOp: map[string]string{OpExplain: "model-b"}}
+	if c.cacheKey(OpExplain, in) == base {
+		t.Error("model does not change the key")
+	}
+	llm.Models = Models{Default: "model-a"}
+
+	saved := explainSysPrompt
+	explainSysPrompt = saved + " Answer in French."
+	changed := c.cacheKey(OpExplain, in)
+	explainSysPrompt = saved
+	if changed == base {
+		t.Error("system prompt does not change the key")
+	}
+	if c.cacheKey(OpExplain, in) != base {
+		t.Error("key did not come back with the original prompt")
+	}
+}
+
+// countingProvider replies to every Chat with a fixed text.
+type countingProvider struct{ calls int }
+
+func (p *countingProvider) Chat(ctx context.Context, req ChatRequest) (string, Usage, error) {
+	p.calls++
+	return "Because.", Usage{PromptTokens: 10, CompletionTokens: 2}, nil
+}
+
+func (p *countingProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (string, Usage, error) {
+	return p.Chat(ctx, req)
+}
+
+func TestCachedHitSkipsGate(t *testing.T) {
+	provider := &countingProvider{}
+	c := NewCached(NewLLMClient(provider, Models{Default: "m"}), newMemCache(), time.Hour)
+	errQuota := errors.New("over quota")
+	gated := WithGate(context.Background(), func(context.Context) error { return errQuota })
+
+	if _, err := c.Explain(context.Background(), "q1", "2+2?", 4); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Explain(gated, "q1", "2+2?", 4); err != nil || got != "Because." {
+		t.Errorf("cached call over quota = %q, %v; want the cached reply", got, err)
+	}
+	if _, err := c.Explain(gated, "q2", "3+3?", 6); !errors.Is(err, errQuota) {
+		t.Errorf("uncached call over quota: err = %v, want the gate's error", err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.calls)
+	}
+}
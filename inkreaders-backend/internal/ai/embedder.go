@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// EmbeddingDims matches the vector(1536) columns on topics and responses.
+const EmbeddingDims = 1536
+
+// Embedder turns text into fixed-size vectors for semantic search.
+type Embedder interface {
+	// Embed returns one vector per input, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model names the embedding model; it is stored with each vector so
+	// rows embedded by a different model can be found and redone.
+	Model() string
+}
+
+// ---------------- OpenAI ----------------
+
+// OpenAIEmbedder calls /embeddings on OpenAI or a compatible server.
+type OpenAIEmbedder struct {
+	ModelName string
+	BaseURL   string
+	APIKey    string
+	Headers   map[string]string
+	HTTP      *http.Client
+}
+
+func NewOpenAIEmbedder(model, apiKey string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		ModelName: model,
+		BaseURL:   defaultBaseURLs[ProviderOpenAI],
+		APIKey:    apiKey,
+		HTTP:      http.DefaultClient,
+	}
+}
+
+func (e *OpenAIEmbedder) Model() string { return e.ModelName }
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body := map[string]any{
+		"model":      e.ModelName,
+		"input":      texts,
+		"dimensions": EmbeddingDims,
+	}
+	b, _ := json.Marshal(body)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", e.BaseURL+"/embeddings", bytes.NewReader(b))
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, e.Headers)
+
+	res, err := e.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		all, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("openai error %d: %s", res.StatusCode, string(all))
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("openai returned %d embeddings for %d inputs", len(out.Data), len(texts))
+	}
+	vecs := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vecs) {
+			return nil, errors.New("openai embedding index out of range")
+		}
+		if len(d.Embedding) != EmbeddingDims {
+			return nil, fmt.Errorf("openai embedding has %d dims, want %d", len(d.Embedding), EmbeddingDims)
+		}
+		vecs[d.Index] = d.Embedding
+	}
+	return vecs, nil
+}
+
+// ---------------- Local hashing ----------------
+
+// HashEmbedder is a deterministic bag-of-words embedder: words and word
+// bigrams are hashed into signed buckets and the vector is L2-normalised.
+// It needs no network, so it backs AI_STUB mode and tests; similarity is
+// lexical rather than semantic.
+type HashEmbedder struct{}
+
+func NewHashEmbedder() HashEmbedder { return HashEmbedder{} }
+
+func (HashEmbedder) Model() string { return "local-hash-v1" }
+
+func (HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = hashEmbed(t)
+	}
+	return out, nil
+}
+
+func hashEmbed(text string) []float32 {
+	vec := make([]float32, EmbeddingDims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	add := func(tok string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(tok))
+		sum := h.Sum64()
+		idx := sum % EmbeddingDims
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vec[idx] += weight
+	}
+	for i, w := range words {
+		add(w, 1)
+		if i > 0 {
+			add(words[i-1]+" "+w, 0.5)
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		inv := float32(1 / math.Sqrt(norm))
+		for i := range vec {
+			vec[i] *= inv
+		}
+	}
+	return vec
+}
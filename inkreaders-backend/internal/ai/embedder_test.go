@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func TestHashEmbedder(t *testing.T) {
+	texts := []string{
+		"The whale hunt in Moby Dick",
+		"the WHALE hunt, in Moby-Dick!",
+		"Moby Dick and the whale",
+		"Quarterly tax filing deadlines",
+		"",
+	}
+	e := NewHashEmbedder()
+	vecs, err := e.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != len(texts) {
+		t.Fatalf("got %d vectors for %d texts", len(vecs), len(texts))
+	}
+	for i, v := range vecs {
+		if len(v) != EmbeddingDims {
+			t.Fatalf("vector %d has %d dims, want %d", i, len(v), EmbeddingDims)
+		}
+	}
+
+	for i, v := range vecs[:4] {
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		if math.Abs(norm-1) > 1e-5 {
+			t.Errorf("vector %d has norm² %v, want 1", i, norm)
+		}
+	}
+	for _, x := range vecs[4] {
+		if x != 0 {
+			t.Fatal("empty text should embed to the zero vector")
+		}
+	}
+
+	// Case and punctuation are ignored.
+	if sim := cosine(vecs[0], vecs[1]); sim < 0.999 {
+		t.Errorf("same words differently cased: similarity %v, want 1", sim)
+	}
+	related, unrelated := cosine(vecs[0], vecs[2]), cosine(vecs[0], vecs[3])
+	if related <= unrelated {
+		t.Errorf("related %v should beat unrelated %v", related, unrelated)
+	}
+
+	again, _ := e.Embed(context.Background(), texts[:1])
+	for i := range again[0] {
+		if again[0][i] != vecs[0][i] {
+			t.Fatal("embedding is not deterministic")
+		}
+	}
+	if e.Model() == "" {
+		t.Error("Model() is empty; vectors could not be told apart from another model's")
+	}
+}
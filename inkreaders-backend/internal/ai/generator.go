@@ -19,6 +19,9 @@ type GenerateOut struct {
 	InferredTitle  string
 	InferredFormat string
 	Questions      []db.Question
+	// Errors lists questions dropped because they stayed invalid after
+	// repair. Questions holds the rest.
+	Errors []QuestionError
 }
 
 type Client interface {
@@ -26,6 +29,12 @@ type Client interface {
 	Remix(ctx context.Context, p RemixParams) (db.ExerciseSet, error)
 	Explain(ctx context.Context, questionID string, prompt string, answer any) (string, error)
 	GenerateResponse(ctx context.Context, prompt string) (string, error)
+	// GenerateResponseStream is GenerateResponse with onDelta called for
+	// each chunk of text as it arrives. It returns the full text.
+	GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error)
+	// GenerateFollowUp answers prompt in the context of a thread; history
+	// is the earlier turns, oldest first. Streams like GenerateResponseStream.
+	GenerateFollowUp(ctx context.Context, history []Turn, prompt string, onDelta func(string)) (string, error)
 }
 
 type RemixParams struct {
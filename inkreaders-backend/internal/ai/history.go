@@ -0,0 +1,77 @@
+package ai
+
+import "unicode/utf8"
+
+// Turn is one message of a notebook thread sent as chat history.
+type Turn struct {
+	Role    string // RoleUser or RoleAssistant
+	Content string
+}
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// historyMaxChars bounds the history sent with a follow-up (~4k tokens).
+const historyMaxChars = 16000
+
+// TrimHistory keeps the most recent turns whose combined length fits in
+// maxChars. The root turn (the topic's original question) is kept when
+// anything is dropped, since follow-ups usually refer back to it, but cut
+// to half the budget so a long root can't crowd out the recent turns. If
+// even the latest turn doesn't fit, its end is kept.
+func TrimHistory(history []Turn, maxChars int) []Turn {
+	total := 0
+	for _, t := range history {
+		total += len(t.Content)
+	}
+	if total <= maxChars || len(history) == 0 {
+		return history
+	}
+	if len(history) == 1 {
+		return []Turn{{Role: history[0].Role, Content: keepHead(history[0].Content, maxChars)}}
+	}
+
+	root := history[0]
+	root.Content = keepHead(root.Content, maxChars/2)
+	budget := maxChars - len(root.Content)
+	start := len(history)
+	for start > 1 && budget-len(history[start-1].Content) >= 0 {
+		start--
+		budget -= len(history[start].Content)
+	}
+	if start == len(history) {
+		last := history[start-1]
+		last.Content = keepTail(last.Content, budget)
+		return []Turn{root, last}
+	}
+	out := make([]Turn, 0, len(history)-start+1)
+	out = append(out, root)
+	return append(out, history[start:]...)
+}
+
+// keepHead returns at most n bytes from the start of s, not splitting a
+// UTF-8 sequence.
+func keepHead(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// keepTail returns at most n bytes from the end of s, not splitting a
+// UTF-8 sequence.
+func keepTail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	i := len(s) - n
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return s[i:]
+}
@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func turns(lens ...int) []Turn {
+	out := make([]Turn, len(lens))
+	for i, n := range lens {
+		role := RoleUser
+		if i%2 == 1 {
+			role = RoleAssistant
+		}
+		out[i] = Turn{Role: role, Content: strings.Repeat(string(rune('a'+i)), n)}
+	}
+	return out
+}
+
+func lens(ts []Turn) []int {
+	out := make([]int, len(ts))
+	for i, t := range ts {
+		out[i] = len(t.Content)
+	}
+	return out
+}
+
+func TestTrimHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  []Turn
+		maxChars int
+		want     []int // lengths of the kept turns
+		wantLast string
+	}{
+		{"empty", nil, 10, []int{}, ""},
+		{"fits", turns(3, 3, 3), 9, []int{3, 3, 3}, "c"},
+		{"drops middle", turns(2, 5, 3, 3), 9, []int{2, 3, 3}, "d"},
+		{"drops all but root and latest", turns(2, 5, 5, 4), 9, []int{2, 4}, "d"},
+		{"root cut to half", turns(20, 3, 3), 20, []int{10, 3, 3}, "c"},
+		{"root alone too long", turns(30), 20, []int{20}, "a"},
+		{"latest cut to fit", turns(4, 3, 40), 20, []int{4, 16}, "c"},
+		{"long root and latest", turns(40, 3, 40), 20, []int{10, 10}, "c"},
+	}
+	for _, tt := range tests {
+		got := TrimHistory(tt.history, tt.maxChars)
+		if gl := lens(got); !reflect.DeepEqual(gl, tt.want) {
+			t.Errorf("%s: kept lengths %v, want %v", tt.name, gl, tt.want)
+			continue
+		}
+		total := 0
+		for _, turn := range got {
+			total += len(turn.Content)
+		}
+		if total > tt.maxChars {
+			t.Errorf("%s: %d chars kept, budget %d", tt.name, total, tt.maxChars)
+		}
+		if len(got) > 0 {
+			if got[0].Role != RoleUser {
+				t.Errorf("%s: first kept turn is %s, want the user root", tt.name, got[0].Role)
+			}
+			if last := got[len(got)-1].Content; last[len(last)-1:] != tt.wantLast {
+				t.Errorf("%s: last kept turn %q is not the latest", tt.name, last)
+			}
+		}
+	}
+}
+
+func TestTrimHistoryUTF8(t *testing.T) {
+	history := []Turn{
+		{Role: RoleUser, Content: strings.Repeat("é", 10)},
+		{Role: RoleAssistant, Content: strings.Repeat("ß", 10)},
+		{Role: RoleUser, Content: strings.Repeat("ü", 10)},
+	}
+	for budget := 1; budget <= 60; budget++ {
+		for _, turn := range TrimHistory(history, budget) {
+			if !utf8.ValidString(turn.Content) {
+				t.Fatalf("budget %d: split a rune in %q", budget, turn.Content)
+			}
+		}
+	}
+}
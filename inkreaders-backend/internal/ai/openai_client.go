@@ -1,75 +1,92 @@
 package ai
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/grading"
 )
 
-type OpenAIClient struct {
-	Model  string
-	APIKey string
-	HTTP   *http.Client
+// LLMClient implements Client on top of any Provider: it owns the prompts
+// and output parsing, the Provider owns the wire protocol.
+type LLMClient struct {
+	Provider Provider
+	Models   Models
+	// RepairAttempts bounds the extra calls spent re-prompting invalid
+	// generated questions.
+	RepairAttempts int
+	// Debug logs the size and item count of each generation reply.
+	Debug bool
+	// OnUsage, if set, is called after every model call with its token
+	// counts and cost at Prices. The user comes from WithUser.
+	OnUsage func(ctx context.Context, rec UsageRecord)
+	Prices  Prices
 }
 
-func NewOpenAI(model string, apiKey string) *OpenAIClient {
-	return &OpenAIClient{
-		Model:  model,
-		APIKey: apiKey,
-		HTTP:   http.DefaultClient,
-	}
+func NewLLMClient(p Provider, models Models) *LLMClient {
+	return &LLMClient{Provider: p, Models: models, RepairAttempts: defaultRepairAttempts, Prices: DefaultPrices()}
+}
+
+// NewOpenAI is an LLMClient for api.openai.com with one model for everything.
+func NewOpenAI(model string, apiKey string) *LLMClient {
+	return NewLLMClient(NewProvider(ProviderConfig{
+		Kind:    ProviderOpenAI,
+		BaseURL: defaultBaseURLs[ProviderOpenAI],
+		APIKey:  apiKey,
+
+		StructuredOutput: true,
+	}), Models{Default: model})
 }
 
 var sysPrompt = `You are an exercise generator.
 Output STRICT UTF-8 JSON ONLY (no markdown, no comments, no prose).
-Each item must follow this shape:
-[
-  {
-    "type": "mcq" | "fill_blank" | "true_false",
-    "q": "Question text",
-    "options": ["A","B","C","D"],        // mcq only
-    "answer": "A" | "B" | "C" | "D" | true | false | "word",
-    "explain": "short rationale"
-  }
-]`
-
-func (c *OpenAIClient) Generate(ctx context.Context, p GenerateParams) (GenerateOut, error) {
+The output must follow this shape:
+{
+  "questions": [
+    {
+      "type": "mcq" | "fill_blank" | "true_false" | "multi_select" | "ordering" | "matching" | "short_answer" | "numeric",
+      "q": "Question text",
+      "options": ["A","B","C","D"],        // mcq, multi_select, ordering
+      "answer": "A" | "B" | "C" | "D" | true | false | "word",
+      "explain": "short rationale"
+    }
+  ]
+}
+Per type:
+- mcq: "answer" is the one correct option.
+- true_false: "answer" is true or false.
+- fill_blank: "answer" is the missing word(s).
+- multi_select: "answer" is an array of ALL correct options (two or more, but not every option).
+- ordering: "options" are the items in shuffled order; "answer" is the same items in the correct order.
+- matching: no "answer"; "pairs": [{"left": "term", "right": "its match"}, ...] with at least 3 pairs.
+- short_answer: "answer" is a model answer of 1-3 sentences; "rubric": [{"criterion": "what a good answer covers", "keywords": ["word", "synonym"]}, ...].
+- numeric: "answer" is a number; "tolerance" is the accepted absolute error (0 if exact); "unit" if any.`
+
+func (c *LLMClient) Generate(ctx context.Context, p GenerateParams) (GenerateOut, error) {
 	userPrompt := builderPrompt(p)
 
-	raw, err := c.chat(ctx, sysPrompt, userPrompt)
+	// 1) Ask for the questions and repair the invalid ones
+	wire, qerrs, err := c.generateQuestions(ctx, OpGenerate, userPrompt, p.Formats)
 	if err != nil {
-		return GenerateOut{}, err
+		return GenerateOut{Errors: qerrs}, err
 	}
-	fmt.Printf("[AI raw output] %s\n", string(raw))
 
-	// 1) Parse into a wire shape (what the model returns)
-	var wire []wireQuestion
-	if err := json.Unmarshal(raw, &wire); err != nil {
-		trim := trimToJSONArray(string(raw))
-		if trim == "" || json.Unmarshal([]byte(trim), &wire) != nil {
-			return GenerateOut{}, fmt.Errorf("invalid JSON from model: %w", err)
-		}
-	}
-
-	// 2) Validate the wire questions (types, prompts, answers)
-	if err := validateWireQuestions(wire, p.Formats); err != nil {
-		return GenerateOut{}, err
-	}
-
-	// 3) Map to db.Question (your schema)
+	// 2) Map to db.Question (your schema)
 	items := make([]db.Question, 0, len(wire))
 	for i, wq := range wire {
 		items = append(items, toDBQuestion(wq, i))
 	}
 
-	// 4) Infer title/format
+	// 3) Infer title/format
 	infTitle := p.Title
 	if strings.TrimSpace(infTitle) == "" {
 		if p.Topic != "" {
@@ -84,10 +101,11 @@ func (c *OpenAIClient) Generate(ctx context.Context, p GenerateParams) (Generate
 		InferredTitle:  infTitle,
 		InferredFormat: infFormat,
 		Questions:      items,
+		Errors:         qerrs,
 	}, nil
 }
 
-func (c *OpenAIClient) Remix(ctx context.Context, p RemixParams) (db.ExerciseSet, error) {
+func (c *LLMClient) Remix(ctx context.Context, p RemixParams) (db.ExerciseSet, error) {
 	// Provide parent questions to the model
 	parentJSON, _ := json.Marshal(p.Parent.Questions)
 	userPrompt := fmt.Sprintf(
@@ -95,25 +113,16 @@ func (c *OpenAIClient) Remix(ctx context.Context, p RemixParams) (db.ExerciseSet
 - Harder: %v
 - Reduce to: %d (0 = keep)
 - Switch format to: %s (empty = keep)
-Return STRICT JSON array as previously defined.
+Return STRICT JSON as previously defined.
 Parent questions:
 %s`, p.Harder, p.ReduceTo, p.Transform, string(parentJSON))
 
-	raw, err := c.chat(ctx, sysPrompt, userPrompt)
+	wire, qerrs, err := c.generateQuestions(ctx, OpRemix, userPrompt, nil)
 	if err != nil {
 		return db.ExerciseSet{}, err
 	}
-	fmt.Printf("[AI raw output] %s\n", string(raw))
-
-	var wire []wireQuestion
-	if err := json.Unmarshal(raw, &wire); err != nil {
-		trim := trimToJSONArray(string(raw))
-		if trim == "" || json.Unmarshal([]byte(trim), &wire) != nil {
-			return db.ExerciseSet{}, fmt.Errorf("invalid JSON from model: %w", err)
-		}
-	}
-	if err := validateWireQuestions(wire, nil); err != nil {
-		return db.ExerciseSet{}, err
+	for _, qe := range qerrs {
+		log.Printf("[AI] remix of %s dropped %s", p.Parent.ID, qe.Error)
 	}
 
 	items := make([]db.Question, 0, len(wire))
@@ -145,63 +154,54 @@ func builderPrompt(p GenerateParams) string {
 	if strings.TrimSpace(p.SourceText) != "" {
 		fmt.Fprintf(&sb, "Use ONLY the facts from the source text below.\nSOURCE TEXT:\n%s\n", p.SourceText)
 	}
-	fmt.Fprintf(&sb, "Return ONLY a JSON array of question objects (no markdown).")
+	fmt.Fprintf(&sb, "Return ONLY the JSON object (no markdown).")
 	return sb.String()
 }
 
-// ---------------- Chat ----------------
-
-func (c *OpenAIClient) chat(ctx context.Context, system string, user string) ([]byte, error) {
-	body := map[string]any{
-    "model": c.Model,
-    "messages": []map[string]string{
-        {"role": "system", "content": system},
-        {"role": "user", "content": user},
-    },
-    "temperature": 0.2,
-    "max_tokens": 600, // tweak as needed
-	}
+// promptVersion is part of every cache fingerprint. Bump it when the user
+// prompts, the question schema or reply parsing change, so results made
+// the old way stop being served.
+const promptVersion = 1
+
+// CacheFingerprint is the prompt version and the model and system prompt
+// op runs with; see Cached.
+func (c *LLMClient) CacheFingerprint(op string) string {
+	sys := tutorSysPrompt
+	switch op {
+	case OpGenerate, OpRemix:
+		sys = sysPrompt
+	case OpExplain:
+		sys = explainSysPrompt
+	}
+	return fmt.Sprintf("v%d\x00%s\x00%s", promptVersion, c.Models.For(op), sys)
+}
 
-	b, _ := json.Marshal(body)
+// ---------------- Chat ----------------
 
-	req, _ := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(b))
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
-	req.Header.Set("Content-Type", "application/json")
+// chatMaxTokens caps the non-streaming completions.
+const chatMaxTokens = 600
 
-	res, err := c.HTTP.Do(req)
+func (c *LLMClient) chat(ctx context.Context, op string, system string, user string) ([]byte, error) {
+	out, err := c.complete(ctx, op, []Message{
+		{Role: "system", Content: system},
+		{Role: RoleUser, Content: user},
+	}, chatMaxTokens, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-	if res.StatusCode >= 300 {
-		all, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("openai error %d: %s", res.StatusCode, string(all))
-	}
-	var out struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
-		return nil, err
-	}
-	if len(out.Choices) == 0 {
-		return nil, errors.New("no choices")
-	}
-	return []byte(out.Choices[0].Message.Content), nil
+	return []byte(out), nil
 }
 
 
-// Explain generates a concise explanation for the given question prompt and answer.
-// answer can be string, bool, or any JSON value — we stringify for safety.
-func (c *OpenAIClient) Explain(ctx context.Context, questionID string, prompt string, answer any) (string, error) {
-	// Build a focused system prompt that encourages a clear explanation suitable for students.
-	sys := `You are a friendly, concise teacher who explains answers for learners preparing for competitive exams.
+// explainSysPrompt is a focused system prompt that encourages a clear
+// explanation suitable for students.
+var explainSysPrompt = `You are a friendly, concise teacher who explains answers for learners preparing for competitive exams.
 Be brief but precise. Use short bullet points or 2-3 sentences. Make steps clear if applicable.
 OUTPUT: plain text explanation only (no JSON, no markdown).`
 
+// Explain generates a concise explanation for the given question prompt and answer.
+// answer can be string, bool, or any JSON value — we stringify for safety.
+func (c *LLMClient) Explain(ctx context.Context, questionID string, prompt string, answer any) (string, error) {
 	// Build the user prompt including the question/context and the answer
 	var ansStr string
 	switch v := answer.(type) {
@@ -218,7 +218,7 @@ OUTPUT: plain text explanation only (no JSON, no markdown).`
 	user := "Question:\n" + prompt + "\n\nAnswer:\n" + ansStr + "\n\nExplain why this answer is correct and provide brief steps or a short rationale."
 
 	// call chat helper (re-uses your existing chat code)
-	raw, err := c.chat(ctx, sys, user)
+	raw, err := c.chat(ctx, OpExplain, explainSysPrompt, user)
 	if err != nil {
 		return "", err
 	}
@@ -248,6 +248,11 @@ type wireQuestion struct {
 	CorrectAns   any         `json:"correct_answer"`
 	Explain      string      `json:"explain"`
 	Explanation  string      `json:"explanation"`
+
+	Pairs     []db.MatchPair       `json:"pairs"`  // matching
+	Rubric    []db.RubricCriterion `json:"rubric"` // short_answer
+	Tolerance float64              `json:"tolerance"`
+	Unit      string               `json:"unit"`
 }
 
 func (w wireQuestion) normPrompt() string {
@@ -293,8 +298,20 @@ func (w wireQuestion) normExplanation() string {
 	return strings.TrimSpace(w.Explain)
 }
 
+// numAnswer reads a numeric answer sent as a number or a numeric string.
+func (w wireQuestion) numAnswer() (float64, bool) {
+	switch v := w.normAnswer().(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
 func toDBQuestion(w wireQuestion, idx int) db.Question {
-	return db.Question{
+	q := db.Question{
 		ID:            fmt.Sprintf("q%d", idx+1),
 		Type:          w.Type,
 		Prompt:        w.normPrompt(),
@@ -303,56 +320,194 @@ func toDBQuestion(w wireQuestion, idx int) db.Question {
 		Explanation:   w.normExplanation(),
 		OrderIndex:    idx,
 	}
+	// Typed answers, with CorrectAnswer kept as their plain copy.
+	switch w.Type {
+	case db.QuestionMatching:
+		q.Pairs = w.Pairs
+		m := make(map[string]string, len(w.Pairs))
+		for _, p := range w.Pairs {
+			m[p.Left] = p.Right
+		}
+		q.CorrectAnswer = m
+	case db.QuestionShortAnswer:
+		model, _ := w.normAnswer().(string)
+		q.Rubric = &db.Rubric{ModelAnswer: model, Criteria: w.Rubric}
+	case db.QuestionOrdering:
+		// Keep the correct order as option texts, so letters or indexes
+		// in the model's answer still point at the right items once the
+		// options are shuffled for display.
+		opts := w.normOptions()
+		ans, _ := w.normAnswer().([]any)
+		order := make([]any, 0, len(ans))
+		for _, a := range ans {
+			if i := grading.OptionIndex(opts, a); i >= 0 {
+				order = append(order, opts[i])
+			}
+		}
+		q.CorrectAnswer = order
+		q.Options = shuffled(opts)
+	case db.QuestionNumeric:
+		v, _ := w.numAnswer()
+		q.Numeric = &db.NumericAnswer{Value: v, Tolerance: math.Abs(w.Tolerance), Unit: strings.TrimSpace(w.Unit)}
+		q.CorrectAnswer = v
+	}
+	return q
+}
+
+// shuffleAttempts bounds the reshuffles in shuffled.
+const shuffleAttempts = 10
+
+// shuffled returns a copy of opts in a random order, reshuffling up to
+// shuffleAttempts times to move off the given one. Models tend to list
+// ordering items already sorted, which would give the answer away.
+// Validated options are distinct, so this almost always succeeds; the cap
+// keeps options that repeat (every order alike) from looping forever.
+func shuffled(opts []string) []string {
+	out := slices.Clone(opts)
+	if len(out) < 2 {
+		return out
+	}
+	for range shuffleAttempts {
+		rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+		if !slices.Equal(out, opts) {
+			break
+		}
+	}
+	return out
+}
+
+// repeatedOption returns an option whose text, compared as grading
+// compares answers, matches an earlier option's.
+func repeatedOption(opts []string) (string, bool) {
+	for i, o := range opts {
+		if grading.OptionIndex(opts, o) != i {
+			return o, true
+		}
+	}
+	return "", false
 }
 
 // --------------- Validation / Inference ---------------
 
-func validateWireQuestions(items []wireQuestion, allowedFormats []string) error {
-	for i, q := range items {
-		if strings.TrimSpace(q.Type) == "" || strings.TrimSpace(q.normPrompt()) == "" {
-			return fmt.Errorf("q[%d] missing type or prompt", i)
+// validateWireQuestion checks one question's type, prompt and answer shape,
+// and that its type is in allowedFormats when any are given.
+func validateWireQuestion(q wireQuestion, allowedFormats []string) error {
+	if strings.TrimSpace(q.Type) == "" || strings.TrimSpace(q.normPrompt()) == "" {
+		return errors.New("missing type or prompt")
+	}
+	switch q.Type {
+	case "mcq":
+		opts := q.normOptions()
+		if len(opts) < 2 {
+			return errors.New("mcq requires at least 2 options")
 		}
-		switch q.Type {
-		case "mcq":
-			opts := q.normOptions()
-			if len(opts) < 2 {
-				return fmt.Errorf("q[%d] mcq requires at least 2 options", i)
+		if _, ok := q.normAnswer().(string); !ok {
+			return errors.New("mcq answer must be a string (one of the options)")
+		}
+	case "true_false":
+		switch q.normAnswer().(type) {
+		case bool:
+			// ok
+		case string:
+			// allow "true"/"false" as string
+			// (frontend/normalizer will coerce for checking)
+		default:
+			return errors.New("true_false answer must be boolean or 'true'/'false' string")
+		}
+	case "fill_blank":
+		if _, ok := q.normAnswer().(string); !ok {
+			return errors.New("fill_blank answer must be string")
+		}
+	case db.QuestionMultiSelect:
+		opts := q.normOptions()
+		if len(opts) < 3 {
+			return errors.New("multi_select requires at least 3 options")
+		}
+		if o, dup := repeatedOption(opts); dup {
+			return fmt.Errorf("multi_select option %q appears twice", o)
+		}
+		ans, ok := q.normAnswer().([]any)
+		if !ok {
+			return errors.New("multi_select answer must be an array of the correct options")
+		}
+		seen := map[int]bool{}
+		for _, a := range ans {
+			i := grading.OptionIndex(opts, a)
+			if i < 0 {
+				return fmt.Errorf("multi_select answer %v is not one of the options", a)
 			}
-			if _, ok := q.normAnswer().(string); !ok {
-				return fmt.Errorf("q[%d] mcq answer must be a string (one of the options)", i)
+			seen[i] = true
+		}
+		if len(seen) != len(ans) {
+			return errors.New("multi_select answer repeats an option")
+		}
+		// One correct option is an mcq; all of them needs no choosing.
+		if len(ans) < 2 || len(ans) >= len(opts) {
+			return errors.New("multi_select answer must be at least 2 of the options, but not all")
+		}
+	case db.QuestionOrdering:
+		opts := q.normOptions()
+		if len(opts) < 2 {
+			return errors.New("ordering requires at least 2 options")
+		}
+		if o, dup := repeatedOption(opts); dup {
+			return fmt.Errorf("ordering option %q appears twice", o)
+		}
+		ans, ok := q.normAnswer().([]any)
+		if !ok || len(ans) != len(opts) {
+			return errors.New("ordering answer must list every option once, in order")
+		}
+		seen := map[int]bool{}
+		for _, a := range ans {
+			i := grading.OptionIndex(opts, a)
+			if i < 0 || seen[i] {
+				return errors.New("ordering answer must list every option once, in order")
 			}
-		case "true_false":
-			switch q.normAnswer().(type) {
-			case bool:
-				// ok
-			case string:
-				// allow "true"/"false" as string
-				// (frontend/normalizer will coerce for checking)
-			default:
-				return fmt.Errorf("q[%d] true_false answer must be boolean or 'true'/'false' string", i)
+			seen[i] = true
+		}
+	case db.QuestionMatching:
+		if len(q.Pairs) < 2 {
+			return errors.New("matching requires at least 2 pairs")
+		}
+		lefts := map[string]bool{}
+		for _, p := range q.Pairs {
+			l := strings.ToLower(strings.TrimSpace(p.Left))
+			if l == "" || strings.TrimSpace(p.Right) == "" {
+				return errors.New("matching pairs need both left and right")
 			}
-		case "fill_blank":
-			if _, ok := q.normAnswer().(string); !ok {
-				return fmt.Errorf("q[%d] fill_blank answer must be string", i)
+			if lefts[l] {
+				return fmt.Errorf("matching left %q appears twice", p.Left)
 			}
-		case "mixed":
-			// Accept; your DB allows 'mixed'
-		default:
-			return fmt.Errorf("q[%d] invalid type %q", i, q.Type)
+			lefts[l] = true
 		}
-	}
-
-	// Optional: enforce allowedFormats if provided
-	if len(allowedFormats) > 0 {
-		ok := map[string]bool{}
-		for _, f := range allowedFormats {
-			ok[f] = true
+	case db.QuestionShortAnswer:
+		if s, _ := q.normAnswer().(string); strings.TrimSpace(s) == "" {
+			return errors.New("short_answer needs a model answer")
+		}
+		if len(q.Rubric) == 0 {
+			return errors.New("short_answer requires a rubric")
 		}
-		for i, q := range items {
-			if !ok[q.Type] {
-				return fmt.Errorf("q[%d] type %q not in allowed formats", i, q.Type)
+		for _, c := range q.Rubric {
+			if strings.TrimSpace(c.Criterion) == "" || len(c.Keywords) == 0 {
+				return errors.New("each rubric criterion needs text and keywords")
 			}
 		}
+	case db.QuestionNumeric:
+		if _, ok := q.numAnswer(); !ok {
+			return errors.New("numeric answer must be a number")
+		}
+		if q.Tolerance < 0 {
+			return errors.New("numeric tolerance must not be negative")
+		}
+	case "mixed":
+		// Accept; your DB allows 'mixed'
+	default:
+		return fmt.Errorf("invalid type %q", q.Type)
+	}
+
+	// Optional: enforce allowedFormats if provided
+	if len(allowedFormats) > 0 && !slices.Contains(allowedFormats, q.Type) {
+		return fmt.Errorf("type %q not in allowed formats", q.Type)
 	}
 	return nil
 }
@@ -382,9 +537,8 @@ func trimToJSONArray(s string) string {
 }
 
 
-// GenerateResponse generates a free-text AI response for a notebook topic
-func (c *OpenAIClient) GenerateResponse(ctx context.Context, prompt string) (string, error) {
-    sys := `You are a helpful tutor. 
+// tutorSysPrompt is the system prompt for free-text notebook responses.
+var tutorSysPrompt = `You are a helpful tutor. 
 Always detect the desired response language from the user’s query. 
 If the user specifies a language (e.g., "in Sanskrit", "in Hindi"), respond entirely in that language. 
 If no language is specified, reply in the most natural language implied by the query. 
@@ -395,10 +549,11 @@ Do not mention AI or your own identity.
 Stay factual — if you don’t know the answer, say "I don’t know" or "I am not sure". 
 Keep the response focused only on the user’s request.`
 
-    raw, err := c.chat(ctx, sys, prompt)
+// GenerateResponse generates a free-text AI response for a notebook topic
+func (c *LLMClient) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+    raw, err := c.chat(ctx, OpResponse, tutorSysPrompt, prompt)
     if err != nil {
         return "", err
     }
     return strings.TrimSpace(string(raw)), nil
 }
-
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/grading"
+)
+
+func TestToDBQuestionOrdering(t *testing.T) {
+	inOrder := []string{"Triassic", "Jurassic", "Cretaceous", "Paleogene"}
+	answers := map[string][]any{
+		"texts":   {"Triassic", "Jurassic", "Cretaceous", "Paleogene"},
+		"letters": {"A", "B", "C", "D"},
+		"indexes": {0.0, 1.0, 2.0, 3.0},
+	}
+	for name, ans := range answers {
+		w := wireQuestion{Type: db.QuestionOrdering, Q: "Oldest first", Options: slices.Clone(inOrder), Answer: ans}
+		if err := validateWireQuestion(w, nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for range 20 {
+			q := toDBQuestion(w, 0)
+			if slices.Equal(q.Options, inOrder) {
+				t.Fatalf("%s: options left in the correct order", name)
+			}
+			if !reflect.DeepEqual(q.CorrectAnswer, []any{"Triassic", "Jurassic", "Cretaceous", "Paleogene"}) {
+				t.Fatalf("%s: correct answer = %v, want the items in order", name, q.CorrectAnswer)
+			}
+			if !grading.Grade(q, q.CorrectAnswer) {
+				t.Fatalf("%s: the stored order does not grade correct", name)
+			}
+		}
+		if !slices.Equal(w.Options, inOrder) {
+			t.Fatalf("%s: shuffling changed the model's options", name)
+		}
+	}
+
+	// Repeated items have no order to move off; they fail validation, and
+	// converting them anyway still returns.
+	dup := wireQuestion{Type: db.QuestionOrdering, Q: "Order", Options: []string{"x", "x"}, Answer: []any{0.0, 1.0}}
+	if err := validateWireQuestion(dup, nil); err == nil {
+		t.Error("repeated ordering items passed validation")
+	}
+	if q := toDBQuestion(dup, 0); !slices.Equal(q.Options, []string{"x", "x"}) {
+		t.Errorf("repeated items: options = %v", q.Options)
+	}
+}
+
+func TestValidateMultiSelect(t *testing.T) {
+	opts := []string{"Mercury", "Venus", "Earth", "Mars"}
+	tests := []struct {
+		name   string
+		answer any
+		ok     bool
+	}{
+		{"two", []any{"Venus", "Mars"}, true},
+		{"all but one", []any{"A", "B", "C"}, true},
+		{"one", []any{"Venus"}, false},
+		{"all", []any{"Mercury", "Venus", "Earth", "Mars"}, false},
+		{"none", []any{}, false},
+		{"repeat", []any{"Venus", "B"}, false},
+		{"not an option", []any{"Venus", "Pluto"}, false},
+		{"not a list", "Venus", false},
+	}
+	for _, tt := range tests {
+		w := wireQuestion{Type: db.QuestionMultiSelect, Q: "Which are rocky?", Options: opts, Answer: tt.answer}
+		if err := validateWireQuestion(w, nil); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+
+	w := wireQuestion{Type: db.QuestionMultiSelect, Q: "Which are rocky?",
+		Options: []string{"Mercury", "Venus", "venus.", "Mars"}, Answer: []any{"Venus", "Mars"}}
+	if err := validateWireQuestion(w, nil); err == nil {
+		t.Error("options repeating a text passed validation")
+	}
+}
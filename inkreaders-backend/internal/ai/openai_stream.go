@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"context"
+	"strings"
+)
+
+// streamMaxTokens is the completion cap for streamed responses. Streaming
+// shows progress as it goes, so answers can be longer than chat's 600.
+const streamMaxTokens = 2000
+
+// GenerateResponseStream streams a notebook response.
+func (c *LLMClient) GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	return c.chatStream(ctx, OpResponse, []Message{
+		{Role: "system", Content: tutorSysPrompt},
+		{Role: RoleUser, Content: prompt},
+	}, onDelta)
+}
+
+// GenerateFollowUp streams an answer to prompt with the earlier turns of the
+// thread sent as chat history.
+func (c *LLMClient) GenerateFollowUp(ctx context.Context, history []Turn, prompt string, onDelta func(string)) (string, error) {
+	msgs := []Message{{Role: "system", Content: tutorSysPrompt}}
+	for _, t := range TrimHistory(history, historyMaxChars) {
+		msgs = append(msgs, Message{Role: t.Role, Content: t.Content})
+	}
+	msgs = append(msgs, Message{Role: RoleUser, Content: prompt})
+	return c.chatStream(ctx, OpFollowUp, msgs, onDelta)
+}
+
+func (c *LLMClient) chatStream(ctx context.Context, op string, msgs []Message, onDelta func(string)) (string, error) {
+	if err := checkGate(ctx); err != nil {
+		return "", err
+	}
+	model := c.Models.For(op)
+	full, usage, err := c.Provider.ChatStream(ctx, ChatRequest{
+		Model:       model,
+		Messages:    msgs,
+		Temperature: 0.2,
+		MaxTokens:   streamMaxTokens,
+	}, onDelta)
+	// A stream cut off midway still spent tokens, if the server said so.
+	c.meter(ctx, op, model, usage)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(full), nil
+}
@@ -0,0 +1,201 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider is the wire protocol to an LLM server. LLMClient builds the
+// prompts; a Provider only moves messages.
+type Provider interface {
+	Chat(ctx context.Context, req ChatRequest) (string, Usage, error)
+	// ChatStream calls onDelta with each text chunk and returns the full
+	// text. It is bounded by ctx only, not ProviderConfig.Timeout.
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (string, Usage, error)
+}
+
+type Message struct {
+	Role    string `json:"role"` // "system", RoleUser or RoleAssistant
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Model       string
+	Messages    []Message
+	Temperature float64
+	MaxTokens   int
+	// Schema, if set, asks for a JSON reply matching it: response_format
+	// on OpenAI-compatible servers, a forced tool call on Anthropic. The
+	// reply is the JSON document either way. Providers with structured
+	// output turned off ignore it and the prompt has to carry the shape.
+	Schema *JSONSchema
+}
+
+// JSONSchema is a named JSON Schema whose root is an object.
+type JSONSchema struct {
+	Name        string // [a-zA-Z0-9_-]+
+	Description string
+	Schema      map[string]any
+}
+
+// Operations that can each be given their own model.
+const (
+	OpGenerate = "generate"
+	OpRemix    = "remix"
+	OpExplain  = "explain"
+	OpResponse = "response"
+	OpFollowUp = "followup"
+)
+
+// Models picks the model for an operation, falling back to Default.
+type Models struct {
+	Default string
+	PerOp   map[string]string
+}
+
+func (m Models) For(op string) string {
+	if v := m.PerOp[op]; v != "" {
+		return v
+	}
+	return m.Default
+}
+
+// Provider kinds.
+const (
+	ProviderOpenAI    = "openai"    // api.openai.com or any OpenAI-compatible server
+	ProviderOllama    = "ollama"    // OpenAI-compatible, local defaults
+	ProviderAnthropic = "anthropic" // Anthropic messages API
+)
+
+type ProviderConfig struct {
+	Kind    string
+	BaseURL string // without the /chat/completions or /v1/messages suffix
+	APIKey  string
+	Headers map[string]string // extra headers on every request
+	Timeout time.Duration     // per non-streaming request
+	Models  Models
+	// StructuredOutput sends ChatRequest.Schema to the server. Turn it
+	// off for servers that reject response_format.
+	StructuredOutput bool
+}
+
+var defaultBaseURLs = map[string]string{
+	ProviderOpenAI:    "https://api.openai.com/v1",
+	ProviderOllama:    "http://localhost:11434/v1",
+	ProviderAnthropic: "https://api.anthropic.com",
+}
+
+// ProviderConfigFromEnv reads the LLM_* variables:
+//
+//	LLM_PROVIDER     openai (default; also llama.cpp, vLLM), ollama, anthropic
+//	LLM_BASE_URL     server base URL, e.g. http://localhost:8000/v1
+//	LLM_API_KEY      falls back to OPENAI_API_KEY / ANTHROPIC_API_KEY
+//	LLM_MODEL        default model; falls back to OPENAI_MODEL
+//	LLM_MODEL_<OP>   per operation: GENERATE, REMIX, EXPLAIN, RESPONSE, FOLLOWUP
+//	LLM_HEADERS      extra headers, "Name: value; Other: value"
+//	LLM_TIMEOUT      per-request timeout, e.g. 90s (default 60s)
+//	LLM_STRUCTURED_OUTPUT  false to not send JSON schemas (default true)
+func ProviderConfigFromEnv() (ProviderConfig, error) {
+	cfg := ProviderConfig{
+		Kind:    strings.ToLower(os.Getenv("LLM_PROVIDER")),
+		BaseURL: os.Getenv("LLM_BASE_URL"),
+		APIKey:  os.Getenv("LLM_API_KEY"),
+		Headers: map[string]string{},
+		Timeout: 60 * time.Second,
+		Models:  Models{Default: os.Getenv("LLM_MODEL"), PerOp: map[string]string{}},
+
+		StructuredOutput: true,
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = ProviderOpenAI
+	}
+	if _, ok := defaultBaseURLs[cfg.Kind]; !ok {
+		return cfg, fmt.Errorf("unknown LLM_PROVIDER %q", cfg.Kind)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURLs[cfg.Kind]
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+
+	switch cfg.Kind {
+	case ProviderOpenAI:
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if cfg.Models.Default == "" {
+			cfg.Models.Default = os.Getenv("OPENAI_MODEL")
+		}
+		if cfg.Models.Default == "" {
+			cfg.Models.Default = "gpt-4o-mini"
+		}
+	case ProviderOllama:
+		if cfg.Models.Default == "" {
+			cfg.Models.Default = "llama3.1"
+		}
+	case ProviderAnthropic:
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if cfg.Models.Default == "" {
+			cfg.Models.Default = "claude-3-5-haiku-latest"
+		}
+	}
+	// Only api.openai.com and Anthropic insist on a key; local servers don't.
+	if cfg.APIKey == "" && (cfg.Kind == ProviderAnthropic || strings.Contains(cfg.BaseURL, "api.openai.com")) {
+		return cfg, fmt.Errorf("LLM_API_KEY required for %s", cfg.Kind)
+	}
+
+	for _, op := range []string{OpGenerate, OpRemix, OpExplain, OpResponse, OpFollowUp} {
+		if v := os.Getenv("LLM_MODEL_" + strings.ToUpper(op)); v != "" {
+			cfg.Models.PerOp[op] = v
+		}
+	}
+	for _, h := range strings.Split(os.Getenv("LLM_HEADERS"), ";") {
+		name, value, ok := strings.Cut(h, ":")
+		if ok && strings.TrimSpace(name) != "" {
+			cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	if v := os.Getenv("LLM_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("LLM_TIMEOUT: %w", err)
+		}
+		cfg.Timeout = d
+	}
+	if v := os.Getenv("LLM_STRUCTURED_OUTPUT"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("LLM_STRUCTURED_OUTPUT: %w", err)
+		}
+		cfg.StructuredOutput = on
+	}
+	return cfg, nil
+}
+
+// NewProvider builds the Provider for cfg.Kind.
+func NewProvider(cfg ProviderConfig) Provider {
+	if cfg.Kind == ProviderAnthropic {
+		return &AnthropicProvider{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Headers: cfg.Headers, Timeout: cfg.Timeout, Structured: cfg.StructuredOutput, HTTP: http.DefaultClient}
+	}
+	return &OpenAICompatProvider{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Headers: cfg.Headers, Timeout: cfg.Timeout, Structured: cfg.StructuredOutput, HTTP: http.DefaultClient}
+}
+
+// withTimeout bounds one non-streaming request.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func setHeaders(req *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+}
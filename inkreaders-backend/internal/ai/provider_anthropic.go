@@ -0,0 +1,200 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const anthropicVersion = "2023-06-01"
+
+// AnthropicProvider speaks the Anthropic messages API. System messages are
+// lifted into the top-level "system" field, which is where the API wants
+// them.
+type AnthropicProvider struct {
+	BaseURL string
+	APIKey  string
+	Headers map[string]string
+	Timeout time.Duration
+	// Structured turns ChatRequest.Schema into a single tool the model is
+	// forced to call; Chat returns the tool input as JSON.
+	Structured bool
+	HTTP       *http.Client
+}
+
+func (p *AnthropicProvider) newRequest(ctx context.Context, req ChatRequest, stream bool) (*http.Request, error) {
+	var system []string
+	msgs := make([]Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024 // required by the API
+	}
+	body := map[string]any{
+		"model":       req.Model,
+		"messages":    msgs,
+		"max_tokens":  maxTokens,
+		"temperature": req.Temperature,
+	}
+	if len(system) > 0 {
+		body["system"] = strings.Join(system, "\n\n")
+	}
+	if stream {
+		body["stream"] = true
+	}
+	if req.Schema != nil && p.Structured {
+		body["tools"] = []map[string]any{{
+			"name":         req.Schema.Name,
+			"description":  req.Schema.Description,
+			"input_schema": req.Schema.Schema,
+		}}
+		body["tool_choice"] = map[string]any{"type": "tool", "name": req.Schema.Name}
+	}
+	b, _ := json.Marshal(body)
+
+	hr, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/v1/messages", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	hr.Header.Set("x-api-key", p.APIKey)
+	hr.Header.Set("anthropic-version", anthropicVersion)
+	hr.Header.Set("Content-Type", "application/json")
+	setHeaders(hr, p.Headers)
+	return hr, nil
+}
+
+func (p *AnthropicProvider) Chat(ctx context.Context, req ChatRequest) (string, Usage, error) {
+	ctx, cancel := withTimeout(ctx, p.Timeout)
+	defer cancel()
+	hr, err := p.newRequest(ctx, req, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	res, err := p.HTTP.Do(hr)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		all, _ := io.ReadAll(res.Body)
+		return "", Usage{}, fmt.Errorf("anthropic error %d: %s", res.StatusCode, string(all))
+	}
+	var out struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Input json.RawMessage `json:"input"` // tool_use
+		} `json:"content"`
+		Usage anthropicUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", Usage{}, err
+	}
+	var sb strings.Builder
+	for _, c := range out.Content {
+		switch c.Type {
+		case "text":
+			sb.WriteString(c.Text)
+		case "tool_use":
+			return string(c.Input), out.Usage.usage(), nil
+		}
+	}
+	if sb.Len() == 0 {
+		return "", Usage{}, errors.New("no text content")
+	}
+	return sb.String(), out.Usage.usage(), nil
+}
+
+// ChatStream reads the messages SSE stream: text arrives in
+// content_block_delta events and message_stop ends it. Input tokens come
+// in message_start, output tokens in message_delta.
+func (p *AnthropicProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (string, Usage, error) {
+	hr, err := p.newRequest(ctx, req, true)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	res, err := p.HTTP.Do(hr)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		all, _ := io.ReadAll(res.Body)
+		return "", Usage{}, fmt.Errorf("anthropic error %d: %s", res.StatusCode, string(all))
+	}
+
+	var full strings.Builder
+	var usage Usage
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var ev struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"` // message_start
+			Usage anthropicUsage `json:"usage"` // message_delta
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return full.String(), usage, fmt.Errorf("bad stream event: %w", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			usage.PromptTokens = ev.Message.Usage.InputTokens
+		case "message_delta":
+			usage.CompletionTokens = ev.Usage.OutputTokens
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				full.WriteString(ev.Delta.Text)
+				if onDelta != nil {
+					onDelta(ev.Delta.Text)
+				}
+			}
+		case "message_stop":
+			return full.String(), usage, nil
+		case "error":
+			msg := "unknown"
+			if ev.Error != nil {
+				msg = ev.Error.Message
+			}
+			return full.String(), usage, fmt.Errorf("anthropic stream error: %s", msg)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return full.String(), usage, err
+	}
+	return full.String(), usage, errors.New("stream ended without message_stop")
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) usage() Usage {
+	return Usage{PromptTokens: u.InputTokens, CompletionTokens: u.OutputTokens}
+}
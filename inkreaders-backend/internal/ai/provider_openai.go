@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAICompatProvider speaks the OpenAI chat completions API, which
+// OpenAI, Ollama (/v1), llama.cpp's server and vLLM all serve.
+type OpenAICompatProvider struct {
+	BaseURL string
+	APIKey  string // optional for local servers
+	Headers map[string]string
+	Timeout time.Duration
+	// Structured sends ChatRequest.Schema as a json_schema response_format.
+	Structured bool
+	HTTP       *http.Client
+}
+
+func (p *OpenAICompatProvider) newRequest(ctx context.Context, req ChatRequest, stream bool) (*http.Request, error) {
+	body := map[string]any{
+		"model":       req.Model,
+		"messages":    req.Messages,
+		"temperature": req.Temperature,
+		"max_tokens":  req.MaxTokens,
+	}
+	if stream {
+		body["stream"] = true
+		// Ask for a final chunk carrying the token counts.
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
+	if req.Schema != nil && p.Structured {
+		body["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":        req.Schema.Name,
+				"description": req.Schema.Description,
+				"schema":      req.Schema.Schema,
+				"strict":      true,
+			},
+		}
+	}
+	b, _ := json.Marshal(body)
+
+	hr, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/chat/completions", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if p.APIKey != "" {
+		hr.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	if stream {
+		hr.Header.Set("Accept", "text/event-stream")
+	}
+	setHeaders(hr, p.Headers)
+	return hr, nil
+}
+
+func (p *OpenAICompatProvider) Chat(ctx context.Context, req ChatRequest) (string, Usage, error) {
+	ctx, cancel := withTimeout(ctx, p.Timeout)
+	defer cancel()
+	hr, err := p.newRequest(ctx, req, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	res, err := p.HTTP.Do(hr)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		all, _ := io.ReadAll(res.Body)
+		return "", Usage{}, fmt.Errorf("openai error %d: %s", res.StatusCode, string(all))
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage openAIUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", Usage{}, err
+	}
+	if len(out.Choices) == 0 {
+		return "", Usage{}, errors.New("no choices")
+	}
+	return out.Choices[0].Message.Content, out.Usage.usage(), nil
+}
+
+// ChatStream reads the chat completions SSE stream (stream:true).
+func (p *OpenAICompatProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string)) (string, Usage, error) {
+	hr, err := p.newRequest(ctx, req, true)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	res, err := p.HTTP.Do(hr)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		all, _ := io.ReadAll(res.Body)
+		return "", Usage{}, fmt.Errorf("openai error %d: %s", res.StatusCode, string(all))
+	}
+
+	var full strings.Builder
+	var usage Usage
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue // blank separators, comments, event: lines
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return full.String(), usage, nil
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return full.String(), usage, fmt.Errorf("bad stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return full.String(), usage, fmt.Errorf("openai stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		full.WriteString(delta)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return full.String(), usage, err
+	}
+	// Some compatible servers close without [DONE]; treat a clean EOF
+	// after content as the end.
+	if full.Len() > 0 {
+		return full.String(), usage, nil
+	}
+	return "", usage, errors.New("stream ended without content")
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u openAIUsage) usage() Usage {
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
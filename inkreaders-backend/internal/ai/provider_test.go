@@ -0,0 +1,493 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standIn is an httptest LLM server that records every request and answers
+// with reply.
+type standIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+type recordedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]any
+}
+
+func newStandIn(t *testing.T, reply func(w http.ResponseWriter, r *http.Request)) *standIn {
+	t.Helper()
+	s := &standIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		rec := recordedRequest{Path: r.URL.Path, Header: r.Header.Clone()}
+		if err := json.Unmarshal(b, &rec.Body); err != nil {
+			t.Errorf("request body is not JSON: %s", b)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, rec)
+		s.mu.Unlock()
+		reply(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *standIn) last(t *testing.T) recordedRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no request reached the server")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func openAIReply(content string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"content": content}}},
+			"usage":   map[string]any{"prompt_tokens": 11, "completion_tokens": 5},
+		})
+	}
+}
+
+func anthropicReply(content string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": content}},
+			"usage":   map[string]any{"input_tokens": 9, "output_tokens": 3},
+		})
+	}
+}
+
+// clearLLMEnv unsets the variables ProviderConfigFromEnv reads, so the
+// environment the tests run in can't leak into them.
+func clearLLMEnv(t *testing.T) {
+	for _, k := range []string{
+		"LLM_PROVIDER", "LLM_BASE_URL", "LLM_API_KEY", "LLM_MODEL", "LLM_HEADERS", "LLM_TIMEOUT",
+		"LLM_STRUCTURED_OUTPUT", "OPENAI_API_KEY", "OPENAI_MODEL", "ANTHROPIC_API_KEY",
+		"LLM_MODEL_GENERATE", "LLM_MODEL_REMIX", "LLM_MODEL_EXPLAIN", "LLM_MODEL_RESPONSE", "LLM_MODEL_FOLLOWUP",
+	} {
+		t.Setenv(k, "")
+	}
+}
+
+func TestProviderConfigFromEnv(t *testing.T) {
+	clearLLMEnv(t)
+	t.Setenv("LLM_PROVIDER", "Ollama")
+	t.Setenv("LLM_BASE_URL", "http://gpu-box:11434/v1//")
+	t.Setenv("LLM_MODEL_EXPLAIN", "qwen2.5")
+	t.Setenv("LLM_HEADERS", "X-Team: books; X-Trace : on")
+	t.Setenv("LLM_TIMEOUT", "90s")
+	t.Setenv("LLM_STRUCTURED_OUTPUT", "false")
+
+	cfg, err := ProviderConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Kind != ProviderOllama || cfg.BaseURL != "http://gpu-box:11434/v1" {
+		t.Errorf("kind=%q base=%q", cfg.Kind, cfg.BaseURL)
+	}
+	if cfg.Models.Default != "llama3.1" || cfg.Models.For(OpExplain) != "qwen2.5" || cfg.Models.For(OpGenerate) != "llama3.1" {
+		t.Errorf("models = %+v", cfg.Models)
+	}
+	if want := map[string]string{"X-Team": "books", "X-Trace": "on"}; !reflect.DeepEqual(cfg.Headers, want) {
+		t.Errorf("headers = %v, want %v", cfg.Headers, want)
+	}
+	if cfg.Timeout != 90*time.Second || cfg.StructuredOutput {
+		t.Errorf("timeout=%s structured=%v", cfg.Timeout, cfg.StructuredOutput)
+	}
+	if cfg.APIKey != "" {
+		t.Errorf("local server got API key %q", cfg.APIKey)
+	}
+}
+
+func TestProviderConfigFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"unknown provider", map[string]string{"LLM_PROVIDER": "bard"}},
+		{"anthropic without key", map[string]string{"LLM_PROVIDER": "anthropic"}},
+		{"openai without key", map[string]string{}},
+		{"bad timeout", map[string]string{"LLM_PROVIDER": "ollama", "LLM_TIMEOUT": "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearLLMEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := ProviderConfigFromEnv(); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
+
+func TestOpenAICompatChat(t *testing.T) {
+	srv := newStandIn(t, openAIReply("Because."))
+	clearLLMEnv(t)
+	t.Setenv("LLM_BASE_URL", srv.URL+"/v1/")
+	t.Setenv("LLM_API_KEY", "sk-test")
+	t.Setenv("LLM_MODEL", "base-model")
+	t.Setenv("LLM_MODEL_EXPLAIN", "explain-model")
+	t.Setenv("LLM_HEADERS", "X-Org: ink")
+	cfg, err := ProviderConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metered []UsageRecord
+	c := NewLLMClient(NewProvider(cfg), cfg.Models)
+	c.OnUsage = func(_ context.Context, rec UsageRecord) { metered = append(metered, rec) }
+
+	out, err := c.Explain(context.Background(), "q1", "2+2?", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Because." {
+		t.Errorf("explain = %q", out)
+	}
+	req := srv.last(t)
+	if req.Path != "/v1/chat/completions" {
+		t.Errorf("path = %q", req.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if req.Header.Get("x-api-key") != "" {
+		t.Error("OpenAI request carries x-api-key")
+	}
+	if got := req.Header.Get("X-Org"); got != "ink" {
+		t.Errorf("X-Org = %q", got)
+	}
+	if req.Body["model"] != "explain-model" {
+		t.Errorf("explain used model %v", req.Body["model"])
+	}
+	msgs, _ := req.Body["messages"].([]any)
+	if len(msgs) == 0 || msgs[0].(map[string]any)["role"] != "system" {
+		t.Errorf("messages = %v, want the system prompt first", msgs)
+	}
+	if _, ok := req.Body["stream"]; ok {
+		t.Error("non-streaming request has stream set")
+	}
+
+	if _, err := c.GenerateResponse(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if m := srv.last(t).Body["model"]; m != "base-model" {
+		t.Errorf("response used model %v, want the default", m)
+	}
+	if len(metered) != 2 || metered[0].Model != "explain-model" || metered[0].Usage != (Usage{11, 5}) {
+		t.Errorf("metered = %+v", metered)
+	}
+}
+
+func TestOpenAICompatNoKey(t *testing.T) {
+	srv := newStandIn(t, openAIReply("ok"))
+	p := &OpenAICompatProvider{BaseURL: srv.URL, HTTP: http.DefaultClient}
+	if _, _, err := p.Chat(context.Background(), ChatRequest{Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.last(t).Header.Get("Authorization"); got != "" {
+		t.Errorf("keyless provider sent Authorization %q", got)
+	}
+}
+
+func TestAnthropicChat(t *testing.T) {
+	srv := newStandIn(t, anthropicReply("Because."))
+	clearLLMEnv(t)
+	t.Setenv("LLM_PROVIDER", "anthropic")
+	t.Setenv("LLM_BASE_URL", srv.URL+"/")
+	t.Setenv("ANTHROPIC_API_KEY", "ant-test")
+	t.Setenv("LLM_MODEL_EXPLAIN", "claude-explain")
+	cfg, err := ProviderConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewLLMClient(NewProvider(cfg), cfg.Models)
+
+	if _, err := c.Explain(context.Background(), "q1", "2+2?", "5"); err != nil {
+		t.Fatal(err)
+	}
+	req := srv.last(t)
+	if req.Path != "/v1/messages" {
+		t.Errorf("path = %q", req.Path)
+	}
+	if got := req.Header.Get("x-api-key"); got != "ant-test" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := req.Header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("anthropic-version = %q", got)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("Anthropic request carries Authorization")
+	}
+	if req.Body["model"] != "claude-explain" {
+		t.Errorf("model = %v", req.Body["model"])
+	}
+	if sys, _ := req.Body["system"].(string); sys != explainSysPrompt {
+		t.Errorf("system = %q, want the explain system prompt", sys)
+	}
+	for _, m := range req.Body["messages"].([]any) {
+		if m.(map[string]any)["role"] == "system" {
+			t.Error("system message left in messages")
+		}
+	}
+	if req.Body["max_tokens"] == nil {
+		t.Error("max_tokens missing; the API requires it")
+	}
+}
+
+func TestAnthropicSystemJoined(t *testing.T) {
+	srv := newStandIn(t, anthropicReply("ok"))
+	p := &AnthropicProvider{BaseURL: srv.URL, APIKey: "k", HTTP: http.DefaultClient}
+	_, usage, err := p.Chat(context.Background(), ChatRequest{Model: "m", Messages: []Message{
+		{Role: "system", Content: "one"},
+		{Role: RoleUser, Content: "hi"},
+		{Role: "system", Content: "two"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := srv.last(t)
+	if req.Body["system"] != "one\n\ntwo" {
+		t.Errorf("system = %q", req.Body["system"])
+	}
+	if n := len(req.Body["messages"].([]any)); n != 1 {
+		t.Errorf("%d messages, want 1", n)
+	}
+	if req.Body["max_tokens"] != float64(1024) {
+		t.Errorf("max_tokens = %v, want the 1024 default", req.Body["max_tokens"])
+	}
+	if usage != (Usage{9, 3}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestProviderTimeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+	providers := map[string]func(url string) Provider{
+		"openai": func(url string) Provider {
+			return &OpenAICompatProvider{BaseURL: url, Timeout: 50 * time.Millisecond, HTTP: http.DefaultClient}
+		},
+		"anthropic": func(url string) Provider {
+			return &AnthropicProvider{BaseURL: url, APIKey: "k", Timeout: 50 * time.Millisecond, HTTP: http.DefaultClient}
+		},
+	}
+	for name, mk := range providers {
+		t.Run(name, func(t *testing.T) {
+			srv := newStandIn(t, slow)
+			start := time.Now()
+			_, _, err := mk(srv.URL).Chat(context.Background(), ChatRequest{Model: "m"})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want a deadline error", err)
+			}
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("Chat took %s despite a 50ms timeout", d)
+			}
+		})
+	}
+}
+
+func sseReply(lines ...string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, l := range lines {
+			fmt.Fprint(w, l+"\n")
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func TestOpenAICompatChatStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		usage   Usage
+		wantErr bool
+	}{
+		{
+			name: "deltas, usage and done",
+			lines: []string{
+				`: keep-alive`, ``,
+				`data: {"choices":[{"delta":{"role":"assistant"}}]}`, ``,
+				`data: {"choices":[{"delta":{"content":"Hel"}}]}`, ``,
+				`data:{"choices":[{"delta":{"content":"lo"},"finish_reason":"stop"}]}`, ``,
+				`data: {"choices":[],"usage":{"prompt_tokens":4,"completion_tokens":2}}`, ``,
+				`data: [DONE]`, ``,
+			},
+			want:  "Hello",
+			usage: Usage{4, 2},
+		},
+		{
+			name:  "eof without done",
+			lines: []string{`data: {"choices":[{"delta":{"content":"Hi"}}]}`, ``},
+			want:  "Hi",
+		},
+		{
+			name:    "error chunk",
+			lines:   []string{`data: {"choices":[{"delta":{"content":"Hi"}}]}`, ``, `data: {"error":{"message":"overloaded"}}`, ``},
+			want:    "Hi",
+			wantErr: true,
+		},
+		{
+			name:    "empty stream",
+			lines:   []string{`data: [DONE]`},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStandIn(t, sseReply(tt.lines...))
+			p := &OpenAICompatProvider{BaseURL: srv.URL, APIKey: "k", HTTP: http.DefaultClient}
+			var deltas []string
+			full, usage, err := p.ChatStream(context.Background(), ChatRequest{Model: "m"}, func(d string) { deltas = append(deltas, d) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if full != tt.want || strings.Join(deltas, "") != tt.want {
+				t.Errorf("full=%q deltas=%q, want %q", full, deltas, tt.want)
+			}
+			if usage != tt.usage {
+				t.Errorf("usage = %+v, want %+v", usage, tt.usage)
+			}
+			req := srv.last(t)
+			if req.Body["stream"] != true || req.Header.Get("Accept") != "text/event-stream" {
+				t.Errorf("stream=%v accept=%q", req.Body["stream"], req.Header.Get("Accept"))
+			}
+			if so, _ := req.Body["stream_options"].(map[string]any); so["include_usage"] != true {
+				t.Errorf("stream_options = %v", req.Body["stream_options"])
+			}
+		})
+	}
+}
+
+func TestAnthropicChatStream(t *testing.T) {
+	event := func(typ, data string) string { return "event: " + typ + "\ndata: " + data + "\n" }
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		usage   Usage
+		wantErr bool
+	}{
+		{
+			name: "full message",
+			lines: []string{
+				event("message_start", `{"type":"message_start","message":{"usage":{"input_tokens":7,"output_tokens":1}}}`),
+				event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`),
+				event("ping", `{"type":"ping"}`),
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`),
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`),
+				event("content_block_stop", `{"type":"content_block_stop","index":0}`),
+				event("message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`),
+				event("message_stop", `{"type":"message_stop"}`),
+			},
+			want:  "Hello",
+			usage: Usage{7, 4},
+		},
+		{
+			name: "error event",
+			lines: []string{
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`),
+				event("error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`),
+			},
+			want:    "Hi",
+			wantErr: true,
+		},
+		{
+			name:    "cut off before message_stop",
+			lines:   []string{event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`)},
+			want:    "Hi",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStandIn(t, sseReply(tt.lines...))
+			p := &AnthropicProvider{BaseURL: srv.URL, APIKey: "k", HTTP: http.DefaultClient}
+			var deltas []string
+			full, usage, err := p.ChatStream(context.Background(), ChatRequest{Model: "m"}, func(d string) { deltas = append(deltas, d) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if full != tt.want || strings.Join(deltas, "") != tt.want {
+				t.Errorf("full=%q deltas=%q, want %q", full, deltas, tt.want)
+			}
+			if usage != tt.usage {
+				t.Errorf("usage = %+v, want %+v", usage, tt.usage)
+			}
+			if srv.last(t).Body["stream"] != true {
+				t.Error("stream not requested")
+			}
+		})
+	}
+}
+
+func TestOpenAICompatStrictSchema(t *testing.T) {
+	srv := newStandIn(t, openAIReply(`{"questions":[]}`))
+	p := &OpenAICompatProvider{BaseURL: srv.URL, Structured: true, HTTP: http.DefaultClient}
+	if _, _, err := p.Chat(context.Background(), ChatRequest{Model: "m", Schema: questionsSchema(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	rf, _ := srv.last(t).Body["response_format"].(map[string]any)
+	js, _ := rf["json_schema"].(map[string]any)
+	if js["strict"] != true {
+		t.Fatalf("json_schema = %v, want strict", js)
+	}
+
+	// Strict mode rejects a schema unless every object is closed and
+	// requires all of its properties.
+	var walk func(path string, node any)
+	walk = func(path string, node any) {
+		switch n := node.(type) {
+		case map[string]any:
+			if props, ok := n["properties"].(map[string]any); ok {
+				if n["additionalProperties"] != false {
+					t.Errorf("%s: additionalProperties not false", path)
+				}
+				req := map[string]bool{}
+				for _, r := range n["required"].([]any) {
+					req[r.(string)] = true
+				}
+				for k := range props {
+					if !req[k] {
+						t.Errorf("%s: property %q not required", path, k)
+					}
+				}
+			}
+			for k, v := range n {
+				walk(path+"."+k, v)
+			}
+		case []any:
+			for i, v := range n {
+				walk(fmt.Sprintf("%s[%d]", path, i), v)
+			}
+		}
+	}
+	walk("schema", js["schema"])
+}
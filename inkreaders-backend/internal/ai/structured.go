@@ -0,0 +1,259 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// defaultRepairAttempts is how many times the invalid questions of a batch
+// are sent back to the model for correction.
+const defaultRepairAttempts = 2
+
+// generateMaxTokens caps question-generation calls. A batch of questions is
+// far longer than an explanation, and a truncated reply is invalid JSON.
+const generateMaxTokens = 4000
+
+// QuestionError is a generated question that still failed validation when
+// the repair attempts ran out. Raw is the model's item as it came back.
+type QuestionError struct {
+	Error string          `json:"error"`
+	Raw   json.RawMessage `json:"raw,omitempty"`
+}
+
+var stringArray = map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+
+// nullable lets a field be null. Strict json_schema mode requires every
+// property to be listed as required, so optional fields are nullable instead;
+// null decodes to the zero value.
+func nullable(t string) []string { return []string{t, "null"} }
+
+// object is a closed JSON object with every property required, as strict
+// mode demands.
+func object(props map[string]any) map[string]any {
+	required := make([]string, 0, len(props))
+	for k := range props {
+		required = append(required, k)
+	}
+	sort.Strings(required)
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// questionsSchema describes {"questions": [...]} restricted to formats.
+func questionsSchema(formats []string) *JSONSchema {
+	if len(formats) == 0 {
+		formats = db.QuestionTypes
+	}
+	return &JSONSchema{
+		Name:        "exercise_questions",
+		Description: "Return the generated exercise questions.",
+		Schema: object(map[string]any{
+			"questions": map[string]any{
+				"type": "array",
+				"items": object(map[string]any{
+					"type":    map[string]any{"type": "string", "enum": formats},
+					"q":       map[string]any{"type": "string"},
+					"options": map[string]any{"type": nullable("array"), "items": map[string]any{"type": "string"}},
+					"answer": map[string]any{"anyOf": []map[string]any{
+						{"type": "string"},
+						{"type": "boolean"},
+						{"type": "number"},
+						stringArray,
+						{"type": "null"},
+					}},
+					"pairs": map[string]any{
+						"type": nullable("array"),
+						"items": object(map[string]any{
+							"left":  map[string]any{"type": "string"},
+							"right": map[string]any{"type": "string"},
+						}),
+					},
+					"rubric": map[string]any{
+						"type": nullable("array"),
+						"items": object(map[string]any{
+							"criterion": map[string]any{"type": "string"},
+							"keywords":  stringArray,
+						}),
+					},
+					"tolerance": map[string]any{"type": nullable("number")},
+					"unit":      map[string]any{"type": nullable("string")},
+					"explain":   map[string]any{"type": "string"},
+				}),
+			},
+		}),
+	}
+}
+
+// genSlot is one question of a batch: the model's raw item and, once
+// decoded, the question or why it was rejected.
+type genSlot struct {
+	raw json.RawMessage
+	q   wireQuestion
+	err error
+}
+
+// generateQuestions asks for a batch of questions, then re-prompts with only
+// the invalid ones, up to RepairAttempts times, replacing each in place. It
+// returns the valid questions in order plus the ones that never validated,
+// and fails only if the model could not be reached or nothing validated.
+func (c *LLMClient) generateQuestions(ctx context.Context, op, prompt string, formats []string) ([]wireQuestion, []QuestionError, error) {
+	schema := questionsSchema(formats)
+	msgs := []Message{
+		{Role: "system", Content: sysPrompt},
+		{Role: RoleUser, Content: prompt},
+	}
+
+	var slots []genSlot
+	var raw string
+	var err error
+	for attempt := 0; attempt <= c.RepairAttempts; attempt++ {
+		if slots == nil {
+			// First call, or the last reply held no parseable batch.
+			raw, err = c.complete(ctx, op, msgs, generateMaxTokens, schema)
+			if err != nil {
+				return nil, nil, err
+			}
+			items, perr := parseQuestionItems(raw)
+			c.debugReply("generate", raw, items)
+			if perr != nil {
+				err = perr
+				continue
+			}
+			slots = make([]genSlot, len(items))
+			for i, it := range items {
+				slots[i] = decodeSlot(it, formats)
+			}
+			continue
+		}
+
+		bad := invalidSlots(slots)
+		if len(bad) == 0 {
+			break
+		}
+		repair := append(msgs[:2:2],
+			Message{Role: RoleAssistant, Content: raw},
+			Message{Role: RoleUser, Content: repairPrompt(slots, bad)},
+		)
+		out, rerr := c.complete(ctx, op, repair, generateMaxTokens, schema)
+		if rerr != nil {
+			log.Printf("[AI] repair attempt %d: %v", attempt, rerr)
+			break
+		}
+		items, perr := parseQuestionItems(out)
+		c.debugReply("repair", out, items)
+		if perr != nil {
+			log.Printf("[AI] repair attempt %d: %v", attempt, perr)
+			continue
+		}
+		for k, i := range bad {
+			if k < len(items) {
+				slots[i] = decodeSlot(items[k], formats)
+			}
+		}
+	}
+	if slots == nil {
+		return nil, nil, fmt.Errorf("invalid JSON from model: %w", err)
+	}
+
+	var valid []wireQuestion
+	var qerrs []QuestionError
+	for i, s := range slots {
+		if s.err != nil {
+			qerrs = append(qerrs, QuestionError{Error: fmt.Sprintf("q[%d] %v", i, s.err), Raw: s.raw})
+			continue
+		}
+		valid = append(valid, s.q)
+	}
+	if len(valid) == 0 {
+		if len(qerrs) == 0 {
+			return nil, nil, errors.New("model returned no questions")
+		}
+		return nil, qerrs, fmt.Errorf("no valid questions: %s", qerrs[0].Error)
+	}
+	return valid, qerrs, nil
+}
+
+func (c *LLMClient) complete(ctx context.Context, op string, msgs []Message, maxTokens int, schema *JSONSchema) (string, error) {
+	if err := checkGate(ctx); err != nil {
+		return "", err
+	}
+	model := c.Models.For(op)
+	out, usage, err := c.Provider.Chat(ctx, ChatRequest{
+		Model:       model,
+		Messages:    msgs,
+		Temperature: 0.2,
+		MaxTokens:   maxTokens,
+		Schema:      schema,
+	})
+	c.meter(ctx, op, model, usage)
+	return out, err
+}
+
+// parseQuestionItems accepts {"questions": [...]}, a bare array, or either
+// wrapped in prose (for servers without structured output), and returns
+// the items undecoded so one bad item cannot sink the batch.
+func parseQuestionItems(raw string) ([]json.RawMessage, error) {
+	var obj struct {
+		Questions []json.RawMessage `json:"questions"`
+	}
+	if json.Unmarshal([]byte(raw), &obj) == nil && obj.Questions != nil {
+		return obj.Questions, nil
+	}
+	var items []json.RawMessage
+	if json.Unmarshal([]byte(raw), &items) == nil {
+		return items, nil
+	}
+	if trim := trimToJSONArray(raw); trim != "" && json.Unmarshal([]byte(trim), &items) == nil {
+		return items, nil
+	}
+	return nil, errors.New("no JSON question array in output")
+}
+
+func decodeSlot(raw json.RawMessage, formats []string) genSlot {
+	s := genSlot{raw: raw}
+	if err := json.Unmarshal(raw, &s.q); err != nil {
+		s.err = fmt.Errorf("not a question object: %v", err)
+		return s
+	}
+	s.err = validateWireQuestion(s.q, formats)
+	return s
+}
+
+func invalidSlots(slots []genSlot) []int {
+	var bad []int
+	for i, s := range slots {
+		if s.err != nil {
+			bad = append(bad, i)
+		}
+	}
+	return bad
+}
+
+func repairPrompt(slots []genSlot, bad []int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of those questions are invalid. Rewrite each one so it is valid, keeping its topic and language.\n", len(bad))
+	fmt.Fprintf(&sb, "Return ONLY the %d corrected questions, in this order, in the same JSON shape.\n", len(bad))
+	for k, i := range bad {
+		fmt.Fprintf(&sb, "\n%d. Problem: %v\nQuestion: %s\n", k+1, slots[i].err, slots[i].raw)
+	}
+	return sb.String()
+}
+
+// debugReply logs the size of a generation reply and how many question items
+// it held, without the content itself.
+func (c *LLMClient) debugReply(stage, raw string, items []json.RawMessage) {
+	if c.Debug {
+		log.Printf("[AI] %s reply: %d bytes, %d items", stage, len(raw), len(items))
+	}
+}
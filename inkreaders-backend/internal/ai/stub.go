@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
 )
@@ -70,6 +71,28 @@ func (s *Stub) GenerateResponse(ctx context.Context, prompt string) (string, err
 	return fmt.Sprintf("📒 Stub AI response for: %s\n\nThis is a placeholder response. In production, the AI will generate a complete answer.", prompt), nil
 }
 
+// GenerateResponseStream streams the canned response word by word so the
+// SSE path can be exercised without OpenAI.
+func (s *Stub) GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	full, _ := s.GenerateResponse(ctx, prompt)
+	for _, word := range strings.SplitAfter(full, " ") {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(40 * time.Millisecond):
+		}
+		if onDelta != nil {
+			onDelta(word)
+		}
+	}
+	return full, nil
+}
+
+// GenerateFollowUp streams a canned answer that notes how much history it got.
+func (s *Stub) GenerateFollowUp(ctx context.Context, history []Turn, prompt string, onDelta func(string)) (string, error) {
+	return s.GenerateResponseStream(ctx, fmt.Sprintf("%s (follow-up after %d earlier turns)", prompt, len(history)), onDelta)
+}
+
 func ifEmpty(s, def string) string {
 	if s == "" {
 		return def
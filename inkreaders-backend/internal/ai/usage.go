@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Usage is the token count of one model call.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func (u Usage) Total() int { return u.PromptTokens + u.CompletionTokens }
+
+// UsageRecord is one metered call, as handed to LLMClient.OnUsage.
+type UsageRecord struct {
+	UserID  uuid.UUID // uuid.Nil when the context carries no user
+	Op      string
+	Model   string
+	Usage   Usage
+	CostUSD float64
+}
+
+type userKey struct{}
+
+// WithUser tags ctx with the user model calls are billed to.
+func WithUser(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserFrom returns the user set by WithUser, or uuid.Nil.
+func UserFrom(ctx context.Context) uuid.UUID {
+	id, _ := ctx.Value(userKey{}).(uuid.UUID)
+	return id
+}
+
+// Gate decides whether a call that would reach the model may go ahead,
+// e.g. against a spending quota. Results served from the cache never
+// consult it.
+type Gate func(ctx context.Context) error
+
+type gateKey struct{}
+
+// WithGate makes LLMClient ask gate before each model call made with ctx;
+// an error fails the call unsent.
+func WithGate(ctx context.Context, gate Gate) context.Context {
+	return context.WithValue(ctx, gateKey{}, gate)
+}
+
+func checkGate(ctx context.Context) error {
+	if gate, ok := ctx.Value(gateKey{}).(Gate); ok {
+		return gate(ctx)
+	}
+	return nil
+}
+
+// Price is USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Prices maps a model name, or a prefix of dated names such as
+// gpt-4o-mini-2024-07-18, to its price. Unknown models cost nothing,
+// which is right for local servers.
+type Prices map[string]Price
+
+// DefaultPrices are list prices at the time of writing; override them with
+// LLM_PRICES.
+func DefaultPrices() Prices {
+	return Prices{
+		"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+		"gpt-4o":            {Input: 2.50, Output: 10.00},
+		"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+		"gpt-4.1":           {Input: 2.00, Output: 8.00},
+		"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+		"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	}
+}
+
+// Cost prices u at the longest matching model prefix.
+func (p Prices) Cost(model string, u Usage) float64 {
+	best, found := "", false
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) >= len(best) {
+			best, found = name, true
+		}
+	}
+	if !found {
+		return 0
+	}
+	price := p[best]
+	return (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6
+}
+
+// PricesFromEnv is DefaultPrices with LLM_PRICES applied on top, given as
+// "model=input/output; ..." in USD per million tokens.
+func PricesFromEnv() (Prices, error) {
+	prices := DefaultPrices()
+	for _, entry := range strings.Split(os.Getenv("LLM_PRICES"), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		model, rates, ok := strings.Cut(entry, "=")
+		in, out, ok2 := strings.Cut(rates, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("LLM_PRICES: bad entry %q", entry)
+		}
+		var price Price
+		var err error
+		if price.Input, err = strconv.ParseFloat(strings.TrimSpace(in), 64); err != nil {
+			return nil, fmt.Errorf("LLM_PRICES: %q: %w", entry, err)
+		}
+		if price.Output, err = strconv.ParseFloat(strings.TrimSpace(out), 64); err != nil {
+			return nil, fmt.Errorf("LLM_PRICES: %q: %w", entry, err)
+		}
+		prices[strings.TrimSpace(model)] = price
+	}
+	return prices, nil
+}
+
+// meter reports one call's usage, if anyone is listening and it used any
+// tokens.
+func (c *LLMClient) meter(ctx context.Context, op, model string, u Usage) {
+	if c.OnUsage == nil || u.Total() == 0 {
+		return
+	}
+	c.OnUsage(ctx, UsageRecord{
+		UserID:  UserFrom(ctx),
+		Op:      op,
+		Model:   model,
+		Usage:   u,
+		CostUSD: c.Prices.Cost(model, u),
+	})
+}
@@ -0,0 +1,194 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanISBN(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"978-0-306-40615-7", "9780306406157"},
+		{" 0 8044 2957 x ", "080442957X"},
+		{"ISBN: 0-306-40615-2", "0306406152"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := CleanISBN(tt.in); got != tt.want {
+			t.Errorf("CleanISBN(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidISBN(t *testing.T) {
+	tests := []struct {
+		in     string
+		valid  bool
+		length int
+	}{
+		{"0306406152", true, 10},
+		{"080442957X", true, 10},
+		{"0306406153", false, 10},   // bad check digit
+		{"08044295X7", false, 10},   // X only allowed last
+		{"030640615", false, 10},    // too short
+		{"9780306406157", true, 13}, // 978
+		{"9791090636071", true, 13}, // 979
+		{"9780306406158", false, 13},
+		{"978030640615X", false, 13}, // no X in ISBN-13
+		{"978030640615", false, 13},
+	}
+	for _, tt := range tests {
+		var got bool
+		if tt.length == 10 {
+			got = ValidISBN10(tt.in)
+		} else {
+			got = ValidISBN13(tt.in)
+		}
+		if got != tt.valid {
+			t.Errorf("ValidISBN%d(%q) = %v, want %v", tt.length, tt.in, got, tt.valid)
+		}
+	}
+}
+
+func TestISBNConversion(t *testing.T) {
+	pairs := []struct{ isbn10, isbn13 string }{
+		{"0306406152", "9780306406157"},
+		{"080442957X", "9780804429573"},
+		{"0140449132", "9780140449136"},
+	}
+	for _, p := range pairs {
+		if got, err := ISBN10To13(p.isbn10); err != nil || got != p.isbn13 {
+			t.Errorf("ISBN10To13(%q) = %q, %v; want %q", p.isbn10, got, err, p.isbn13)
+		}
+		if got, err := ISBN13To10(p.isbn13); err != nil || got != p.isbn10 {
+			t.Errorf("ISBN13To10(%q) = %q, %v; want %q", p.isbn13, got, err, p.isbn10)
+		}
+	}
+
+	if got, err := ISBN13To10("9791090636071"); err != nil || got != "" {
+		t.Errorf("ISBN13To10(979…) = %q, %v; want no ISBN-10 and no error", got, err)
+	}
+	if _, err := ISBN10To13("0306406153"); err != ErrISBNChecksum {
+		t.Errorf("ISBN10To13(bad) err = %v, want ErrISBNChecksum", err)
+	}
+	if _, err := ISBN13To10("9780306406158"); err != ErrISBNChecksum {
+		t.Errorf("ISBN13To10(bad) err = %v, want ErrISBNChecksum", err)
+	}
+}
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		in             string
+		isbn10, isbn13 string
+		err            error
+	}{
+		{"0-306-40615-2", "0306406152", "9780306406157", nil},
+		{"0-8044-2957-x", "080442957X", "9780804429573", nil},
+		{"978-0-306-40615-7", "0306406152", "9780306406157", nil},
+		{"979-10-90636-07-1", "", "9791090636071", nil},
+		{"0-306-40615-3", "", "", ErrISBNChecksum},
+		{"978-0-306-40615-8", "", "", ErrISBNChecksum},
+		{"12345", "", "", ErrISBNLength},
+		{"", "", "", ErrISBNLength},
+	}
+	for _, tt := range tests {
+		i10, i13, err := NormalizeISBN(tt.in)
+		if i10 != tt.isbn10 || i13 != tt.isbn13 || err != tt.err {
+			t.Errorf("NormalizeISBN(%q) = %q, %q, %v; want %q, %q, %v", tt.in, i10, i13, err, tt.isbn10, tt.isbn13, tt.err)
+		}
+	}
+}
+
+func TestResolveISBNs(t *testing.T) {
+	tests := []struct {
+		name           string
+		in10, in13     string
+		isbn10, isbn13 string
+	}{
+		{"both agree", "0306406152", "9780306406157", "0306406152", "9780306406157"},
+		{"only 10 fills 13", "0-306-40615-2", "", "0306406152", "9780306406157"},
+		{"only 13 fills 10", "", "978-0-306-40615-7", "0306406152", "9780306406157"},
+		{"disagree: 13 wins", "080442957X", "9780306406157", "0306406152", "9780306406157"},
+		{"bad 13 falls back to 10", "080442957X", "9780306406158", "080442957X", "9780804429573"},
+		{"bad 10 dropped", "0306406153", "9780804429573", "080442957X", "9780804429573"},
+		{"979 keeps no 10", "0306406152", "9791090636071", "", "9791090636071"},
+		{"13 typed in the 10 field", "9780306406157", "", "0306406152", "9780306406157"},
+		{"both bad", "123", "456", "", ""},
+		{"both empty", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		i10, i13 := ResolveISBNs(tt.in10, tt.in13)
+		if i10 != tt.isbn10 || i13 != tt.isbn13 {
+			t.Errorf("%s: ResolveISBNs(%q, %q) = %q, %q; want %q, %q", tt.name, tt.in10, tt.in13, i10, i13, tt.isbn10, tt.isbn13)
+		}
+	}
+}
+
+func TestTitleKey(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"The Hobbit", "hobbit"},
+		{"The Hobbit: Or There and Back Again", "Hobbit"},
+		{"A Wizard of Earthsea", "wizard of earthsea"},
+		{"An Instance of the Fingerpost", "instance of the fingerpost"},
+		{"Les Misérables", "les miserables"},
+		{"  Cien  años de soledad! ", "cien anos de soledad"},
+		{"Catch-22", "catch 22"},
+		{"“Dune”", "dune"},
+	}
+	for _, tt := range tests {
+		if ka, kb := TitleKey(tt.a), TitleKey(tt.b); ka != kb {
+			t.Errorf("TitleKey(%q) = %q, TitleKey(%q) = %q; want equal", tt.a, ka, tt.b, kb)
+		}
+	}
+
+	distinct := []struct{ a, b string }{
+		{"The Hobbit", "The Hobbits"},
+		{"Dune", "Dune Messiah"},
+	}
+	for _, tt := range distinct {
+		if TitleKey(tt.a) == TitleKey(tt.b) {
+			t.Errorf("TitleKey(%q) == TitleKey(%q); want different", tt.a, tt.b)
+		}
+	}
+	// A bare article is a title, not a prefix to drop.
+	if got := TitleKey("The"); got != "the" {
+		t.Errorf("TitleKey(The) = %q", got)
+	}
+	if got := TitleKey(": subtitle only"); got != "subtitle only" {
+		t.Errorf("TitleKey(leading colon) = %q", got)
+	}
+}
+
+func TestAuthorsKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		same bool
+	}{
+		{"order ignored", []string{"Terry Pratchett", "Neil Gaiman"}, []string{"Neil Gaiman", "Terry Pratchett"}, true},
+		{"last, first", []string{"Tolkien, J. R. R."}, []string{"J. R. R. Tolkien"}, true},
+		{"accents and case", []string{"Gabriel García Márquez"}, []string{"gabriel garcia marquez"}, true},
+		{"repeats and blanks dropped", []string{"Ursula K. Le Guin", " ", "ursula k le guin"}, []string{"Ursula K. Le Guin"}, true},
+		{"initials kept", []string{"J. R. R. Tolkien"}, []string{"JRR Tolkien"}, false},
+		{"different authors", []string{"Neil Gaiman"}, []string{"Neil Gaiman", "Terry Pratchett"}, false},
+	}
+	for _, tt := range tests {
+		if got := AuthorsKey(tt.a) == AuthorsKey(tt.b); got != tt.same {
+			t.Errorf("%s: AuthorsKey(%q)=%q vs AuthorsKey(%q)=%q; same=%v, want %v",
+				tt.name, tt.a, AuthorsKey(tt.a), tt.b, AuthorsKey(tt.b), got, tt.same)
+		}
+	}
+	if got := AuthorsKey(nil); got != "" {
+		t.Errorf("AuthorsKey(nil) = %q", got)
+	}
+}
+
+func TestCleanAuthors(t *testing.T) {
+	got := CleanAuthors([]string{"  Le Guin,  Ursula K. ", "Ursula K. Le Guin", "", "Smith, Jr., John"})
+	want := []string{"Ursula K. Le Guin", "Smith, Jr., John"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CleanAuthors = %q, want %q", got, want)
+	}
+	if got := CleanTitle(`  "The   Left Hand of Darkness"  `); got != "The Left Hand of Darkness" {
+		t.Errorf("CleanTitle = %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Package catalog normalises book identity: ISBN checksums and conversion,
+// and title/author keys used to recognise the same book typed differently.
+package catalog
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrISBNLength   = errors.New("isbn: must have 10 or 13 digits")
+	ErrISBNChecksum = errors.New("isbn: bad check digit")
+)
+
+// CleanISBN drops everything but digits and a trailing X, so "978-0-14-
+// 044913-6" and "0 14 044913 X" compare as plain strings.
+func CleanISBN(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if r >= '0' && r <= '9' || r == 'X' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// ValidISBN10 reports whether s (already cleaned) is a well-formed ISBN-10.
+func ValidISBN10(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var d int
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += d * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// ValidISBN13 reports whether s (already cleaned) is a well-formed ISBN-13.
+func ValidISBN13(s string) bool {
+	if len(s) != 13 {
+		return false
+	}
+	for i := 0; i < 13; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return isbn13Check(s[:12]) == s[12]
+}
+
+// ISBN10To13 converts a valid ISBN-10 to its 978-prefixed ISBN-13.
+func ISBN10To13(isbn10 string) (string, error) {
+	if !ValidISBN10(isbn10) {
+		return "", ErrISBNChecksum
+	}
+	body := "978" + isbn10[:9]
+	return body + string(isbn13Check(body)), nil
+}
+
+// ISBN13To10 converts a valid ISBN-13 back to ISBN-10. Only 978-prefixed
+// numbers have one; for 979 it returns "" and no error.
+func ISBN13To10(isbn13 string) (string, error) {
+	if !ValidISBN13(isbn13) {
+		return "", ErrISBNChecksum
+	}
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", nil
+	}
+	body := isbn13[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", nil
+	}
+	return body + string(rune('0'+check)), nil
+}
+
+// NormalizeISBN accepts an ISBN-10 or ISBN-13 in any formatting and returns
+// both forms (isbn10 is "" for 979 numbers).
+func NormalizeISBN(s string) (isbn10, isbn13 string, err error) {
+	c := CleanISBN(s)
+	switch len(c) {
+	case 10:
+		if !ValidISBN10(c) {
+			return "", "", ErrISBNChecksum
+		}
+		isbn13, _ = ISBN10To13(c)
+		return c, isbn13, nil
+	case 13:
+		if !ValidISBN13(c) {
+			return "", "", ErrISBNChecksum
+		}
+		isbn10, _ = ISBN13To10(c)
+		return isbn10, c, nil
+	}
+	return "", "", ErrISBNLength
+}
+
+// ResolveISBNs reconciles the isbn10/isbn13 pair a poster typed. Invalid
+// values are dropped; a valid one fills in the other. When both are valid
+// but disagree, the ISBN-13 wins.
+func ResolveISBNs(isbn10, isbn13 string) (string, string) {
+	if i10, i13, err := NormalizeISBN(isbn13); err == nil {
+		return i10, i13
+	}
+	if i10, i13, err := NormalizeISBN(isbn10); err == nil {
+		return i10, i13
+	}
+	return "", ""
+}
+
+func isbn13Check(body12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body12[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
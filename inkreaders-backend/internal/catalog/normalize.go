@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CleanTitle tidies a title for display: surrounding space and quotes
+// trimmed, inner whitespace collapsed.
+func CleanTitle(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, `"'“”‘’ `)
+}
+
+// CleanAuthors tidies author names for display: whitespace collapsed,
+// "Last, First" turned into "First Last", blanks and repeats dropped.
+func CleanAuthors(authors []string) []string {
+	out := make([]string, 0, len(authors))
+	seen := map[string]bool{}
+	for _, a := range authors {
+		a = strings.Join(strings.Fields(a), " ")
+		if last, first, ok := strings.Cut(a, ", "); ok && !strings.Contains(first, ",") {
+			a = first + " " + last
+		}
+		k := AuthorKey(a)
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+// TitleKey is the form titles are matched on: case, accents, punctuation,
+// a leading article and any subtitle after ':' are ignored.
+func TitleKey(s string) string {
+	s = foldKey(s)
+	if i := strings.Index(s, ":"); i > 0 {
+		s = s[:i]
+	}
+	s = keepWords(s)
+	for _, art := range []string{"the ", "a ", "an "} {
+		if strings.HasPrefix(s, art) && len(s) > len(art) {
+			s = s[len(art):]
+			break
+		}
+	}
+	return s
+}
+
+// AuthorKey is the form a single author name is matched on. Initials are
+// kept, so "J. R. R. Tolkien" and "JRR Tolkien" still differ.
+func AuthorKey(s string) string {
+	return keepWords(foldKey(s))
+}
+
+// AuthorsKey joins the sorted author keys, so author order doesn't matter.
+func AuthorsKey(authors []string) string {
+	keys := make([]string, 0, len(authors))
+	for _, a := range CleanAuthors(authors) {
+		keys = append(keys, AuthorKey(a))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "|")
+}
+
+// foldKey lowercases and strips diacritics.
+func foldKey(s string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// keepWords keeps letters and digits and collapses everything else to single
+// spaces.
+func keepWords(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			sb.WriteRune(r)
+		} else {
+			space = true
+		}
+	}
+	return sb.String()
+}
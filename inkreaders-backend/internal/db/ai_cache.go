@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ------------------ AI result cache ------------------
+
+// GetAICache returns the unexpired value stored under key; ok is false on a
+// miss.
+func (s *Store) GetAICache(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	err = s.Pool.QueryRow(ctx, `
+		SELECT value FROM app.ai_cache WHERE key = $1 AND expires_at > now()
+	`, key).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	return value, err == nil, err
+}
+
+// PutAICache stores value under key for ttl, replacing any older entry.
+func (s *Store) PutAICache(ctx context.Context, key, op string, value []byte, ttl time.Duration) error {
+	_, err := s.Pool.Exec(ctx, `
+		INSERT INTO app.ai_cache (key, op, value, expires_at)
+		VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+		ON CONFLICT (key) DO UPDATE
+		SET op = EXCLUDED.op, value = EXCLUDED.value,
+		    created_at = now(), expires_at = EXCLUDED.expires_at
+	`, key, op, value, ttl.Seconds())
+	return err
+}
+
+// PurgeAICache deletes expired entries and returns how many.
+func (s *Store) PurgeAICache(ctx context.Context) (int64, error) {
+	tag, err := s.Pool.Exec(ctx, `DELETE FROM app.ai_cache WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
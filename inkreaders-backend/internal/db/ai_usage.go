@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ------------------ AI usage metering ------------------
+
+type AIUsage struct {
+	UserID           *uuid.UUID
+	Op               string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+}
+
+func (s *Store) RecordAIUsage(ctx context.Context, u AIUsage) error {
+	_, err := s.Pool.Exec(ctx, `
+		INSERT INTO app.ai_usage (user_id, op, model, prompt_tokens, completion_tokens, cost_usd)
+		VALUES ($1, $2, $3, $4, $5, $6::float8)
+	`, u.UserID, u.Op, u.Model, u.PromptTokens, u.CompletionTokens, u.CostUSD)
+	return err
+}
+
+// AIUsageTotals is a sum of metered calls.
+type AIUsageTotals struct {
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Tokens           int64   `json:"tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+const aiUsageSums = `
+	count(*),
+	COALESCE(sum(prompt_tokens), 0),
+	COALESCE(sum(completion_tokens), 0),
+	COALESCE(sum(prompt_tokens + completion_tokens), 0),
+	COALESCE(sum(cost_usd), 0)::float8
+`
+
+func (t *AIUsageTotals) scanArgs() []any {
+	return []any{&t.Calls, &t.PromptTokens, &t.CompletionTokens, &t.Tokens, &t.CostUSD}
+}
+
+// AIUsageDayMonth sums a user's usage over the UTC day and month containing
+// now, in one pass over the month's rows.
+func (s *Store) AIUsageDayMonth(ctx context.Context, userID uuid.UUID, now time.Time) (day, month int64, err error) {
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	err = s.Pool.QueryRow(ctx, `
+		SELECT COALESCE(sum(prompt_tokens + completion_tokens) FILTER (WHERE created_at >= $2), 0),
+		       COALESCE(sum(prompt_tokens + completion_tokens), 0)
+		FROM app.ai_usage
+		WHERE user_id = $1 AND created_at >= $3
+	`, userID, dayStart, monthStart).Scan(&day, &month)
+	return day, month, err
+}
+
+type AIUsageGroup struct {
+	Key string `json:"key"`
+	AIUsageTotals
+}
+
+type AIUsageUser struct {
+	UserID *uuid.UUID `json:"user_id"` // null: calls billed to no one
+	Email  *string    `json:"email,omitempty"`
+	Name   *string    `json:"name,omitempty"`
+	AIUsageTotals
+}
+
+type AIUsageSummary struct {
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Totals  AIUsageTotals  `json:"totals"`
+	ByOp    []AIUsageGroup `json:"by_op"`
+	ByModel []AIUsageGroup `json:"by_model"`
+	ByDay   []AIUsageGroup `json:"by_day"`
+	// TopUsers are the heaviest users by cost, at most topUsers of them.
+	TopUsers []AIUsageUser `json:"top_users"`
+}
+
+// SummarizeAIUsage totals usage in [from, to) overall, per operation, model
+// and UTC day, and for the topUsers most expensive users.
+func (s *Store) SummarizeAIUsage(ctx context.Context, from, to time.Time, topUsers int) (AIUsageSummary, error) {
+	out := AIUsageSummary{From: from, To: to, ByOp: []AIUsageGroup{}, ByModel: []AIUsageGroup{}, ByDay: []AIUsageGroup{}, TopUsers: []AIUsageUser{}}
+	if err := s.Pool.QueryRow(ctx, `SELECT `+aiUsageSums+` FROM app.ai_usage WHERE created_at >= $1 AND created_at < $2`,
+		from, to).Scan(out.Totals.scanArgs()...); err != nil {
+		return out, err
+	}
+
+	groups := []struct {
+		expr string
+		dst  *[]AIUsageGroup
+	}{
+		{"op", &out.ByOp},
+		{"model", &out.ByModel},
+		{"to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')", &out.ByDay},
+	}
+	for _, g := range groups {
+		rows, err := s.Pool.Query(ctx, `
+			SELECT `+g.expr+` AS key, `+aiUsageSums+`
+			FROM app.ai_usage
+			WHERE created_at >= $1 AND created_at < $2
+			GROUP BY 1
+			ORDER BY 1
+		`, from, to)
+		if err != nil {
+			return out, err
+		}
+		for rows.Next() {
+			var row AIUsageGroup
+			if err := rows.Scan(append([]any{&row.Key}, row.scanArgs()...)...); err != nil {
+				rows.Close()
+				return out, err
+			}
+			*g.dst = append(*g.dst, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return out, err
+		}
+	}
+
+	rows, err := s.Pool.Query(ctx, `
+		SELECT u.user_id, us.email, us.name, u.calls, u.prompt_tokens, u.completion_tokens, u.tokens, u.cost_usd
+		FROM (
+			SELECT user_id, `+aiUsageSums+`
+			FROM app.ai_usage
+			WHERE created_at >= $1 AND created_at < $2
+			GROUP BY user_id
+		) AS u (user_id, calls, prompt_tokens, completion_tokens, tokens, cost_usd)
+		LEFT JOIN app.users us ON us.id = u.user_id
+		ORDER BY u.cost_usd DESC, u.tokens DESC
+		LIMIT $3
+	`, from, to, topUsers)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row AIUsageUser
+		if err := rows.Scan(append([]any{&row.UserID, &row.Email, &row.Name}, row.scanArgs()...)...); err != nil {
+			return out, err
+		}
+		out.TopUsers = append(out.TopUsers, row)
+	}
+	return out, rows.Err()
+}
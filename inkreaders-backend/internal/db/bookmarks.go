@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ------------------ Bookmarks ------------------
+
+type Bookmark struct {
+	PostURI   string    `json:"post_uri"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddBookmark is idempotent; it returns the existing bookmark's time if the
+// post was already saved.
+func (s *Store) AddBookmark(ctx context.Context, userID uuid.UUID, postURI string) (Bookmark, error) {
+	b := Bookmark{PostURI: postURI}
+	err := s.Pool.QueryRow(ctx, `
+		INSERT INTO app.bookmarks (user_id, post_uri) VALUES ($1, $2)
+		ON CONFLICT (user_id, post_uri) DO UPDATE SET post_uri = EXCLUDED.post_uri
+		RETURNING created_at
+	`, userID, postURI).Scan(&b.CreatedAt)
+	return b, err
+}
+
+// DeleteBookmark reports whether a bookmark was removed.
+func (s *Store) DeleteBookmark(ctx context.Context, userID uuid.UUID, postURI string) (bool, error) {
+	ct, err := s.Pool.Exec(ctx, `DELETE FROM app.bookmarks WHERE user_id = $1 AND post_uri = $2`, userID, postURI)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+// ListBookmarks returns a user's bookmarks, newest first. The cursor is an
+// offset, as in ListTopics.
+func (s *Store) ListBookmarks(ctx context.Context, userID uuid.UUID, limit int, cursor string) ([]Bookmark, string, error) {
+	offset := 0
+	if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+		offset = n
+	}
+	rows, err := s.Pool.Query(ctx, `
+		SELECT post_uri, created_at
+		FROM app.bookmarks
+		WHERE user_id = $1
+		ORDER BY created_at DESC, post_uri
+		LIMIT $2 OFFSET $3
+	`, userID, limit+1, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []Bookmark{}
+	for rows.Next() {
+		var b Bookmark
+		if err := rows.Scan(&b.PostURI, &b.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/catalog"
+)
+
+// ------------------ Books (catalog) ------------------
+
+type Book struct {
+	ID        int64     `json:"id"`
+	ISBN10    *string   `json:"isbn10,omitempty"`
+	ISBN13    *string   `json:"isbn13,omitempty"`
+	Title     string    `json:"title"`
+	Authors   []string  `json:"authors"`
+	Link      *string   `json:"link,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Filled in by the book.enrich job.
+	CoverURL    *string  `json:"cover_url,omitempty"`
+	Publisher   *string  `json:"publisher,omitempty"`
+	PageCount   *int     `json:"page_count,omitempty"`
+	Subjects    []string `json:"subjects,omitempty"`
+	PublishYear *int     `json:"publish_year,omitempty"`
+
+	// Aggregates over live com.inkreaders.book.post rows.
+	AvgRating   *float64 `json:"avg_rating,omitempty"`
+	RatingCount int64    `json:"rating_count"`
+	ReviewCount int64    `json:"review_count"`
+}
+
+const bookSelect = `
+	SELECT b.id, b.isbn10, b.isbn13, b.title, b.authors, b.link, b.created_at,
+	       b.cover_url, b.publisher, b.page_count, b.subjects, b.publish_year,
+	       st.avg_rating, COALESCE(st.rating_count, 0), COALESCE(st.review_count, 0)
+	FROM app.books b
+	LEFT JOIN LATERAL (
+		SELECT avg(p.rating)::float8 AS avg_rating,
+		       count(p.rating)      AS rating_count,
+		       count(*)             AS review_count
+		FROM app.posts p
+		WHERE p.book_id = b.id
+		  AND p.collection = 'com.inkreaders.book.post'
+		  AND p.deleted_at IS NULL
+	) st ON true
+`
+
+func scanBook(row pgx.Row) (Book, error) {
+	var b Book
+	err := row.Scan(&b.ID, &b.ISBN10, &b.ISBN13, &b.Title, &b.Authors, &b.Link, &b.CreatedAt,
+		&b.CoverURL, &b.Publisher, &b.PageCount, &b.Subjects, &b.PublishYear,
+		&b.AvgRating, &b.RatingCount, &b.ReviewCount)
+	return b, err
+}
+
+func (s *Store) GetBook(ctx context.Context, id int64) (Book, error) {
+	return scanBook(s.Pool.QueryRow(ctx, bookSelect+` WHERE b.id = $1`, id))
+}
+
+// GetBookByISBN looks a book up by either ISBN form; isbn13 must already be
+// normalised (see catalog.NormalizeISBN).
+func (s *Store) GetBookByISBN(ctx context.Context, isbn10, isbn13 string) (Book, error) {
+	return scanBook(s.Pool.QueryRow(ctx, bookSelect+`
+		WHERE b.isbn13 = $1 OR (b.isbn10 = $2 AND $2 <> '')
+		ORDER BY (b.isbn13 = $1) DESC NULLS LAST, b.id
+		LIMIT 1
+	`, isbn13, isbn10))
+}
+
+// BookMetadata is what an enrichment source knows about an edition.
+type BookMetadata struct {
+	CoverURL    string
+	Publisher   string
+	PageCount   int
+	Subjects    []string
+	PublishYear int
+}
+
+// BookISBNs identifies a book to an enrichment source.
+type BookISBNs struct {
+	ID     int64
+	ISBN10 string
+	ISBN13 string
+}
+
+// BooksToEnrich returns books with an ISBN-13 that were never enriched, or
+// whose last attempt found no cover and is older than retryAfter.
+func (s *Store) BooksToEnrich(ctx context.Context, limit int, retryAfter time.Duration) ([]BookISBNs, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT id, COALESCE(isbn10, ''), isbn13
+		FROM app.books
+		WHERE isbn13 IS NOT NULL
+		  AND (enriched_at IS NULL OR (cover_url IS NULL AND enriched_at < now() - make_interval(secs => $2)))
+		ORDER BY enriched_at NULLS FIRST, id
+		LIMIT $1
+	`, limit, retryAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []BookISBNs
+	for rows.Next() {
+		var b BookISBNs
+		if err := rows.Scan(&b.ID, &b.ISBN10, &b.ISBN13); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// SetBookMetadata stores what source found for a book; m == nil records a
+// miss. Fields the source left empty keep their current value.
+func (s *Store) SetBookMetadata(ctx context.Context, id int64, source string, m *BookMetadata) error {
+	if m == nil {
+		_, err := s.Pool.Exec(ctx, `
+			UPDATE app.books SET enrich_source = $2, enriched_at = now() WHERE id = $1
+		`, id, source)
+		return err
+	}
+	if m.Subjects == nil {
+		m.Subjects = []string{}
+	}
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.books SET
+			cover_url    = COALESCE(NULLIF($2, ''), cover_url),
+			publisher    = COALESCE(NULLIF($3, ''), publisher),
+			page_count   = COALESCE(NULLIF($4, 0), page_count),
+			subjects     = CASE WHEN cardinality($5::text[]) > 0 THEN $5 ELSE subjects END,
+			publish_year = COALESCE(NULLIF($6, 0), publish_year),
+			enrich_source = $7,
+			enriched_at  = now()
+		WHERE id = $1
+	`, id, m.CoverURL, m.Publisher, m.PageCount, m.Subjects, m.PublishYear, source)
+	return err
+}
+
+// bookRefs lists the columns that point at books.id; merging re-points all
+// of them before deleting the duplicate rows.
+var bookRefs = []struct{ table, column string }{
+	{"app.posts", "book_id"},
+	{"app.shelves", "book_id"},
+	{"app.reading_progress", "book_id"},
+	{"app.events", "book_id"},
+}
+
+type bookRow struct {
+	id                int64
+	isbn10, isbn13    string
+	link              string
+	titleKey, authKey string
+}
+
+// MergeDuplicateBooks backfills ISBNs and match keys on every books row and
+// folds rows that describe the same book into the oldest one: rows sharing an
+// ISBN-13 (after converting ISBN-10s), and rows without an ISBN whose title
+// and authors match exactly one ISBN group or each other. It returns how many
+// rows were removed.
+func (s *Store) MergeDuplicateBooks(ctx context.Context) (int, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT id, COALESCE(isbn10, ''), COALESCE(isbn13, ''), title, authors, COALESCE(link, '')
+		FROM app.books ORDER BY id
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var all []bookRow
+	for rows.Next() {
+		var (
+			r       bookRow
+			title   string
+			authors []string
+		)
+		if err := rows.Scan(&r.id, &r.isbn10, &r.isbn13, &title, &authors, &r.link); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		r.isbn10, r.isbn13 = catalog.ResolveISBNs(r.isbn10, r.isbn13)
+		r.titleKey, r.authKey = catalog.TitleKey(title), catalog.AuthorsKey(authors)
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Group by ISBN-13 first, then attach ISBN-less rows by title/authors.
+	groups := map[string][]bookRow{}
+	var order []string
+	add := func(key string, r bookRow) {
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+	isbnByTitle := map[string]map[string]bool{}
+	for _, r := range all {
+		if r.isbn13 == "" {
+			continue
+		}
+		add("isbn:"+r.isbn13, r)
+		tk := r.titleKey + "|" + r.authKey
+		if isbnByTitle[tk] == nil {
+			isbnByTitle[tk] = map[string]bool{}
+		}
+		isbnByTitle[tk][r.isbn13] = true
+	}
+	for _, r := range all {
+		if r.isbn13 != "" {
+			continue
+		}
+		tk := r.titleKey + "|" + r.authKey
+		if isbns := isbnByTitle[tk]; len(isbns) == 1 {
+			for isbn := range isbns {
+				add("isbn:"+isbn, r)
+			}
+			continue
+		}
+		add("title:"+tk, r)
+	}
+
+	merged := 0
+	for _, key := range order {
+		n, err := s.mergeBookGroup(ctx, groups[key])
+		if err != nil {
+			return merged, fmt.Errorf("merge %s: %w", key, err)
+		}
+		merged += n
+	}
+	return merged, nil
+}
+
+func (s *Store) mergeBookGroup(ctx context.Context, group []bookRow) (int, error) {
+	keep := group[0]
+	for _, r := range group[1:] {
+		if r.id < keep.id {
+			keep = r
+		}
+	}
+	var dupes []int64
+	for _, r := range group {
+		if r.id == keep.id {
+			continue
+		}
+		dupes = append(dupes, r.id)
+		if keep.isbn13 == "" {
+			keep.isbn10, keep.isbn13 = r.isbn10, r.isbn13
+		}
+		if keep.link == "" {
+			keep.link = r.link
+		}
+	}
+
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(dupes) > 0 {
+		for _, ref := range bookRefs {
+			q := `UPDATE ` + ref.table + ` SET ` + ref.column + ` = $1 WHERE ` + ref.column + ` = ANY($2)`
+			if _, err := tx.Exec(ctx, q, keep.id, dupes); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM app.books WHERE id = ANY($1)`, dupes); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE app.books SET
+			isbn10 = NULLIF($2, ''), isbn13 = NULLIF($3, ''),
+			link = NULLIF($4, ''), title_key = $5, authors_key = $6
+		WHERE id = $1
+	`, keep.id, keep.isbn10, keep.isbn13, keep.link, keep.titleKey, keep.authKey); err != nil {
+		return 0, err
+	}
+	return len(dupes), tx.Commit(ctx)
+}
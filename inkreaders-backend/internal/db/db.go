@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/catalog"
 )
 
 type Store struct {
@@ -41,37 +44,68 @@ func Open(ctx context.Context, dsn string) (*Store, error) {
 
 func (s *Store) Close() { s.Pool.Close() }
 
+// UpsertBook finds or creates the catalog row for a posted book. ISBNs are
+// validated and completed (an ISBN-10 also finds the ISBN-13 row), and
+// without an ISBN the match is on normalised title and authors. An existing
+// row keeps its title and authors; missing ISBNs and link are filled in.
 func (s *Store) UpsertBook(ctx context.Context, title string, authors []string, isbn10, isbn13, link string) (int64, error) {
+	title = catalog.CleanTitle(title)
+	authors = catalog.CleanAuthors(authors)
+	isbn10, isbn13 = catalog.ResolveISBNs(isbn10, isbn13)
+	titleKey, authorsKey := catalog.TitleKey(title), catalog.AuthorsKey(authors)
+
+	id, err := s.findBookID(ctx, titleKey, authorsKey, title, authors, isbn10, isbn13)
+	switch {
+	case err == nil:
+		_, err = s.Pool.Exec(ctx, `
+			UPDATE app.books SET
+				isbn13 = COALESCE(isbn13, NULLIF($2, '')),
+				isbn10 = COALESCE(isbn10, NULLIF($3, '')),
+				link = COALESCE(NULLIF(link, ''), NULLIF($4, '')),
+				title_key = COALESCE(title_key, $5),
+				authors_key = COALESCE(authors_key, $6)
+			WHERE id = $1
+		`, id, isbn13, isbn10, link, titleKey, authorsKey)
+		return id, err
+	case !errors.Is(err, pgx.ErrNoRows):
+		return 0, err
+	}
+
+	err = s.Pool.QueryRow(ctx, `
+		INSERT INTO app.books (title, authors, isbn10, isbn13, link, title_key, authors_key)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+		ON CONFLICT (isbn13) DO UPDATE SET isbn13 = EXCLUDED.isbn13
+		RETURNING id
+	`, title, authors, isbn10, isbn13, link, titleKey, authorsKey).Scan(&id)
+	return id, err
+}
+
+// FindBookID returns the catalog row a typed book matches, by the same rules
+// as UpsertBook, without creating one. pgx.ErrNoRows means no match.
+func (s *Store) FindBookID(ctx context.Context, title string, authors []string, isbn10, isbn13 string) (int64, error) {
+	title = catalog.CleanTitle(title)
+	authors = catalog.CleanAuthors(authors)
+	isbn10, isbn13 = catalog.ResolveISBNs(isbn10, isbn13)
+	return s.findBookID(ctx, catalog.TitleKey(title), catalog.AuthorsKey(authors), title, authors, isbn10, isbn13)
+}
+
+func (s *Store) findBookID(ctx context.Context, titleKey, authorsKey, title string, authors []string, isbn10, isbn13 string) (int64, error) {
 	var id int64
-	// prefer isbn13 as a unique key if present
 	if isbn13 != "" {
 		err := s.Pool.QueryRow(ctx, `
-			INSERT INTO app.books (title, authors, isbn10, isbn13, link)
-			VALUES ($1,$2,$3,$4,$5)
-			ON CONFLICT (isbn13) DO UPDATE SET
-				title = EXCLUDED.title,
-				authors = EXCLUDED.authors,
-				isbn10 = EXCLUDED.isbn10,
-				link = EXCLUDED.link
-			RETURNING id
-		`, title, authors, isbn10, isbn13, link).Scan(&id)
+			SELECT id FROM app.books
+			WHERE isbn13 = $1 OR (isbn10 = $2 AND $2 <> '')
+			ORDER BY (isbn13 = $1) DESC NULLS LAST, id
+			LIMIT 1
+		`, isbn13, isbn10).Scan(&id)
 		return id, err
 	}
-
-	// no isbn13: try to find by (title,authors) first
 	err := s.Pool.QueryRow(ctx, `
-		SELECT id FROM app.books WHERE title=$1 AND authors=$2 LIMIT 1
-	`, title, authors).Scan(&id)
-	if err == nil {
-		return id, nil
-	}
-
-	// insert new row
-	err = s.Pool.QueryRow(ctx, `
-		INSERT INTO app.books (title, authors, isbn10, link)
-		VALUES ($1,$2,$3,$4)
-		RETURNING id
-	`, title, authors, isbn10, link).Scan(&id)
+		SELECT id FROM app.books
+		WHERE (title_key = $1 AND authors_key = $2) OR (title = $3 AND authors = $4)
+		ORDER BY (isbn13 IS NOT NULL) DESC, id
+		LIMIT 1
+	`, titleKey, authorsKey, title, authors).Scan(&id)
 	return id, err
 }
 
@@ -82,7 +116,9 @@ func (s *Store) UpsertBookPost(ctx context.Context, uri, cid, did string, create
 		INSERT INTO app.posts (uri, cid, did, collection, created_at, text, rating, progress, book_id)
 		VALUES ($1,$2,$3,'com.inkreaders.book.post',$4,$5,$6,$7,$8)
 		ON CONFLICT (uri) DO UPDATE SET
-			cid=$2, did=$3, created_at=$4, text=$5, rating=$6, progress=$7, book_id=$8
+			cid=$2, did=$3, created_at=$4, text=$5, rating=$6, progress=$7, book_id=$8,
+			revision=posts.revision+1, updated_at=now(), deleted_at=NULL
+		WHERE posts.cid IS DISTINCT FROM EXCLUDED.cid
 	`, uri, cid, did, createdAt, text, rating, progress, bookID)
 	return err
 }
@@ -92,11 +128,45 @@ func (s *Store) UpsertArticlePost(ctx context.Context, uri, cid, did string, cre
 		INSERT INTO app.posts (uri, cid, did, collection, created_at, text, article_url, article_title, article_source)
 		VALUES ($1,$2,$3,'com.inkreaders.article.post',$4,$5,$6,$7,$8)
 		ON CONFLICT (uri) DO UPDATE SET
-			cid=$2, did=$3, created_at=$4, text=$5, article_url=$6, article_title=$7, article_source=$8
+			cid=$2, did=$3, created_at=$4, text=$5, article_url=$6, article_title=$7, article_source=$8,
+			revision=posts.revision+1, updated_at=now(), deleted_at=NULL
+		WHERE posts.cid IS DISTINCT FROM EXCLUDED.cid
 	`, uri, cid, did, createdAt, text, url, title, source)
 	return err
 }
 
+// DeletePost tombstones an indexed post whose record was deleted upstream.
+func (s *Store) DeletePost(ctx context.Context, uri string) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.posts SET deleted_at=now(), updated_at=now()
+		WHERE uri=$1 AND deleted_at IS NULL
+	`, uri)
+	return err
+}
+
+// LivePostCIDs returns uri -> cid for the non-deleted posts indexed from one
+// repo and collection, for reconciling against listRecords.
+func (s *Store) LivePostCIDs(ctx context.Context, did, collection string) (map[string]string, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT uri, cid FROM app.posts
+		WHERE did=$1 AND collection=$2 AND deleted_at IS NULL
+	`, did, collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]string{}
+	for rows.Next() {
+		var uri, cid string
+		if err := rows.Scan(&uri, &cid); err != nil {
+			return nil, err
+		}
+		out[uri] = cid
+	}
+	return out, rows.Err()
+}
+
 func (s *Store) GetCursor(ctx context.Context, name string) (string, error) {
 	var v string
 	err := s.Pool.QueryRow(ctx, `SELECT value FROM app.cursors WHERE name=$1`, name).Scan(&v)
@@ -104,16 +174,11 @@ func (s *Store) GetCursor(ctx context.Context, name string) (string, error) {
 }
 
 func (s *Store) SetCursor(ctx context.Context, name, value string) error {
-	ct, err := s.Pool.Exec(ctx, `
-		UPDATE cursors SET value=$2 WHERE name=$1
+	_, err := s.Pool.Exec(ctx, `
+		INSERT INTO app.cursors (name, value) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value
 	`, name, value)
-	if err != nil {
-		return err
-	}
-	if ct.RowsAffected() == 0 {
-		return errors.New("cursor row missing")
-	}
-	return nil
+	return err
 }
 
 // Simple trending: count book posts last 24h, group by book
@@ -131,6 +196,7 @@ func (s *Store) TrendingBooksLast24h(ctx context.Context, limit int32) ([]Trendi
 		FROM app.posts p
 		JOIN app.books b ON p.book_id = b.id
 		WHERE p.collection = 'com.inkreaders.book.post'
+		  AND p.deleted_at IS NULL
 		  AND p.created_at >= NOW() - INTERVAL '24 hours'
 		GROUP BY b.id, b.title, b.authors, b.link
 		ORDER BY cnt DESC, b.title ASC
@@ -152,6 +218,73 @@ func (s *Store) TrendingBooksLast24h(ctx context.Context, limit int32) ([]Trendi
 	return out, rows.Err()
 }
 
+// TrendingWindows are the spans the book_trending rollup is computed for.
+var TrendingWindows = map[string]bool{"24h": true, "7d": true, "30d": true}
+
+type ScoredBook struct {
+	BookID          int64    `json:"bookId"`
+	Title           string   `json:"title"`
+	Authors         []string `json:"authors"`
+	Link            *string  `json:"link,omitempty"`
+	Score           float64  `json:"score"`
+	Posts           int64    `json:"posts"`
+	Reviewers       int64    `json:"reviewers"`
+	AvgRating       *float32 `json:"avgRating,omitempty"`
+	ProgressUpdates int64    `json:"progressUpdates"`
+}
+
+// TrendingBooks reads one window of the book_trending rollup, highest score
+// first. The cursor is an offset, as in ListTopics.
+func (s *Store) TrendingBooks(ctx context.Context, window string, limit int, cursor string) ([]ScoredBook, string, error) {
+	offset := 0
+	if cursor != "" {
+		if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+			offset = n
+		}
+	}
+
+	rows, err := s.Pool.Query(ctx, `
+		SELECT b.id, b.title, b.authors, b.link,
+		       t.score, t.posts, t.reviewers, t.avg_rating, t.progress_updates
+		FROM app.book_trending t
+		JOIN app.books b ON b.id = t.book_id
+		WHERE t.span = $1
+		ORDER BY t.score DESC, b.id
+		LIMIT $2 OFFSET $3
+	`, window, limit+1, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var out []ScoredBook
+	for rows.Next() {
+		var b ScoredBook
+		if err := rows.Scan(&b.BookID, &b.Title, &b.Authors, &b.Link,
+			&b.Score, &b.Posts, &b.Reviewers, &b.AvgRating, &b.ProgressUpdates); err != nil {
+			return nil, "", err
+		}
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
+
+// RefreshTrendingBooks recomputes the book_trending rollup without blocking
+// readers.
+func (s *Store) RefreshTrendingBooks(ctx context.Context) error {
+	_, err := s.Pool.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY app.book_trending`)
+	return err
+}
+
 // SessionRow represents a row from the sessions table
 type SessionRow struct {
 	SessionToken string
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Embeddings & semantic search ---
+
+// EmbedItem is a response waiting for an embedding. ContentMD5 guards the
+// write: the vector is only stored if the content hasn't changed since.
+type EmbedItem struct {
+	ID         uuid.UUID
+	Content    string
+	ContentMD5 string
+}
+
+// embeddable selects rows with no vector from the current model ($3),
+// skipping failed rows and AI placeholders still being generated.
+const embeddable = `
+	(embedding IS NULL OR embedding_model IS DISTINCT FROM $3)
+	AND coalesce(content, '') <> ''
+	AND coalesce(status, '') <> 'failed'
+	AND NOT (author_type = 'ai' AND status = 'pending')
+`
+
+// ResponsesToEmbed returns responses without an embedding from model,
+// including ones embedded by an earlier model, oldest edit first. With ids
+// set only those rows are considered.
+func (s *Store) ResponsesToEmbed(ctx context.Context, ids []uuid.UUID, model string, limit int) ([]EmbedItem, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT id, content FROM responses
+		WHERE `+embeddable+`
+		  AND (cardinality($1::uuid[]) = 0 OR id = ANY($1))
+		ORDER BY embedding IS NOT NULL, updated_at
+		LIMIT $2
+	`, ids, limit, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EmbedItem
+	for rows.Next() {
+		var it EmbedItem
+		if err := rows.Scan(&it.ID, &it.Content); err != nil {
+			return nil, err
+		}
+		sum := md5.Sum([]byte(it.Content))
+		it.ContentMD5 = hex.EncodeToString(sum[:])
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// SetResponseEmbedding stores vec unless the content was edited after it
+// was read (the clear trigger would then have queued it again).
+func (s *Store) SetResponseEmbedding(ctx context.Context, it EmbedItem, vec []float32, model string) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE responses
+		SET embedding = $2::vector, embedding_model = $3, embedded_at = now()
+		WHERE id = $1 AND md5(coalesce(content, '')) = $4
+	`, it.ID, vectorLiteral(vec), model, it.ContentMD5)
+	return err
+}
+
+type SemanticHit struct {
+	ResponseID uuid.UUID `json:"responseId"`
+	TopicID    uuid.UUID `json:"topicId"`
+	TopicTitle string    `json:"topicTitle"`
+	// Snippet is HTML, as in SearchHit; rows matched only by meaning get
+	// the escaped start of the response.
+	Snippet    string    `json:"snippet"`
+	Similarity float64   `json:"similarity"`
+	TextRank   float64   `json:"textRank"`
+	Score      float64   `json:"score"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// semanticPool is how many nearest neighbours and how many text matches
+// are pulled in as candidates before blending.
+const semanticPool = 100
+
+// SemanticSearch ranks the owner's responses by
+// weight*cosine similarity + (1-weight)*normalised ts_rank_cd. Candidates
+// are the nearest vectors plus the best text matches, so exact keyword hits
+// without an embedding yet still show up. Only vectors from model are
+// compared with vec; rows still on another model rank by text alone until
+// the sweep re-embeds them. The cursor is an offset.
+func (s *Store) SemanticSearch(ctx context.Context, owner uuid.UUID, vec []float32, model, query string, weight float64, limit int, cursor string) ([]SemanticHit, string, error) {
+	offset := 0
+	if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+		offset = n
+	}
+	rows, err := s.Pool.Query(ctx, `
+		WITH q AS (SELECT $2::vector AS vec, websearch_to_tsquery('english', $3) AS query),
+		cand AS (
+			(SELECT r.id FROM responses r JOIN topics t ON t.id = r.topic_id, q
+			 WHERE t.user_id = $1 AND r.embedding IS NOT NULL AND r.embedding_model = $9
+			 ORDER BY r.embedding <=> q.vec
+			 LIMIT $5)
+			UNION
+			(SELECT r.id FROM responses r JOIN topics t ON t.id = r.topic_id, q
+			 WHERE t.user_id = $1 AND r.search_vector @@ q.query
+			 ORDER BY ts_rank_cd(r.search_vector, q.query) DESC
+			 LIMIT $5)
+		),
+		scored AS (
+			SELECT r.id, r.topic_id, t.title, r.content, r.updated_at,
+			       r.search_vector @@ q.query AS matched,
+			       CASE WHEN r.embedding_model = $9 THEN coalesce(1 - (r.embedding <=> q.vec), 0) ELSE 0 END::float8 AS similarity,
+			       coalesce(ts_rank_cd(r.search_vector, q.query, 32), 0)::float8 AS text_rank
+			FROM cand c
+			JOIN responses r ON r.id = c.id
+			JOIN topics t ON t.id = r.topic_id, q
+		),
+		page AS (
+			SELECT *, $4::float8 * similarity + (1 - $4::float8) * text_rank AS score
+			FROM scored
+			ORDER BY score DESC, updated_at DESC, id
+			LIMIT $6 OFFSET $7
+		)
+		SELECT p.id, p.topic_id, p.title,
+		       CASE WHEN p.matched THEN ts_headline('english', coalesce(p.content, ''), q.query, $8)
+		            ELSE left(coalesce(p.content, ''), 240) END,
+		       p.similarity, p.text_rank, p.score, coalesce(p.updated_at, now())
+		FROM page p, q
+		ORDER BY p.score DESC, p.updated_at DESC, p.id
+	`, owner, vectorLiteral(vec), query, weight, semanticPool, limit+1, offset, headlineOpts, model)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []SemanticHit{}
+	for rows.Next() {
+		var h SemanticHit
+		if err := rows.Scan(&h.ResponseID, &h.TopicID, &h.TopicTitle, &h.Snippet,
+			&h.Similarity, &h.TextRank, &h.Score, &h.UpdatedAt); err != nil {
+			return nil, "", err
+		}
+		h.Snippet = snippetHTML(h.Snippet)
+		out = append(out, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
+
+// vectorLiteral formats vec as pgvector text input, e.g. "[0.1,-0.2]".
+func vectorLiteral(vec []float32) string {
+	var sb strings.Builder
+	sb.Grow(len(vec) * 10)
+	sb.WriteByte('[')
+	for i, v := range vec {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ------------------ Events (book club) ------------------
+
+const (
+	RSVPGoing    = "going"
+	RSVPMaybe    = "maybe"
+	RSVPDeclined = "declined"
+)
+
+type Event struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Description *string    `json:"description,omitempty"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Location    *string    `json:"location,omitempty"`
+	URL         *string    `json:"url,omitempty"`
+	// CreatedBy is the creator's user id; the column predates this API
+	// and is text.
+	CreatedBy   string     `json:"created_by"`
+	BookID      *int64     `json:"book_id,omitempty"`
+	TopicID     *uuid.UUID `json:"topic_id,omitempty"`
+	AnnounceURI *string    `json:"announce_uri,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Filled for the viewing user.
+	Going  int     `json:"going"`
+	Maybe  int     `json:"maybe"`
+	MyRSVP *string `json:"my_rsvp,omitempty"`
+}
+
+const eventSelect = `
+	SELECT e.id, e.title, e.description, e.starts_at, e.ends_at, e.location, e.url,
+	       COALESCE(e.created_by, ''), e.book_id, e.topic_id, e.announce_uri, e.created_at, e.updated_at,
+	       (SELECT count(*) FROM app.event_rsvps r WHERE r.event_id = e.id AND r.status = 'going')::int,
+	       (SELECT count(*) FROM app.event_rsvps r WHERE r.event_id = e.id AND r.status = 'maybe')::int,
+	       (SELECT r.status FROM app.event_rsvps r WHERE r.event_id = e.id AND r.user_id = $1)
+	FROM app.events e
+`
+
+func scanEvent(row pgx.Row) (Event, error) {
+	var e Event
+	err := row.Scan(&e.ID, &e.Title, &e.Description, &e.StartsAt, &e.EndsAt, &e.Location, &e.URL,
+		&e.CreatedBy, &e.BookID, &e.TopicID, &e.AnnounceURI, &e.CreatedAt, &e.UpdatedAt,
+		&e.Going, &e.Maybe, &e.MyRSVP)
+	return e, err
+}
+
+func (s *Store) CreateEvent(ctx context.Context, e *Event) error {
+	return s.Pool.QueryRow(ctx, `
+		INSERT INTO app.events (title, description, starts_at, ends_at, location, url, created_by, book_id, topic_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`, e.Title, e.Description, e.StartsAt, e.EndsAt, e.Location, e.URL, e.CreatedBy, e.BookID, e.TopicID,
+	).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt)
+}
+
+// GetEvent loads an event with RSVP counts and the viewer's own RSVP.
+func (s *Store) GetEvent(ctx context.Context, id, viewer uuid.UUID) (Event, error) {
+	return scanEvent(s.Pool.QueryRow(ctx, eventSelect+` WHERE e.id = $2`, viewer, id))
+}
+
+// ListEvents lists events ending (or starting, if open-ended) at or after
+// from, soonest first. With mine set, only events the viewer created or
+// RSVP'd to. The cursor is an offset, as in ListTopics.
+func (s *Store) ListEvents(ctx context.Context, viewer uuid.UUID, from time.Time, mine bool, limit int, cursor string) ([]Event, string, error) {
+	offset := 0
+	if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+		offset = n
+	}
+	rows, err := s.Pool.Query(ctx, eventSelect+`
+		WHERE COALESCE(e.ends_at, e.starts_at) >= $2
+		  AND (NOT $3 OR e.created_by = $1::text
+		       OR EXISTS (SELECT 1 FROM app.event_rsvps r WHERE r.event_id = e.id AND r.user_id = $1))
+		ORDER BY e.starts_at, e.id
+		LIMIT $4 OFFSET $5
+	`, viewer, from, mine, limit+1, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
+
+// UpdateEvent writes the editable fields; only the creator matches.
+func (s *Store) UpdateEvent(ctx context.Context, e *Event) error {
+	ct, err := s.Pool.Exec(ctx, `
+		UPDATE app.events SET
+			title = $3, description = $4, starts_at = $5, ends_at = $6,
+			location = $7, url = $8, book_id = $9, topic_id = $10, announce_uri = $11,
+			updated_at = now()
+		WHERE id = $1 AND created_by = $2
+	`, e.ID, e.CreatedBy, e.Title, e.Description, e.StartsAt, e.EndsAt,
+		e.Location, e.URL, e.BookID, e.TopicID, e.AnnounceURI)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteEvent reports whether the creator's event was removed.
+func (s *Store) DeleteEvent(ctx context.Context, id, owner uuid.UUID) (bool, error) {
+	ct, err := s.Pool.Exec(ctx, `DELETE FROM app.events WHERE id = $1 AND created_by = $2::text`, id, owner)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+func (s *Store) SetRSVP(ctx context.Context, eventID, userID uuid.UUID, status string) error {
+	_, err := s.Pool.Exec(ctx, `
+		INSERT INTO app.event_rsvps (event_id, user_id, status) VALUES ($1, $2, $3)
+		ON CONFLICT (event_id, user_id) DO UPDATE SET status = EXCLUDED.status, updated_at = now()
+	`, eventID, userID, status)
+	return err
+}
+
+func (s *Store) DeleteRSVP(ctx context.Context, eventID, userID uuid.UUID) error {
+	_, err := s.Pool.Exec(ctx, `DELETE FROM app.event_rsvps WHERE event_id = $1 AND user_id = $2`, eventID, userID)
+	return err
+}
+
+type RSVP struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Handle    *string   `json:"handle,omitempty"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Store) ListRSVPs(ctx context.Context, eventID uuid.UUID) ([]RSVP, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT r.user_id, u.handle, r.status, r.updated_at
+		FROM app.event_rsvps r
+		LEFT JOIN app.users u ON u.id = r.user_id
+		WHERE r.event_id = $1
+		ORDER BY r.status, r.updated_at
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []RSVP{}
+	for rows.Next() {
+		var r RSVP
+		if err := rows.Scan(&r.UserID, &r.Handle, &r.Status, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// CalendarEvents returns the events for a user's .ics feed: ones they
+// created or are going / maybe going to, from since onwards.
+func (s *Store) CalendarEvents(ctx context.Context, userID uuid.UUID, since time.Time) ([]Event, error) {
+	rows, err := s.Pool.Query(ctx, eventSelect+`
+		WHERE COALESCE(e.ends_at, e.starts_at) >= $2
+		  AND (e.created_by = $1::text
+		       OR EXISTS (SELECT 1 FROM app.event_rsvps r
+		                  WHERE r.event_id = e.id AND r.user_id = $1 AND r.status IN ('going', 'maybe')))
+		ORDER BY e.starts_at
+		LIMIT 500
+	`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// CalendarToken returns the user's feed token, creating it on first use or
+// replacing it when rotate is set.
+func (s *Store) CalendarToken(ctx context.Context, userID uuid.UUID, rotate bool) (string, error) {
+	if !rotate {
+		var tok string
+		err := s.Pool.QueryRow(ctx, `SELECT token FROM app.calendar_tokens WHERE user_id = $1`, userID).Scan(&tok)
+		if err == nil {
+			return tok, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return "", err
+		}
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	tok := base64.RawURLEncoding.EncodeToString(b)
+	_, err := s.Pool.Exec(ctx, `
+		INSERT INTO app.calendar_tokens (user_id, token) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, created_at = now()
+	`, userID, tok)
+	return tok, err
+}
+
+func (s *Store) UserForCalendarToken(ctx context.Context, token string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := s.Pool.QueryRow(ctx, `SELECT user_id FROM app.calendar_tokens WHERE token = $1`, token).Scan(&id)
+	return id, err
+}
@@ -17,6 +17,9 @@ type ExerciseSource struct {
 	Type   string     `json:"type"`
 	Topic  string     `json:"topic,omitempty"`
 	FileID *uuid.UUID `json:"file_id,omitempty"`
+	// Page range the questions were drawn from (1-based, inclusive).
+	PageFrom int `json:"page_from,omitempty"`
+	PageTo   int `json:"page_to,omitempty"`
 }
 
 type ExerciseMeta struct {
@@ -26,6 +29,25 @@ type ExerciseMeta struct {
 	SeedSetID  *uuid.UUID     `json:"seed_set_id,omitempty"`
 }
 
+// Question types. A set's format is its one question type, or "mixed".
+const (
+	QuestionMCQ         = "mcq"
+	QuestionTrueFalse   = "true_false"
+	QuestionFillBlank   = "fill_blank"
+	QuestionMultiSelect = "multi_select" // Options; CorrectAnswer lists every right option
+	QuestionOrdering    = "ordering"     // Options shuffled; CorrectAnswer is them in order
+	QuestionMatching    = "matching"     // Pairs
+	QuestionShortAnswer = "short_answer" // Rubric
+	QuestionNumeric     = "numeric"      // Numeric
+	FormatMixed         = "mixed"
+)
+
+// QuestionTypes are all the types a question may have.
+var QuestionTypes = []string{
+	QuestionMCQ, QuestionTrueFalse, QuestionFillBlank, QuestionMultiSelect,
+	QuestionOrdering, QuestionMatching, QuestionShortAnswer, QuestionNumeric,
+}
+
 type Question struct {
 	ID            string      `json:"id"`
 	Type          string      `json:"type"`
@@ -34,6 +56,41 @@ type Question struct {
 	CorrectAnswer any         `json:"correct_answer"`
 	Explanation   string      `json:"explanation,omitempty"`
 	OrderIndex    int         `json:"order_index,omitempty"`
+
+	// Typed answers for the types that need more than CorrectAnswer, which
+	// still holds a plain copy (the pairs as an object, the model answer,
+	// the value) for clients that only know it.
+	Pairs   []MatchPair    `json:"pairs,omitempty"`
+	Rubric  *Rubric        `json:"rubric,omitempty"`
+	Numeric *NumericAnswer `json:"numeric,omitempty"`
+}
+
+// MatchPair is one correct left-to-right match. Clients show the rights
+// shuffled.
+type MatchPair struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// Rubric grades a short answer: each criterion is met when the answer
+// mentions any of its keywords, and the answer passes when the met share
+// reaches PassScore (default 0.6).
+type Rubric struct {
+	ModelAnswer string            `json:"model_answer"`
+	Criteria    []RubricCriterion `json:"criteria"`
+	PassScore   float64           `json:"pass_score,omitempty"`
+}
+
+type RubricCriterion struct {
+	Criterion string   `json:"criterion"`
+	Keywords  []string `json:"keywords"`
+}
+
+// NumericAnswer accepts answers within Tolerance of Value.
+type NumericAnswer struct {
+	Value     float64 `json:"value"`
+	Tolerance float64 `json:"tolerance,omitempty"`
+	Unit      string  `json:"unit,omitempty"`
 }
 
 type ExerciseSet struct {
@@ -214,3 +271,98 @@ func (s *Store) GetFile(ctx context.Context, id string, owner uuid.UUID) (File,
 	`, id, owner).Scan(&f.ID, &f.UserID, &f.Mime, &f.StorageKey, &f.Pages, &f.Chars)
 	return f, err
 }
+
+// ------------------ Attempts ------------------
+
+type QuestionResult struct {
+	QuestionID    string `json:"question_id"`
+	Answer        any    `json:"answer"`
+	Correct       bool   `json:"correct"`
+	CorrectAnswer any    `json:"correct_answer"`
+	// Score is the partial credit, 0..1, for matching and short answers.
+	Score *float64 `json:"score,omitempty"`
+}
+
+type ExerciseAttempt struct {
+	ID         uuid.UUID        `json:"id"`
+	ExerciseID string           `json:"exercise_id"`
+	UserID     uuid.UUID        `json:"user_id"`
+	Answers    map[string]any   `json:"answers"`
+	Results    []QuestionResult `json:"results"`
+	Correct    int              `json:"correct"`
+	Total      int              `json:"total"`
+	Score      float64          `json:"score"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// GetVisibleExerciseSet loads a set the viewer may attempt: their own sets,
+// plus anything that is not private.
+func (s *Store) GetVisibleExerciseSet(ctx context.Context, id string, viewer uuid.UUID) (*ExerciseSet, error) {
+	var e ExerciseSet
+	var qjson, mjson []byte
+	err := s.Pool.QueryRow(ctx, `
+		SELECT id, user_id, title, format, questions, meta, visibility,
+		       parent_set_id, at_uri, cid, feed_uri, created_at, updated_at
+		FROM app.exercise_sets
+		WHERE id=$1 AND (user_id=$2 OR visibility <> 'private')
+	`, id, viewer).Scan(
+		&e.ID, &e.UserID, &e.Title, &e.Format,
+		&qjson, &mjson, &e.Visibility,
+		&e.ParentSetID, &e.ATURI, &e.CID, &e.FeedURI, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(qjson, &e.Questions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(mjson, &e.Meta); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *Store) InsertExerciseAttempt(ctx context.Context, a *ExerciseAttempt) error {
+	return s.Pool.QueryRow(ctx, `
+		INSERT INTO app.exercise_attempts
+			(exercise_id, user_id, answers, results, correct, total, score)
+		VALUES ($1,$2,$3::jsonb,$4::jsonb,$5,$6,$7)
+		RETURNING id, created_at
+	`, a.ExerciseID, a.UserID, toJSON(a.Answers), toJSON(a.Results), a.Correct, a.Total, a.Score).
+		Scan(&a.ID, &a.CreatedAt)
+}
+
+func (s *Store) ListExerciseAttempts(ctx context.Context, exerciseID string, userID uuid.UUID, limit int) ([]ExerciseAttempt, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.Pool.Query(ctx, `
+		SELECT id, exercise_id, user_id, answers, results, correct, total, score, created_at
+		FROM app.exercise_attempts
+		WHERE exercise_id=$1 AND user_id=$2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, exerciseID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ExerciseAttempt
+	for rows.Next() {
+		var a ExerciseAttempt
+		var ajson, rjson []byte
+		var score *float64
+		if err := rows.Scan(&a.ID, &a.ExerciseID, &a.UserID, &ajson, &rjson,
+			&a.Correct, &a.Total, &score, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(ajson, &a.Answers)
+		_ = json.Unmarshal(rjson, &a.Results)
+		if score != nil {
+			a.Score = *score
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
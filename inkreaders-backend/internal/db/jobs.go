@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Job is a row of app.jobs, the durable background work queue.
+type Job struct {
+	ID          int64           `json:"id"`
+	JobType     string          `json:"job_type"`
+	Payload     json.RawMessage `json:"payload"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   *string         `json:"last_error,omitempty"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// EnqueueJob inserts a pending job that is runnable immediately.
+// maxAttempts <= 0 keeps the column default.
+func (s *Store) EnqueueJob(ctx context.Context, jobType string, payload any, maxAttempts int) (int64, error) {
+	var id int64
+	err := s.Pool.QueryRow(ctx, `
+		INSERT INTO app.jobs (job_type, payload, max_attempts)
+		VALUES ($1, $2::jsonb, COALESCE(NULLIF($3, 0), 5))
+		RETURNING id
+	`, jobType, toJSON(payload), maxAttempts).Scan(&id)
+	return id, err
+}
+
+// EnqueueJobIfIdle enqueues like EnqueueJob unless a job of the same type
+// and payload is already pending or running, for periodic sweeps that must
+// not pile up behind a slow run. queued is false when one was.
+func (s *Store) EnqueueJobIfIdle(ctx context.Context, jobType string, payload any, maxAttempts int) (id int64, queued bool, err error) {
+	err = s.Pool.QueryRow(ctx, `
+		INSERT INTO app.jobs (job_type, payload, max_attempts)
+		SELECT $1, $2::jsonb, COALESCE(NULLIF($3, 0), 5)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM app.jobs
+			WHERE job_type = $1 AND payload = $2::jsonb AND state IN ('pending', 'running')
+		)
+		RETURNING id
+	`, jobType, toJSON(payload), maxAttempts).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// ClaimJob locks the next runnable job of one of the given types, marks it
+// running and bumps its attempt count. Concurrent workers skip rows another
+// worker holds (FOR UPDATE SKIP LOCKED). Returns (nil, nil) when idle.
+func (s *Store) ClaimJob(ctx context.Context, jobTypes []string) (*Job, error) {
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var j Job
+	err = tx.QueryRow(ctx, `
+		SELECT id, job_type, payload, attempts, max_attempts, run_at, created_at
+		FROM app.jobs
+		WHERE state = 'pending' AND run_at <= now() AND job_type = ANY($1)
+		ORDER BY run_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	`, jobTypes).Scan(&j.ID, &j.JobType, &j.Payload, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE app.jobs
+		SET state = 'running', attempts = attempts + 1, locked_at = now()
+		WHERE id = $1
+		RETURNING state, attempts, locked_at, updated_at
+	`, j.ID).Scan(&j.State, &j.Attempts, &j.LockedAt, &j.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (s *Store) CompleteJob(ctx context.Context, id int64) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.jobs SET state = 'done', locked_at = NULL, last_error = NULL WHERE id = $1
+	`, id)
+	return err
+}
+
+// RetryJob puts a job back in the queue to run again at runAt.
+func (s *Store) RetryJob(ctx context.Context, id int64, errMsg string, runAt time.Time) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.jobs SET state = 'pending', locked_at = NULL, last_error = $2, run_at = $3 WHERE id = $1
+	`, id, errMsg, runAt)
+	return err
+}
+
+// ReleaseJob puts a running job back in the queue without using up the
+// attempt it was on, for work cut short by shutdown rather than failing.
+func (s *Store) ReleaseJob(ctx context.Context, id int64) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.jobs
+		SET state = 'pending', locked_at = NULL, attempts = GREATEST(attempts - 1, 0), run_at = now()
+		WHERE id = $1 AND state = 'running'
+	`, id)
+	return err
+}
+
+// FailJob marks a job permanently failed.
+func (s *Store) FailJob(ctx context.Context, id int64, errMsg string) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.jobs SET state = 'failed', locked_at = NULL, last_error = $2 WHERE id = $1
+	`, id, errMsg)
+	return err
+}
+
+// RequeueStaleJobs returns jobs stuck in 'running' (e.g. the process died
+// mid-run) to the queue. The attempt they were on still counts, so a job
+// that has used up max_attempts is marked failed instead and returned, for
+// the caller to give up on.
+func (s *Store) RequeueStaleJobs(ctx context.Context, olderThan time.Duration) (requeued int64, failed []Job, err error) {
+	rows, err := s.Pool.Query(ctx, `
+		UPDATE app.jobs
+		SET state = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+		    last_error = CASE WHEN attempts >= max_attempts THEN 'stale: still running after final attempt' ELSE last_error END,
+		    locked_at = NULL,
+		    run_at = now()
+		WHERE state = 'running' AND locked_at < now() - make_interval(secs => $1)
+		RETURNING id, job_type, payload, state, attempts, max_attempts, last_error, run_at, created_at, updated_at
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.ID, &j.JobType, &j.Payload, &j.State, &j.Attempts, &j.MaxAttempts,
+			&j.LastError, &j.RunAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			return 0, nil, err
+		}
+		if j.State == JobFailed {
+			failed = append(failed, j)
+		} else {
+			requeued++
+		}
+	}
+	return requeued, failed, rows.Err()
+}
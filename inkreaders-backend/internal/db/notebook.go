@@ -126,6 +126,13 @@ func (s *Store) ListTopics(ctx context.Context, userID uuid.UUID, limit int, cur
 	return out, nextCursor, nil
 }
 
+// TopicOwner returns the account that owns a topic.
+func (s *Store) TopicOwner(ctx context.Context, topicID uuid.UUID) (uuid.UUID, error) {
+	var owner uuid.UUID
+	err := s.Pool.QueryRow(ctx, `SELECT user_id FROM topics WHERE id = $1`, topicID).Scan(&owner)
+	return owner, err
+}
+
 func (s *Store) GetTopicWithResponses(ctx context.Context, topicID uuid.UUID, limit int, cursor string) (Topic, []Response, error) {
 	var t Topic
 	var tagsRaw, metaRaw []byte
@@ -321,6 +328,106 @@ func (s *Store) UpdateResponse(ctx context.Context, id uuid.UUID, content, conte
 	return err
 }
 
+// ResponseThread returns id and its ancestors via parent_response_id,
+// oldest first. Depth is capped so a cycle can't run away.
+func (s *Store) ResponseThread(ctx context.Context, id uuid.UUID) ([]Response, error) {
+	rows, err := s.Pool.Query(ctx, `
+		WITH RECURSIVE thread AS (
+			SELECT r.*, 0 AS depth FROM responses r WHERE r.id = $1
+			UNION ALL
+			SELECT p.*, t.depth + 1 FROM responses p
+			JOIN thread t ON p.id = t.parent_response_id
+			WHERE t.depth < 50
+		)
+		SELECT id, topic_id, parent_response_id, author_type, coalesce(content, ''), coalesce(content_html, ''), raw, created_at, updated_at, coalesce(status, '')
+		FROM thread
+		ORDER BY depth DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Response
+	for rows.Next() {
+		var r Response
+		var rawRaw []byte
+		if err := rows.Scan(&r.ID, &r.TopicID, &r.ParentResponseID, &r.AuthorType, &r.Content, &r.ContentHTML, &rawRaw, &r.CreatedAt, &r.UpdatedAt, &r.Status); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(rawRaw, &r.Raw)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// RerenderResponses recomputes content_html from content with renderFn for
+// every response and every saved version of one, in id order, writing only
+// rows whose HTML changes. Failed responses are included: their content is
+// a fixed notice, and rendering it costs nothing. It leaves updated_at
+// alone. Returns the number of rows rewritten.
+func (s *Store) RerenderResponses(ctx context.Context, renderFn func(string) string, batch int) (int, error) {
+	changed, err := s.rerenderHTML(ctx, "responses", renderFn, batch)
+	if err != nil {
+		return changed, err
+	}
+	n, err := s.rerenderHTML(ctx, "response_versions", renderFn, batch)
+	return changed + n, err
+}
+
+// rerenderHTML does the work of RerenderResponses for one table with id,
+// content and content_html columns.
+func (s *Store) rerenderHTML(ctx context.Context, table string, renderFn func(string) string, batch int) (int, error) {
+	var (
+		after   uuid.UUID
+		changed int
+	)
+	for {
+		rows, err := s.Pool.Query(ctx, `
+			SELECT id, coalesce(content, ''), coalesce(content_html, '')
+			FROM `+table+`
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2
+		`, after, batch)
+		if err != nil {
+			return changed, err
+		}
+		type item struct {
+			id   uuid.UUID
+			html string
+		}
+		var todo []item
+		n := 0
+		for rows.Next() {
+			var id uuid.UUID
+			var content, oldHTML string
+			if err := rows.Scan(&id, &content, &oldHTML); err != nil {
+				rows.Close()
+				return changed, err
+			}
+			n++
+			after = id
+			if html := renderFn(content); html != oldHTML {
+				todo = append(todo, item{id, html})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return changed, err
+		}
+		for _, it := range todo {
+			if _, err := s.Pool.Exec(ctx, `UPDATE `+table+` SET content_html = $2 WHERE id = $1`, it.id, it.html); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+		if n < batch {
+			return changed, nil
+		}
+	}
+}
+
 func (s *Store) FailResponse(ctx context.Context, id uuid.UUID, errMsg string) error {
 	_, err := s.Pool.Exec(ctx, `
 		UPDATE responses
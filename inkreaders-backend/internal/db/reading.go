@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ------------------ Reading progress & goals ------------------
+
+type ProgressEntry struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ShelfID        *uuid.UUID `json:"shelf_id,omitempty"`
+	BookID         *int64     `json:"book_id,omitempty"`
+	Page           *int       `json:"page,omitempty"`
+	Percent        *float64   `json:"percent,omitempty"`
+	SessionSeconds *int       `json:"session_seconds,omitempty"`
+	Note           *string    `json:"note,omitempty"`
+	ReadAt         time.Time  `json:"read_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (s *Store) InsertProgress(ctx context.Context, e *ProgressEntry) error {
+	if e.ReadAt.IsZero() {
+		e.ReadAt = time.Now()
+	}
+	return s.Pool.QueryRow(ctx, `
+		INSERT INTO app.reading_progress (user_id, shelf_id, book_id, page, percent, session_seconds, note, read_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, e.UserID, e.ShelfID, e.BookID, e.Page, e.Percent, e.SessionSeconds, e.Note, e.ReadAt).Scan(&e.ID, &e.CreatedAt)
+}
+
+// ListProgress returns a user's log newest first, optionally for one shelf
+// item. The cursor is an offset, as in ListTopics.
+func (s *Store) ListProgress(ctx context.Context, userID uuid.UUID, shelfID *uuid.UUID, limit int, cursor string) ([]ProgressEntry, string, error) {
+	offset := 0
+	if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+		offset = n
+	}
+	rows, err := s.Pool.Query(ctx, `
+		SELECT id, user_id, shelf_id, book_id, page, percent::float8, session_seconds, note, read_at, created_at
+		FROM app.reading_progress
+		WHERE user_id = $1 AND ($2::uuid IS NULL OR shelf_id = $2)
+		ORDER BY read_at DESC, id
+		LIMIT $3 OFFSET $4
+	`, userID, shelfID, limit+1, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []ProgressEntry{}
+	for rows.Next() {
+		var e ProgressEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ShelfID, &e.BookID, &e.Page, &e.Percent,
+			&e.SessionSeconds, &e.Note, &e.ReadAt, &e.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
+
+type ReadingGoal struct {
+	Period      string    `json:"period"` // "year" | "month"
+	PeriodStart time.Time `json:"period_start"`
+	TargetBooks *int      `json:"target_books,omitempty"`
+	TargetPages *int      `json:"target_pages,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (s *Store) UpsertReadingGoal(ctx context.Context, userID uuid.UUID, g *ReadingGoal) error {
+	return s.Pool.QueryRow(ctx, `
+		INSERT INTO app.reading_goals (user_id, period, period_start, target_books, target_pages)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, period, period_start) DO UPDATE SET
+			target_books = EXCLUDED.target_books,
+			target_pages = EXCLUDED.target_pages,
+			updated_at = now()
+		RETURNING updated_at
+	`, userID, g.Period, g.PeriodStart, g.TargetBooks, g.TargetPages).Scan(&g.UpdatedAt)
+}
+
+// ListReadingGoals returns the user's goals whose period starts in [from, to).
+func (s *Store) ListReadingGoals(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]ReadingGoal, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT period, period_start, target_books, target_pages, updated_at
+		FROM app.reading_goals
+		WHERE user_id = $1 AND period_start >= $2 AND period_start < $3
+		ORDER BY period_start, period DESC
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ReadingGoal{}
+	for rows.Next() {
+		var g ReadingGoal
+		if err := rows.Scan(&g.Period, &g.PeriodStart, &g.TargetBooks, &g.TargetPages, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// DayCount is a per-day (or per-month, as the first of the month) total.
+type DayCount struct {
+	Day   time.Time `json:"day"`
+	Count int       `json:"count"`
+}
+
+// ReadingDays returns every calendar day (in tz) with at least one progress
+// entry, most recent first.
+func (s *Store) ReadingDays(ctx context.Context, userID uuid.UUID, tz string) ([]time.Time, error) {
+	rows, err := s.Pool.Query(ctx, `
+		SELECT DISTINCT (read_at AT TIME ZONE $2)::date AS day
+		FROM app.reading_progress
+		WHERE user_id = $1
+		ORDER BY day DESC
+	`, userID, tz)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []time.Time
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// DailyPages sums pages read per day (in tz) over [from, to). Pages are the
+// increase between consecutive snapshots of the same book; percent-only
+// snapshots count when the catalog knows the page count. A book's first
+// snapshot only sets the baseline, since where reading started is unknown.
+func (s *Store) DailyPages(ctx context.Context, userID uuid.UUID, tz string, from, to time.Time) ([]DayCount, error) {
+	rows, err := s.Pool.Query(ctx, `
+		WITH snaps AS (
+			SELECT p.read_at,
+			       COALESCE(p.shelf_id::text, p.book_id::text, p.id::text) AS k,
+			       COALESCE(p.page, CASE WHEN b.page_count > 0 THEN round(p.percent / 100.0 * b.page_count)::int END) AS pg
+			FROM app.reading_progress p
+			LEFT JOIN app.books b ON b.id = COALESCE(p.book_id, (SELECT sh.book_id FROM app.shelves sh WHERE sh.id = p.shelf_id))
+			WHERE p.user_id = $1
+		),
+		deltas AS (
+			SELECT read_at, pg, lag(pg) OVER (PARTITION BY k ORDER BY read_at) AS prev
+			FROM snaps
+			WHERE pg IS NOT NULL
+		)
+		SELECT (read_at AT TIME ZONE $2)::date AS day, sum(GREATEST(pg - prev, 0))::int
+		FROM deltas
+		WHERE prev IS NOT NULL AND read_at >= $3 AND read_at < $4
+		GROUP BY day
+		ORDER BY day
+	`, userID, tz, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanDayCounts(rows)
+}
+
+// FinishedPerMonth counts books finished per month (in tz) over [from, to).
+// A book is finished when its shelf item moved to "finished" or a progress
+// snapshot reached 100% / the last page; each book counts once, at the
+// earliest of those.
+func (s *Store) FinishedPerMonth(ctx context.Context, userID uuid.UUID, tz string, from, to time.Time) ([]DayCount, error) {
+	rows, err := s.Pool.Query(ctx, `
+		WITH done AS (
+			SELECT 's:' || sh.id::text AS k, sh.finished_at AS at
+			FROM app.shelves sh
+			WHERE sh.user_id = $1 AND sh.status = 'finished' AND sh.finished_at IS NOT NULL
+			UNION ALL
+			SELECT COALESCE('s:' || p.shelf_id::text, 'b:' || p.book_id::text), p.read_at
+			FROM app.reading_progress p
+			LEFT JOIN app.books b ON b.id = p.book_id
+			WHERE p.user_id = $1
+			  AND (p.percent >= 100 OR (b.page_count > 0 AND p.page >= b.page_count))
+		),
+		firsts AS (
+			SELECT k, min(at) AS at FROM done WHERE k IS NOT NULL GROUP BY k
+		)
+		SELECT date_trunc('month', at AT TIME ZONE $2)::date AS month, count(*)::int
+		FROM firsts
+		WHERE at >= $3 AND at < $4
+		GROUP BY month
+		ORDER BY month
+	`, userID, tz, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanDayCounts(rows)
+}
+
+func scanDayCounts(rows pgx.Rows) ([]DayCount, error) {
+	defer rows.Close()
+	out := []DayCount{}
+	for rows.Next() {
+		var d DayCount
+		if err := rows.Scan(&d.Day, &d.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,273 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// --- Related topics ---
+
+type RelatedTopic struct {
+	ID         uuid.UUID `json:"id"`
+	Title      string    `json:"title"`
+	SharedTags []string  `json:"sharedTags"`
+	Score      float64   `json:"score"`
+}
+
+type RelatedHighlight struct {
+	ID         uuid.UUID `json:"id"`
+	TopicID    uuid.UUID `json:"topicId"`
+	TopicTitle string    `json:"topicTitle"`
+	Excerpt    string    `json:"excerpt"`
+	Score      float64   `json:"score"`
+}
+
+type Related struct {
+	Topics     []RelatedTopic     `json:"topics"`
+	Highlights []RelatedHighlight `json:"highlights"`
+	ComputedAt time.Time          `json:"computedAt"`
+}
+
+const (
+	// relatedKeep is how many of each kind are computed and cached; callers
+	// slice down to their own limit.
+	relatedKeep = 20
+	// relatedTTL bounds staleness from the viewer's other topics changing,
+	// which doesn't touch the fingerprint.
+	relatedTTL = 24 * time.Hour
+	// Weights of shared tags (Jaccard) vs shared terms (set cosine).
+	relatedTagWeight  = 0.6
+	relatedTermWeight = 0.4
+)
+
+// sourceResponseSQL selects the response that stands for the topic aliased
+// as alias: its canonical response, else the first one written.
+func sourceResponseSQL(alias string) string {
+	return fmt.Sprintf(`
+		SELECT r.id, r.updated_at, r.search_vector FROM responses r
+		WHERE r.topic_id = %[1]s.id
+		  AND (%[1]s.canonical_response_id IS NULL OR r.id = %[1]s.canonical_response_id)
+		ORDER BY r.created_at
+		LIMIT 1
+	`, alias)
+}
+
+// RelatedTopics returns the viewer's topics and highlights most similar to
+// topicID. The topic must be the viewer's own or have public/unlisted
+// visibility in meta; otherwise pgx.ErrNoRows. Results are cached until the
+// topic's source response changes (or relatedTTL passes).
+func (s *Store) RelatedTopics(ctx context.Context, topicID, viewer uuid.UUID, limit int) (Related, error) {
+	var (
+		owner      uuid.UUID
+		visibility string
+		srcID      *uuid.UUID
+		srcUpdated *time.Time
+		tagsRaw    []byte
+		terms      []string
+	)
+	err := s.Pool.QueryRow(ctx, `
+		SELECT t.user_id, coalesce(t.meta->>'visibility', 'private'), src.id, src.updated_at,
+		       coalesce(t.tags, '[]'::jsonb),
+		       tsvector_to_array(coalesce(t.search_vector, ''::tsvector) || coalesce(src.search_vector, ''::tsvector))
+		FROM topics t
+		LEFT JOIN LATERAL (`+sourceResponseSQL("t")+`) src ON true
+		WHERE t.id = $1
+	`, topicID).Scan(&owner, &visibility, &srcID, &srcUpdated, &tagsRaw, &terms)
+	if err != nil {
+		return Related{}, err
+	}
+	if owner != viewer && visibility != "public" && visibility != "unlisted" {
+		return Related{}, pgx.ErrNoRows
+	}
+
+	var cached Related
+	var resultRaw []byte
+	err = s.Pool.QueryRow(ctx, `
+		SELECT result FROM topic_related_cache
+		WHERE topic_id = $1 AND viewer_id = $2
+		  AND source_response_id IS NOT DISTINCT FROM $3
+		  AND source_updated_at IS NOT DISTINCT FROM $4
+		  AND computed_at > now() - make_interval(secs => $5)
+	`, topicID, viewer, srcID, srcUpdated, relatedTTL.Seconds()).Scan(&resultRaw)
+	if err == nil && json.Unmarshal(resultRaw, &cached) == nil {
+		return cached.truncate(limit), nil
+	} else if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Related{}, err
+	}
+
+	var tags []string
+	_ = json.Unmarshal(tagsRaw, &tags)
+	rel, err := s.computeRelated(ctx, topicID, viewer, tags, terms)
+	if err != nil {
+		return Related{}, err
+	}
+	_, err = s.Pool.Exec(ctx, `
+		INSERT INTO topic_related_cache (topic_id, viewer_id, source_response_id, source_updated_at, result, computed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (topic_id, viewer_id) DO UPDATE SET
+			source_response_id = EXCLUDED.source_response_id,
+			source_updated_at = EXCLUDED.source_updated_at,
+			result = EXCLUDED.result,
+			computed_at = EXCLUDED.computed_at
+	`, topicID, viewer, srcID, srcUpdated, toJSON(rel), rel.ComputedAt)
+	if err != nil {
+		return Related{}, err
+	}
+	return rel.truncate(limit), nil
+}
+
+func (s *Store) computeRelated(ctx context.Context, topicID, viewer uuid.UUID, tags, terms []string) (Related, error) {
+	rel := Related{Topics: []RelatedTopic{}, Highlights: []RelatedHighlight{}, ComputedAt: time.Now().UTC()}
+	tagSet := lowerSet(tags)
+
+	// Candidates share at least one tag or one term with the source.
+	rows, err := s.Pool.Query(ctx, `
+		SELECT c.id, c.title, coalesce(c.tags, '[]'::jsonb),
+		       tsvector_to_array(coalesce(c.search_vector, ''::tsvector) || coalesce(src.search_vector, ''::tsvector))
+		FROM topics c
+		LEFT JOIN LATERAL (`+sourceResponseSQL("c")+`) src ON true
+		WHERE c.user_id = $1 AND c.id <> $2
+		  AND (tsvector_to_array(coalesce(c.search_vector, ''::tsvector) || coalesce(src.search_vector, ''::tsvector)) && $3::text[]
+		       OR (jsonb_typeof(c.tags) = 'array'
+		           AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(c.tags) x WHERE lower(x) = ANY($4::text[]))))
+	`, viewer, topicID, terms, setKeys(tagSet))
+	if err != nil {
+		return rel, err
+	}
+	for rows.Next() {
+		var (
+			rt       RelatedTopic
+			tagsRaw  []byte
+			candTags []string
+			candTerm []string
+		)
+		if err := rows.Scan(&rt.ID, &rt.Title, &tagsRaw, &candTerm); err != nil {
+			rows.Close()
+			return rel, err
+		}
+		_ = json.Unmarshal(tagsRaw, &candTags)
+		tagScore := 0.0
+		candSet := lowerSet(candTags)
+		if len(tagSet) > 0 && len(candSet) > 0 {
+			union := len(tagSet)
+			for t := range candSet {
+				if tagSet[t] {
+					rt.SharedTags = append(rt.SharedTags, t)
+				} else {
+					union++
+				}
+			}
+			tagScore = float64(len(rt.SharedTags)) / float64(union)
+		}
+		if rt.SharedTags == nil {
+			rt.SharedTags = []string{}
+		}
+		sort.Strings(rt.SharedTags)
+		rt.Score = relatedTagWeight*tagScore + relatedTermWeight*termOverlap(terms, candTerm)
+		if rt.Score > 0 {
+			rel.Topics = append(rel.Topics, rt)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return rel, err
+	}
+	sort.Slice(rel.Topics, func(i, j int) bool { return rel.Topics[i].Score > rel.Topics[j].Score })
+	if len(rel.Topics) > relatedKeep {
+		rel.Topics = rel.Topics[:relatedKeep]
+	}
+
+	if len(terms) == 0 {
+		return rel, nil
+	}
+	rows, err = s.Pool.Query(ctx, `
+		SELECT h.id, h.topic_id, t.title, h.excerpt, tsvector_to_array(h.search_vector)
+		FROM highlights h JOIN topics t ON t.id = h.topic_id
+		WHERE h.user_id = $1 AND h.topic_id <> $2
+		  AND tsvector_to_array(h.search_vector) && $3::text[]
+	`, viewer, topicID, terms)
+	if err != nil {
+		return rel, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rh RelatedHighlight
+		var hTerms []string
+		if err := rows.Scan(&rh.ID, &rh.TopicID, &rh.TopicTitle, &rh.Excerpt, &hTerms); err != nil {
+			return rel, err
+		}
+		// A single shared word is usually noise for short excerpts.
+		if sharedCount(terms, hTerms) < 2 {
+			continue
+		}
+		rh.Score = termOverlap(terms, hTerms)
+		rel.Highlights = append(rel.Highlights, rh)
+	}
+	if err := rows.Err(); err != nil {
+		return rel, err
+	}
+	sort.Slice(rel.Highlights, func(i, j int) bool { return rel.Highlights[i].Score > rel.Highlights[j].Score })
+	if len(rel.Highlights) > relatedKeep {
+		rel.Highlights = rel.Highlights[:relatedKeep]
+	}
+	return rel, nil
+}
+
+func (r Related) truncate(limit int) Related {
+	if limit > 0 && len(r.Topics) > limit {
+		r.Topics = r.Topics[:limit]
+	}
+	if limit > 0 && len(r.Highlights) > limit {
+		r.Highlights = r.Highlights[:limit]
+	}
+	return r
+}
+
+// termOverlap is the cosine of two lexeme sets: shared / sqrt(|a|·|b|).
+func termOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	return float64(sharedCount(a, b)) / math.Sqrt(float64(len(a))*float64(len(b)))
+}
+
+func sharedCount(a, b []string) int {
+	set := make(map[string]bool, len(a))
+	for _, t := range a {
+		set[t] = true
+	}
+	n := 0
+	for _, t := range b {
+		if set[t] {
+			n++
+		}
+	}
+	return n
+}
+
+func lowerSet(ss []string) map[string]bool {
+	out := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			out[s] = true
+		}
+	}
+	return out
+}
+
+func setKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
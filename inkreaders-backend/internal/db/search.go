@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Full-text search ---
+
+const (
+	SearchTopic     = "topic"
+	SearchResponse  = "response"
+	SearchHighlight = "highlight"
+)
+
+type SearchHit struct {
+	Kind       string     `json:"kind"`
+	ID         uuid.UUID  `json:"id"`
+	TopicID    uuid.UUID  `json:"topicId"`
+	TopicTitle string     `json:"topicTitle"`
+	ResponseID *uuid.UUID `json:"responseId,omitempty"`
+	// Snippet is HTML: escaped text with matches wrapped in <mark>.
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ts_headline returns the stored text as is, which may hold markup, so it
+// marks matches with these private-use code points rather than <mark>;
+// snippetHTML escapes the rest and then swaps them for tags. A marker
+// already in the text only yields a stray tag.
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+// headlineOpts keeps snippets short and marks matches for snippetHTML.
+const headlineOpts = `StartSel="` + markStart + `", StopSel="` + markStop + `", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+
+var snippetMarks = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// snippetHTML makes a ts_headline snippet (or plain text) safe to render as
+// HTML, with matches in <mark>.
+func snippetHTML(s string) string {
+	return snippetMarks.Replace(html.EscapeString(s))
+}
+
+// Search runs a websearch-style query over the owner's topics, responses
+// (via their topic) and highlights, best matches first. kinds limits the
+// result kinds; empty means all. The cursor is an offset, as in ListTopics.
+func (s *Store) Search(ctx context.Context, owner uuid.UUID, query string, kinds []string, limit int, cursor string) ([]SearchHit, string, error) {
+	offset := 0
+	if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+		offset = n
+	}
+	if len(kinds) == 0 {
+		kinds = []string{SearchTopic, SearchResponse, SearchHighlight}
+	}
+
+	rows, err := s.Pool.Query(ctx, `
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query),
+		hits AS (
+			SELECT 'topic' AS kind, t.id, t.id AS topic_id, t.title AS topic_title, NULL::uuid AS response_id,
+			       concat_ws(' — ', t.title, t.description) AS body,
+			       ts_rank_cd(t.search_vector, q.query) AS rank, t.updated_at
+			FROM topics t, q
+			WHERE 'topic' = ANY($3) AND t.user_id = $1 AND t.search_vector @@ q.query
+			UNION ALL
+			SELECT 'response', r.id, t.id, t.title, r.id,
+			       r.content,
+			       ts_rank_cd(r.search_vector, q.query), r.updated_at
+			FROM responses r JOIN topics t ON t.id = r.topic_id, q
+			WHERE 'response' = ANY($3) AND t.user_id = $1 AND r.search_vector @@ q.query
+			UNION ALL
+			SELECT 'highlight', h.id, h.topic_id, t.title, h.response_id,
+			       concat_ws(' — ', h.excerpt, h.note),
+			       ts_rank_cd(h.search_vector, q.query), h.updated_at
+			FROM highlights h JOIN topics t ON t.id = h.topic_id, q
+			WHERE 'highlight' = ANY($3) AND h.user_id = $1 AND h.search_vector @@ q.query
+		),
+		page AS (
+			SELECT * FROM hits
+			ORDER BY rank DESC, updated_at DESC, id
+			LIMIT $4 OFFSET $5
+		)
+		SELECT p.kind, p.id, p.topic_id, p.topic_title, p.response_id,
+		       ts_headline('english', coalesce(p.body, ''), q.query, $6),
+		       p.rank::float8, coalesce(p.updated_at, now())
+		FROM page p, q
+		ORDER BY p.rank DESC, p.updated_at DESC, p.id
+	`, owner, query, kinds, limit+1, offset, headlineOpts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []SearchHit{}
+	for rows.Next() {
+		var h SearchHit
+		if err := rows.Scan(&h.Kind, &h.ID, &h.TopicID, &h.TopicTitle, &h.ResponseID,
+			&h.Snippet, &h.Rank, &h.UpdatedAt); err != nil {
+			return nil, "", err
+		}
+		h.Snippet = snippetHTML(h.Snippet)
+		out = append(out, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
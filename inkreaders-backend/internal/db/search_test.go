@@ -0,0 +1,19 @@
+package db
+
+import "testing"
+
+func TestSnippetHTML(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain words", "plain words"},
+		{"a " + markStart + "match" + markStop + " here", "a <mark>match</mark> here"},
+		{`<img src=x onerror="alert(1)"> ` + markStart + "cat" + markStop,
+			`&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>cat</mark>`},
+		{markStart + "<b>" + markStop + " & co", "<mark>&lt;b&gt;</mark> &amp; co"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := snippetHTML(tt.in); got != tt.want {
+			t.Errorf("snippetHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ------------------ Shelves ------------------
+
+const (
+	ShelfWant     = "want"
+	ShelfReading  = "reading"
+	ShelfFinished = "finished"
+)
+
+// ErrShelfExists is returned when the user already shelved a book with the
+// same title (the table's primary key).
+var ErrShelfExists = errors.New("book already on shelf")
+
+type ShelfItem struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	BookID     *int64     `json:"book_id,omitempty"`
+	ISBN       *string    `json:"isbn,omitempty"`
+	Title      string     `json:"title"`
+	Authors    []string   `json:"authors"`
+	Status     string     `json:"status"`
+	Note       *string    `json:"note,omitempty"`
+	Rating     *float64   `json:"rating,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	PostURI    *string    `json:"post_uri,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SetStatus moves the item to status and keeps the reading dates in step:
+// starting sets started_at, finishing sets finished_at (and started_at if the
+// book skipped "reading"), going back to "want" clears both, and reading a
+// finished book again starts a fresh read.
+func (it *ShelfItem) SetStatus(status string, now time.Time) {
+	switch status {
+	case ShelfWant:
+		it.StartedAt, it.FinishedAt = nil, nil
+	case ShelfReading:
+		if it.Status == ShelfFinished || it.StartedAt == nil {
+			it.StartedAt = &now
+		}
+		it.FinishedAt = nil
+	case ShelfFinished:
+		if it.StartedAt == nil {
+			it.StartedAt = &now
+		}
+		if it.Status != ShelfFinished || it.FinishedAt == nil {
+			it.FinishedAt = &now
+		}
+	}
+	it.Status = status
+}
+
+const shelfColumns = `
+	id, user_id, book_id, book_isbn, book_title, book_authors, status::text, note,
+	rating::float8, started_at, finished_at, post_uri, created_at, updated_at
+`
+
+func scanShelfItem(row pgx.Row) (ShelfItem, error) {
+	var it ShelfItem
+	err := row.Scan(&it.ID, &it.UserID, &it.BookID, &it.ISBN, &it.Title, &it.Authors, &it.Status, &it.Note,
+		&it.Rating, &it.StartedAt, &it.FinishedAt, &it.PostURI, &it.CreatedAt, &it.UpdatedAt)
+	return it, err
+}
+
+// ListShelf lists a user's shelf, most recently touched first, optionally
+// filtered by status. The cursor is an offset, as in ListTopics.
+func (s *Store) ListShelf(ctx context.Context, userID uuid.UUID, status string, limit int, cursor string) ([]ShelfItem, string, error) {
+	offset := 0
+	if cursor != "" {
+		if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+			offset = n
+		}
+	}
+	rows, err := s.Pool.Query(ctx, `
+		SELECT `+shelfColumns+`
+		FROM app.shelves
+		WHERE user_id = $1 AND ($2 = '' OR status::text = $2)
+		ORDER BY updated_at DESC, id
+		LIMIT $3 OFFSET $4
+	`, userID, status, limit+1, offset)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	out := []ShelfItem{}
+	for rows.Next() {
+		it, err := scanShelfItem(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		out = append(out, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.Itoa(offset + limit)
+	}
+	return out, next, nil
+}
+
+func (s *Store) GetShelfItem(ctx context.Context, id, userID uuid.UUID) (ShelfItem, error) {
+	return scanShelfItem(s.Pool.QueryRow(ctx, `
+		SELECT `+shelfColumns+` FROM app.shelves WHERE id = $1 AND user_id = $2
+	`, id, userID))
+}
+
+func (s *Store) CreateShelfItem(ctx context.Context, it *ShelfItem) error {
+	if it.Authors == nil {
+		it.Authors = []string{}
+	}
+	row := s.Pool.QueryRow(ctx, `
+		INSERT INTO app.shelves (user_id, book_id, book_isbn, book_title, book_authors, status, note,
+		                         rating, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6::shelf_status, $7, $8, $9, $10)
+		ON CONFLICT (user_id, book_title) DO NOTHING
+		RETURNING `+shelfColumns,
+		it.UserID, it.BookID, it.ISBN, it.Title, it.Authors, it.Status, it.Note,
+		it.Rating, it.StartedAt, it.FinishedAt)
+	created, err := scanShelfItem(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrShelfExists
+	}
+	if err != nil {
+		return err
+	}
+	*it = created
+	return nil
+}
+
+// UpdateShelfItem writes the mutable fields of an item the caller owns.
+// The post URI is left alone; publishing sets it through ClaimShelfPublish
+// and SetShelfPostURI.
+func (s *Store) UpdateShelfItem(ctx context.Context, it *ShelfItem) error {
+	row := s.Pool.QueryRow(ctx, `
+		UPDATE app.shelves SET
+			status = $3::shelf_status, note = $4, rating = $5,
+			started_at = $6, finished_at = $7, book_id = $8,
+			updated_at = now()
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+shelfColumns,
+		it.ID, it.UserID, it.Status, it.Note, it.Rating,
+		it.StartedAt, it.FinishedAt, it.BookID)
+	updated, err := scanShelfItem(row)
+	if err != nil {
+		return err
+	}
+	*it = updated
+	return nil
+}
+
+// shelfPublishClaimTTL is how long a publish claim holds before another
+// request may take it over, in case its holder died mid-post.
+const shelfPublishClaimTTL = 5 * time.Minute
+
+// ClaimShelfPublish marks an unpublished item as being published, so only
+// one of several concurrent publishes posts. It reports false if the item
+// already has a post or another publish holds the claim.
+func (s *Store) ClaimShelfPublish(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	ct, err := s.Pool.Exec(ctx, `
+		UPDATE app.shelves SET publishing_at = now()
+		WHERE id = $1 AND user_id = $2 AND post_uri IS NULL
+		  AND (publishing_at IS NULL OR publishing_at < now() - make_interval(secs => $3))
+	`, id, userID, shelfPublishClaimTTL.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+// ReleaseShelfPublish drops a publish claim after a failed post.
+func (s *Store) ReleaseShelfPublish(ctx context.Context, id, userID uuid.UUID) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE app.shelves SET publishing_at = NULL WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	return err
+}
+
+// SetShelfPostURI records the post a claimed publish made and releases the
+// claim.
+func (s *Store) SetShelfPostURI(ctx context.Context, it *ShelfItem, uri string) error {
+	row := s.Pool.QueryRow(ctx, `
+		UPDATE app.shelves SET post_uri = $3, publishing_at = NULL, updated_at = now()
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+shelfColumns,
+		it.ID, it.UserID, uri)
+	updated, err := scanShelfItem(row)
+	if err != nil {
+		return err
+	}
+	*it = updated
+	return nil
+}
+
+// DeleteShelfItem reports whether a row was removed.
+func (s *Store) DeleteShelfItem(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	ct, err := s.Pool.Exec(ctx, `DELETE FROM app.shelves WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() > 0, nil
+}
@@ -0,0 +1,129 @@
+package enrich
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/catalog"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// DumpSource reads an Open Library editions dump
+// (ol_dump_editions_*.txt[.gz]: type, key, revision, last_modified, JSON per
+// tab-separated line). It needs no network. The file is streamed once per
+// Lookup and only the requested ISBNs are kept, so a full multi-GB dump works
+// with little memory.
+type DumpSource struct {
+	Path string
+}
+
+func NewDumpSource(path string) *DumpSource { return &DumpSource{Path: path} }
+
+func (d *DumpSource) Name() string { return "openlibrary-dump" }
+
+type olEdition struct {
+	ISBN13        []string `json:"isbn_13"`
+	ISBN10        []string `json:"isbn_10"`
+	Publishers    []string `json:"publishers"`
+	NumberOfPages int      `json:"number_of_pages"`
+	Subjects      []string `json:"subjects"`
+	PublishDate   string   `json:"publish_date"`
+	Covers        []int    `json:"covers"`
+}
+
+func (d *DumpSource) Lookup(ctx context.Context, isbn13s []string) (map[string]db.BookMetadata, error) {
+	want := make(map[string]bool, len(isbn13s))
+	for _, s := range isbn13s {
+		want[s] = true
+	}
+
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(d.Path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	out := map[string]db.BookMetadata{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 1<<20), 16<<20)
+	for lines := 0; sc.Scan(); lines++ {
+		if lines%100000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		line := sc.Bytes()
+		// Cheap pre-filter before JSON decoding: editions only, and only
+		// lines mentioning an ISBN at all.
+		if !bytes.HasPrefix(line, []byte("/type/edition")) || !bytes.Contains(line, []byte(`"isbn_1`)) {
+			continue
+		}
+		i := bytes.LastIndexByte(line, '\t')
+		if i < 0 {
+			continue
+		}
+		var ed olEdition
+		if json.Unmarshal(line[i+1:], &ed) != nil {
+			continue
+		}
+		for _, isbn := range editionISBN13s(ed) {
+			if want[isbn] {
+				if _, seen := out[isbn]; !seen {
+					out[isbn] = ed.metadata()
+				}
+			}
+		}
+		if len(out) == len(want) {
+			break
+		}
+	}
+	return out, sc.Err()
+}
+
+func editionISBN13s(ed olEdition) []string {
+	var out []string
+	for _, s := range ed.ISBN13 {
+		if _, i13, err := catalog.NormalizeISBN(s); err == nil {
+			out = append(out, i13)
+		}
+	}
+	for _, s := range ed.ISBN10 {
+		if _, i13, err := catalog.NormalizeISBN(s); err == nil {
+			out = append(out, i13)
+		}
+	}
+	return out
+}
+
+func (ed olEdition) metadata() db.BookMetadata {
+	m := db.BookMetadata{
+		PageCount:   ed.NumberOfPages,
+		Subjects:    ed.Subjects,
+		PublishYear: publishYear(ed.PublishDate),
+	}
+	if len(ed.Publishers) > 0 {
+		m.Publisher = ed.Publishers[0]
+	}
+	for _, c := range ed.Covers {
+		if c > 0 {
+			m.CoverURL = coverURL(c)
+			break
+		}
+	}
+	return m
+}
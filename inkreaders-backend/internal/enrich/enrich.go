@@ -0,0 +1,76 @@
+// Package enrich fills in book metadata (cover, publisher, pages, subjects,
+// year) from a pluggable source, keyed by ISBN-13.
+package enrich
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// Source looks up editions by ISBN-13. Lookup returns only the ISBNs it
+// knows; a missing key is a miss, not an error.
+type Source interface {
+	Name() string
+	Lookup(ctx context.Context, isbn13s []string) (map[string]db.BookMetadata, error)
+}
+
+// RetryAfter is how long a miss is remembered before the book is tried again.
+const RetryAfter = 30 * 24 * time.Hour
+
+// Run enriches up to limit pending books from src and returns how many got
+// metadata.
+func Run(ctx context.Context, store *db.Store, src Source, limit int) (int, error) {
+	books, err := store.BooksToEnrich(ctx, limit, RetryAfter)
+	if err != nil || len(books) == 0 {
+		return 0, err
+	}
+	isbns := make([]string, len(books))
+	for i, b := range books {
+		isbns[i] = b.ISBN13
+	}
+	found, err := src.Lookup(ctx, isbns)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, b := range books {
+		var m *db.BookMetadata
+		if md, ok := found[b.ISBN13]; ok {
+			m = &md
+			n++
+		}
+		if err := store.SetBookMetadata(ctx, b.ID, src.Name(), m); err != nil {
+			return n, err
+		}
+	}
+	log.Printf("[enrich] %s: %d/%d books enriched", src.Name(), n, len(books))
+	return n, nil
+}
+
+// reYear finds a four-digit year not run into other digits. \b won't do:
+// there is no word boundary between the "c" and "1" of "c1999".
+var reYear = regexp.MustCompile(`(?:^|\D)((?:1[5-9]|20)\d\d)(?:\D|$)`)
+
+// publishYear pulls the year out of Open Library's free-form publish_date
+// ("1999", "March 5, 1999", "c1999").
+func publishYear(s string) int {
+	m := reYear.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	y, _ := strconv.Atoi(m[1])
+	return y
+}
+
+func coverURL(id int) string {
+	if id <= 0 {
+		return ""
+	}
+	return "https://covers.openlibrary.org/b/id/" + strconv.Itoa(id) + "-L.jpg"
+}
@@ -0,0 +1,28 @@
+package enrich
+
+import "testing"
+
+func TestPublishYear(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"1999", 1999},
+		{"March 5, 1999", 1999},
+		{"c1999", 1999},
+		{"©2003 Penguin", 2003},
+		{"1999-03-05", 1999},
+		{"05/03/2011", 2011},
+		{"[1850?]", 1850},
+		{"12345", 0},
+		{"Spring 1499", 0},
+		{"2100", 0},
+		{"n.d.", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := publishYear(tt.in); got != tt.want {
+			t.Errorf("publishYear(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// HTTPSource queries the Open Library Books API
+// (/api/books?bibkeys=ISBN:...&jscmd=data), batching ISBNs per request.
+type HTTPSource struct {
+	BaseURL   string
+	HTTP      *http.Client
+	BatchSize int
+}
+
+func NewHTTPSource(baseURL string) *HTTPSource {
+	if baseURL == "" {
+		baseURL = "https://openlibrary.org"
+	}
+	return &HTTPSource{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+		BatchSize: 50,
+	}
+}
+
+func (h *HTTPSource) Name() string { return "openlibrary-http" }
+
+type olNamed struct {
+	Name string `json:"name"`
+}
+
+type olBookData struct {
+	Publishers    []olNamed `json:"publishers"`
+	NumberOfPages int       `json:"number_of_pages"`
+	Subjects      []olNamed `json:"subjects"`
+	PublishDate   string    `json:"publish_date"`
+	Cover         struct {
+		Large  string `json:"large"`
+		Medium string `json:"medium"`
+	} `json:"cover"`
+}
+
+func (h *HTTPSource) Lookup(ctx context.Context, isbn13s []string) (map[string]db.BookMetadata, error) {
+	out := map[string]db.BookMetadata{}
+	for start := 0; start < len(isbn13s); start += h.BatchSize {
+		end := min(start+h.BatchSize, len(isbn13s))
+		keys := make([]string, 0, end-start)
+		for _, s := range isbn13s[start:end] {
+			keys = append(keys, "ISBN:"+s)
+		}
+
+		q := url.Values{}
+		q.Set("bibkeys", strings.Join(keys, ","))
+		q.Set("format", "json")
+		q.Set("jscmd", "data")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.BaseURL+"/api/books?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := h.HTTP.Do(req)
+		if err != nil {
+			return out, err
+		}
+		var page map[string]olBookData
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return out, fmt.Errorf("openlibrary: %s", resp.Status)
+		}
+		if err != nil {
+			return out, fmt.Errorf("openlibrary: decode: %w", err)
+		}
+
+		for key, d := range page {
+			m := db.BookMetadata{
+				CoverURL:    d.Cover.Large,
+				PageCount:   d.NumberOfPages,
+				PublishYear: publishYear(d.PublishDate),
+			}
+			if m.CoverURL == "" {
+				m.CoverURL = d.Cover.Medium
+			}
+			if len(d.Publishers) > 0 {
+				m.Publisher = d.Publishers[0].Name
+			}
+			for _, s := range d.Subjects {
+				m.Subjects = append(m.Subjects, s.Name)
+			}
+			out[strings.TrimPrefix(key, "ISBN:")] = m
+		}
+	}
+	return out, nil
+}
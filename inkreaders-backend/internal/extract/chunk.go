@@ -0,0 +1,107 @@
+package extract
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrPageRange = errors.New("page range out of bounds")
+
+// Chunk is a slice of a document small enough to hand to the model, with the
+// (1-based, inclusive) pages it came from.
+type Chunk struct {
+	PageFrom int    `json:"page_from"`
+	PageTo   int    `json:"page_to"`
+	Text     string `json:"text"`
+}
+
+// Chunks splits pages from..to (1-based, inclusive; 0 leaves that end open)
+// into chunks of at most maxChars characters. It breaks between paragraphs
+// where it can and only cuts inside a paragraph that is itself too long.
+func (d Document) Chunks(from, to, maxChars int) ([]Chunk, error) {
+	n := len(d.Pages)
+	if from <= 0 {
+		from = 1
+	}
+	if to <= 0 || to > n {
+		to = n
+	}
+	if n == 0 || from > to {
+		return nil, ErrPageRange
+	}
+	if maxChars <= 0 {
+		maxChars = 6000
+	}
+
+	var (
+		out  []Chunk
+		cur  strings.Builder
+		size int
+		cf   int
+		ct   int
+	)
+	flush := func() {
+		if strings.TrimSpace(cur.String()) != "" {
+			out = append(out, Chunk{PageFrom: cf, PageTo: ct, Text: strings.TrimSpace(cur.String())})
+		}
+		cur.Reset()
+		size, cf = 0, 0
+	}
+	add := func(page int, para string) {
+		l := utf8.RuneCountInString(para)
+		if size > 0 && size+2+l > maxChars {
+			flush()
+		}
+		if cf == 0 {
+			cf = page
+		}
+		ct = page
+		if size > 0 {
+			cur.WriteString("\n\n")
+			size += 2
+		}
+		cur.WriteString(para)
+		size += l
+	}
+
+	for page := from; page <= to; page++ {
+		for _, para := range strings.Split(d.Pages[page-1], "\n\n") {
+			para = strings.TrimSpace(para)
+			if para == "" {
+				continue
+			}
+			for _, piece := range splitLong(para, maxChars) {
+				add(page, piece)
+			}
+		}
+	}
+	flush()
+	return out, nil
+}
+
+// splitLong cuts s into pieces of at most max runes, preferring to cut at
+// whitespace in the last fifth of each piece.
+func splitLong(s string, max int) []string {
+	if utf8.RuneCountInString(s) <= max {
+		return []string{s}
+	}
+	var out []string
+	rs := []rune(s)
+	for len(rs) > max {
+		cut := max
+		for i := max; i > max*4/5; i-- {
+			if unicode.IsSpace(rs[i]) {
+				cut = i
+				break
+			}
+		}
+		out = append(out, strings.TrimSpace(string(rs[:cut])))
+		rs = rs[cut:]
+	}
+	if rest := strings.TrimSpace(string(rs)); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
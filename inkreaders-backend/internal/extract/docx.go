@@ -0,0 +1,104 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// docxPages walks word/document.xml. Page boundaries come from explicit page
+// breaks (<w:br w:type="page"/>) and the <w:lastRenderedPageBreak/> markers
+// Word writes on save, which track the pagination the author last saw.
+func docxPages(blob []byte) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	if err != nil {
+		return nil, fmt.Errorf("docx: %w", err)
+	}
+	var body []byte
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			if body, err = readZipFile(f); err != nil {
+				return nil, fmt.Errorf("docx: %w", err)
+			}
+			break
+		}
+	}
+	if body == nil {
+		return nil, errors.New("docx: word/document.xml missing")
+	}
+
+	var (
+		pages  []string
+		cur    strings.Builder
+		inText bool
+	)
+	breakPage := func() {
+		// Word often emits lastRenderedPageBreak right after an explicit
+		// break; don't turn that into an empty page.
+		if strings.TrimSpace(cur.String()) == "" {
+			return
+		}
+		pages = append(pages, cur.String())
+		cur.Reset()
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("docx: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				cur.WriteByte('\t')
+			case "cr":
+				cur.WriteByte('\n')
+			case "br":
+				if attr(t, "type") == "page" {
+					breakPage()
+				} else {
+					cur.WriteByte('\n')
+				}
+			case "lastRenderedPageBreak":
+				breakPage()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				cur.WriteByte('\n')
+			case "tc":
+				cur.WriteByte('\t')
+			}
+		case xml.CharData:
+			if inText {
+				cur.Write(t)
+			}
+		}
+	}
+	if strings.TrimSpace(cur.String()) != "" || len(pages) == 0 {
+		pages = append(pages, cur.String())
+	}
+	return pages, nil
+}
+
+func attr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
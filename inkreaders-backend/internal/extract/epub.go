@@ -0,0 +1,102 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// epubPages returns one page per spine item (usually a chapter), in reading
+// order, following META-INF/container.xml to the package document.
+func epubPages(blob []byte) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	if err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := readXML(files, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("epub: no rootfile in container.xml")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	var pkg struct {
+		Manifest []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := readXML(files, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, it := range pkg.Manifest {
+		hrefs[it.ID] = it.Href
+	}
+
+	base := path.Dir(opfPath)
+	var pages []string
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if u, err := url.PathUnescape(href); err == nil {
+			href = u
+		}
+		f, ok := files[path.Join(base, href)]
+		if !ok {
+			continue
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("epub: %s: %w", f.Name, err)
+		}
+		if text := htmlText(bytes.NewReader(b)); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	return pages, nil
+}
+
+func readXML(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("epub: %s missing", name)
+	}
+	b, err := readZipFile(f)
+	if err != nil {
+		return fmt.Errorf("epub: %s: %w", name, err)
+	}
+	if err := xml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("epub: %s: %w", name, err)
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return readLimited(rc)
+}
@@ -0,0 +1,122 @@
+// Package extract pulls plain text and page boundaries out of uploaded
+// documents (PDF, DOCX, EPUB, HTML, Markdown, plain text). Everything is pure
+// Go so it works offline.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MimePDF      = "application/pdf"
+	MimeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimeEPUB     = "application/epub+zip"
+	MimeHTML     = "text/html"
+	MimeXHTML    = "application/xhtml+xml"
+	MimeMarkdown = "text/markdown"
+	MimeText     = "text/plain"
+)
+
+// maxEntrySize caps how much we inflate from a single zip entry (DOCX/EPUB),
+// so a zip bomb cannot exhaust memory.
+const maxEntrySize = 64 << 20
+
+var ErrUnsupported = errors.New("unsupported document type")
+
+// Document is the extracted text, one entry per page. Formats without real
+// pages (HTML, Markdown) produce a single page; EPUB uses one per spine item.
+type Document struct {
+	Pages []string
+}
+
+// Text joins the pages with a blank line between them.
+func (d Document) Text() string {
+	return strings.Join(d.Pages, "\n\n")
+}
+
+func (d Document) PageCount() int { return len(d.Pages) }
+
+// Chars counts characters (runes), not bytes, of Text().
+func (d Document) Chars() int { return utf8.RuneCountInString(d.Text()) }
+
+// Extractor dispatches on MIME type.
+type Extractor struct{}
+
+func New() *Extractor { return &Extractor{} }
+
+func (e *Extractor) Extract(mimeType string, blob []byte) (Document, error) {
+	if mt, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mt
+	}
+	var (
+		pages []string
+		err   error
+	)
+	switch mimeType {
+	case MimePDF:
+		pages, err = pdfPages(blob)
+	case MimeDOCX:
+		pages, err = docxPages(blob)
+	case MimeEPUB:
+		pages, err = epubPages(blob)
+	case MimeHTML, MimeXHTML:
+		pages = []string{htmlText(strings.NewReader(string(blob)))}
+	case MimeMarkdown, "text/x-markdown":
+		pages = []string{markdownText(string(blob))}
+	default:
+		if !strings.HasPrefix(mimeType, "text/") && !utf8.Valid(blob) {
+			return Document{}, fmt.Errorf("%w: %s", ErrUnsupported, mimeType)
+		}
+		pages = strings.Split(string(blob), "\f")
+	}
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc := Document{Pages: make([]string, 0, len(pages))}
+	for _, p := range pages {
+		doc.Pages = append(doc.Pages, cleanText(p))
+	}
+	if len(doc.Pages) == 0 {
+		doc.Pages = []string{""}
+	}
+	return doc, nil
+}
+
+// ---------- shared helpers ----------
+
+var (
+	reSpaces     = regexp.MustCompile(`[ \t\x{00A0}]+`)
+	reBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// cleanText normalizes line endings, trims each line, collapses runs of
+// spaces and squeezes blank lines to at most one.
+func cleanText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	s = strings.ToValidUTF8(s, "")
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(reSpaces.ReplaceAllString(l, " "))
+	}
+	s = strings.Join(lines, "\n")
+	return strings.TrimSpace(reBlankLines.ReplaceAllString(s, "\n\n"))
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxEntrySize {
+		return nil, errors.New("document entry too large")
+	}
+	return b, nil
+}
@@ -0,0 +1,327 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMarkdownText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"heading", "## Chapter *One*", "Chapter One"},
+		{"setext heading", "Title\n=====\n\nBody", "Title\n\nBody"},
+		{"emphasis", "a **bold** and _italic_ and ~~gone~~ word", "a bold and italic and gone word"},
+		{"snake_case kept", "call snake_case_name here", "call snake_case_name here"},
+		{"underscore emphasis", "an _aside_ and __strong__.", "an aside and strong."},
+		{"link and image", "see [the docs](https://x.io) ![a chart](c.png)", "see the docs a chart"},
+		{"reference link", "read [this][1]\n\n[1]: https://x.io", "read this\n"},
+		{"inline code", "run `go test` now", "run go test now"},
+		{"fenced code kept verbatim", "```go\nx := *p\n```", "x := *p"},
+		{"tilde fence", "~~~\n# not a heading\n~~~", "# not a heading"},
+		{"lists", "- one\n* two\n1. three\n  2) four", "one\ntwo\nthree\n  four"},
+		{"quote", "> quoted **text**", "quoted text"},
+		{"rule dropped", "above\n\n---\n\nbelow", "above\n\n\nbelow"},
+		{"html tags", "a <span class=\"x\">b</span><br/>c", "a bc"},
+		{"table", "| a | b |\n|---|:-:|\n| 1 | 2 |", " a \t b \n 1 \t 2 "},
+		{"crlf", "# A\r\nb", "A\nb"},
+	}
+	for _, tt := range tests {
+		if got := markdownText(tt.in); got != tt.want {
+			t.Errorf("%s: markdownText(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"blocks as paragraphs", "<h1>Title</h1><p>One</p><p>Two</p>", "Title\n\nOne\n\nTwo"},
+		{"inline stays inline", "<p>a <b>bold</b> word</p>", "a bold word"},
+		{"no space added inside a word", "<p>a<b>b</b>c</p>", "abc"},
+		{"script and style dropped", "<head><title>T</title></head><script>x()</script><style>p{}</style><p>kept</p>", "kept"},
+		{"nested skip", "<svg><g><text>drawn</text></g></svg>after", "after"},
+		{"whitespace collapsed", "<p>a \n\t  b</p>", "a b"},
+		{"pre kept", "<pre>x  =  1\n  y</pre>", "x = 1\ny"},
+		{"table cells tabbed", "<table><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></table>", "a b\n\nc"},
+		{"entities", "<p>Tom &amp; Jerry &lt;3</p>", "Tom & Jerry <3"},
+		{"br", "one<br>two<br/>three", "one\ntwo\nthree"},
+	}
+	for _, tt := range tests {
+		if got := htmlText(strings.NewReader(tt.in)); got != tt.want {
+			t.Errorf("%s: htmlText(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+// docx zips body into word/document.xml.
+func docx(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>%s</w:body></w:document>`, body)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func para(runs ...string) string {
+	return "<w:p>" + strings.Join(runs, "") + "</w:p>"
+}
+
+func run(text string) string { return "<w:r><w:t>" + text + "</w:t></w:r>" }
+
+func TestDocxPages(t *testing.T) {
+	pageBreak := `<w:r><w:br w:type="page"/></w:r>`
+	rendered := `<w:r><w:lastRenderedPageBreak/></w:r>`
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"one page", para(run("Hello"), run(" world")), []string{"Hello world"}},
+		{"explicit break", para(run("one")) + para(pageBreak, run("two")), []string{"one", "two"}},
+		{"rendered break", para(run("one")) + para(rendered, run("two")), []string{"one", "two"}},
+		{
+			"break pair makes one boundary",
+			para(run("one")) + para(pageBreak) + para(rendered, run("two")),
+			[]string{"one", "two"},
+		},
+		{"leading break makes no empty page", para(pageBreak, run("only")), []string{"only"}},
+		{"line break and tab", para(run("a"), `<w:r><w:br/></w:r>`, run("b"), `<w:r><w:tab/></w:r>`, run("c")), []string{"a\nb c"}},
+		{"empty document", "", []string{""}},
+	}
+	for _, tt := range tests {
+		doc, err := New().Extract(MimeDOCX, docx(t, tt.body))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(doc.Pages, tt.want) {
+			t.Errorf("%s: pages = %q, want %q", tt.name, doc.Pages, tt.want)
+		}
+	}
+}
+
+func TestDocxErrors(t *testing.T) {
+	if _, err := docxPages([]byte("not a zip")); err == nil {
+		t.Error("non-zip input: want an error")
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("word/other.xml")
+	zw.Close()
+	if _, err := docxPages(buf.Bytes()); err == nil {
+		t.Error("zip without word/document.xml: want an error")
+	}
+	if _, err := docxPages(docx(t, "<w:p><w:r><w:t>unclosed")); err == nil {
+		t.Error("broken XML: want an error")
+	}
+}
+
+func TestChunks(t *testing.T) {
+	doc := Document{Pages: []string{
+		"aaaa\n\nbbbb",
+		"cccc",
+		"dddd\n\neeee",
+	}}
+	tests := []struct {
+		name     string
+		from, to int
+		max      int
+		want     []Chunk
+	}{
+		{
+			"everything fits",
+			0, 0, 100,
+			[]Chunk{{1, 3, "aaaa\n\nbbbb\n\ncccc\n\ndddd\n\neeee"}},
+		},
+		{
+			// Two paragraphs plus the blank line between them is 10.
+			"exactly at the limit",
+			0, 0, 10,
+			[]Chunk{{1, 1, "aaaa\n\nbbbb"}, {2, 3, "cccc\n\ndddd"}, {3, 3, "eeee"}},
+		},
+		{
+			"one over the limit",
+			0, 0, 9,
+			[]Chunk{{1, 1, "aaaa"}, {1, 1, "bbbb"}, {2, 2, "cccc"}, {3, 3, "dddd"}, {3, 3, "eeee"}},
+		},
+		{"page range", 2, 3, 100, []Chunk{{2, 3, "cccc\n\ndddd\n\neeee"}}},
+		{"single page", 3, 3, 100, []Chunk{{3, 3, "dddd\n\neeee"}}},
+		{"open end past the last page", 2, 99, 100, []Chunk{{2, 3, "cccc\n\ndddd\n\neeee"}}},
+	}
+	for _, tt := range tests {
+		got, err := doc.Chunks(tt.from, tt.to, tt.max)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: chunks = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, r := range [][2]int{{4, 5}, {3, 2}} {
+		if _, err := doc.Chunks(r[0], r[1], 100); err != ErrPageRange {
+			t.Errorf("Chunks(%d, %d): err = %v, want ErrPageRange", r[0], r[1], err)
+		}
+	}
+	if _, err := (Document{}).Chunks(0, 0, 100); err != ErrPageRange {
+		t.Errorf("empty document: err = %v, want ErrPageRange", err)
+	}
+}
+
+func TestChunksNoOverlapNoLoss(t *testing.T) {
+	words := strings.Repeat("lorem ipsum dolor sit amet ", 60)
+	doc := Document{Pages: []string{
+		"short one\n\n" + words,
+		"ünïcödé " + strings.Repeat("ß", 500),
+		"tail",
+	}}
+	const max = 200
+	chunks, err := doc.Chunks(0, 0, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevTo := 1
+	var joined strings.Builder
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c.Text); n > max {
+			t.Errorf("chunk %d has %d runes, max %d", i, n, max)
+		}
+		if !utf8.ValidString(c.Text) {
+			t.Errorf("chunk %d splits a rune", i)
+		}
+		if c.PageFrom > c.PageTo || c.PageFrom < prevTo {
+			t.Errorf("chunk %d pages %d-%d after page %d", i, c.PageFrom, c.PageTo, prevTo)
+		}
+		prevTo = c.PageTo
+		joined.WriteString(strings.Join(strings.Fields(c.Text), ""))
+	}
+	// Hard cuts may split a word, so compare without whitespace: every
+	// character appears exactly once, in order. Chunks neither overlap
+	// nor drop text.
+	if want := strings.Join(strings.Fields(doc.Text()), ""); joined.String() != want {
+		t.Errorf("chunks hold %d bytes of text, document has %d", joined.Len(), len(want))
+	}
+	if last := chunks[len(chunks)-1]; last.PageTo != 3 || !strings.HasSuffix(last.Text, "\n\ntail") {
+		t.Errorf("last chunk = %+v", last)
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want []string
+	}{
+		{"fits", "abc def", 7, []string{"abc def"}},
+		{"cuts at late space", "aaaa bbbb cccc", 10, []string{"aaaa bbbb", "cccc"}},
+		{"hard cut without a late space", "aaaaaaaaaaaa", 5, []string{"aaaaa", "aaaaa", "aa"}},
+		{"early space ignored", "a bbbbbbbbbbb", 10, []string{"a bbbbbbbb", "bbb"}},
+		{"runes not bytes", "ééééé ééééé", 6, []string{"ééééé", "ééééé"}},
+	}
+	for _, tt := range tests {
+		if got := splitLong(tt.in, tt.max); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitLong(%q, %d) = %q, want %q", tt.name, tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+// minimalPDF builds a one-page PDF showing text in Helvetica, with a
+// correct xref table.
+func minimalPDF(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, o := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestPDFPages(t *testing.T) {
+	blob := minimalPDF("Hello PDF")
+	pages, err := pdfPages(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || !strings.Contains(pages[0], "Hello PDF") {
+		t.Errorf("pages = %q", pages)
+	}
+
+	// Truncated or mangled files must come back as errors, never panics.
+	for _, n := range []int{0, 8, len(blob) / 3, len(blob) / 2, len(blob) - 40, len(blob) - 10} {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("truncated to %d bytes: panic %v", n, r)
+				}
+			}()
+			if _, err := pdfPages(blob[:n]); err == nil {
+				t.Errorf("truncated to %d bytes: want an error", n)
+			}
+		}()
+	}
+}
+
+func TestExtractDispatch(t *testing.T) {
+	e := New()
+	tests := []struct {
+		mime string
+		in   string
+		want []string
+	}{
+		{"text/plain; charset=utf-8", "page one\fpage   two", []string{"page one", "page two"}},
+		{MimeMarkdown, "# Hi\n\n*there*", []string{"Hi\n\nthere"}},
+		{"text/x-markdown", "`x`", []string{"x"}},
+		{MimeHTML, "<p>a</p><p>b</p>", []string{"a\n\nb"}},
+		{"application/json", `{"a":1}`, []string{`{"a":1}`}},
+	}
+	for _, tt := range tests {
+		doc, err := e.Extract(tt.mime, []byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.mime, err)
+			continue
+		}
+		if !reflect.DeepEqual(doc.Pages, tt.want) {
+			t.Errorf("%s: pages = %q, want %q", tt.mime, doc.Pages, tt.want)
+		}
+	}
+	if _, err := e.Extract("application/octet-stream", []byte{0xff, 0xfe, 0x00, 0x81}); err == nil {
+		t.Error("binary blob: want ErrUnsupported")
+	}
+}
@@ -0,0 +1,80 @@
+package extract
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var reHTMLSpace = regexp.MustCompile(`\s+`)
+
+// Elements whose text never reaches the reader.
+var skipElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Math: true,
+}
+
+// Elements that start a new line in rendered output.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Table: true, atom.Ul: true, atom.Ol: true,
+	atom.Hr: true, atom.Dt: true, atom.Dd: true, atom.Figcaption: true, atom.Aside: true,
+}
+
+// htmlText renders an HTML (or XHTML) document as plain text: scripts and
+// styles dropped, block elements on their own lines, whitespace collapsed
+// outside <pre>.
+func htmlText(r io.Reader) string {
+	var (
+		sb   strings.Builder
+		skip int
+		pre  int
+	)
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return cleanText(sb.String())
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			a := atom.Lookup(name)
+			if skipElements[a] {
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if a == atom.Pre {
+				if tt == html.StartTagToken {
+					pre++
+				} else if tt == html.EndTagToken && pre > 0 {
+					pre--
+				}
+			}
+			if blockElements[a] {
+				sb.WriteByte('\n')
+			} else if a == atom.Td || a == atom.Th {
+				sb.WriteByte('\t')
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := string(z.Text())
+			if pre == 0 {
+				// Collapse whitespace but keep a single space at the edges,
+				// so "a <b>b</b>" stays two words and "a<b>b</b>" one.
+				text = reHTMLSpace.ReplaceAllString(text, " ")
+			}
+			sb.WriteString(text)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	reMDImage     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	reMDLink      = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	reMDRefLink   = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	reMDRefDef    = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s+\S+`)
+	reMDHeading   = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	reMDSetext    = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	reMDRule      = regexp.MustCompile(`^\s{0,3}([-*_]\s*){3,}$`)
+	reMDQuote     = regexp.MustCompile(`^\s{0,3}>\s?`)
+	reMDList      = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+`)
+	reMDEmphasis  = regexp.MustCompile(`(\*\*|__|\*|_|~~)([^\s*_~](?:[^*_~]*[^\s*_~])?)(\*\*|__|\*|_|~~)`)
+	reMDCode      = regexp.MustCompile("`+([^`]+)`+")
+	reMDTag       = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	reMDTableRule = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// markdownText strips Markdown syntax and keeps the readable text: headings,
+// emphasis, links and code lose their markers, fenced code keeps its body.
+func markdownText(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	fenced := false
+	for _, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			out = append(out, l)
+			continue
+		}
+		if reMDRefDef.MatchString(l) || reMDTableRule.MatchString(l) ||
+			reMDRule.MatchString(l) || (reMDSetext.MatchString(l) && len(out) > 0 && out[len(out)-1] != "") {
+			continue
+		}
+		l = reMDQuote.ReplaceAllString(l, "")
+		l = reMDHeading.ReplaceAllString(l, "")
+		l = reMDList.ReplaceAllString(l, "$1")
+		l = reMDImage.ReplaceAllString(l, "$1")
+		l = reMDLink.ReplaceAllString(l, "$1")
+		l = reMDRefLink.ReplaceAllString(l, "$1")
+		l = reMDCode.ReplaceAllString(l, "$1")
+		l = stripEmphasis(l)
+		l = reMDTag.ReplaceAllString(l, "")
+		if strings.Contains(l, "|") && strings.HasPrefix(strings.TrimSpace(l), "|") {
+			l = strings.ReplaceAll(strings.Trim(strings.TrimSpace(l), "|"), "|", "\t")
+		}
+		out = append(out, l)
+	}
+	return strings.Join(out, "\n")
+}
+
+// stripEmphasis drops emphasis markers but leaves underscores inside words
+// alone, as CommonMark does, so snake_case names survive.
+func stripEmphasis(l string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range reMDEmphasis.FindAllStringSubmatchIndex(l, -1) {
+		start, end := m[0], m[1]
+		if l[start] == '_' && (isWordByte(l, start-1) || isWordByte(l, end)) {
+			continue
+		}
+		sb.WriteString(l[last:start])
+		sb.WriteString(l[m[4]:m[5]])
+		last = end
+	}
+	sb.WriteString(l[last:])
+	return sb.String()
+}
+
+func isWordByte(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
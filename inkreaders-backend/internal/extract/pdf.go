@@ -0,0 +1,41 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfPages returns the text of each page in order. The PDF reader panics on
+// some malformed inputs, so we turn that into an error.
+func pdfPages(blob []byte) (pages []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("pdf: malformed document: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(blob), int64(len(blob)))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: %w", err)
+	}
+
+	n := r.NumPage()
+	pages = make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			pages = append(pages, "")
+			continue
+		}
+		// Font resource names (F1, F2…) are page-local, so let each page
+		// build its own font table.
+		text, err := p.GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("pdf: page %d: %w", i, err)
+		}
+		pages = append(pages, text)
+	}
+	return pages, nil
+}
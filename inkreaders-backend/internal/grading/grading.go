@@ -0,0 +1,475 @@
+// Package grading checks learner answers against the stored correct answers
+// of an exercise set, so scoring never depends on the browser.
+package grading
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// Result is the outcome of grading a whole submission.
+type Result struct {
+	Results []db.QuestionResult
+	Correct int
+	Total   int
+	Score   float64 // fraction correct, 0..1
+}
+
+// QuestionKey is the key answers are submitted under. Questions saved without
+// an ID fall back to the same "q<n>" scheme the generator uses.
+func QuestionKey(q db.Question, idx int) string {
+	if strings.TrimSpace(q.ID) != "" {
+		return q.ID
+	}
+	return fmt.Sprintf("q%d", idx+1)
+}
+
+// GradeSet grades answers (keyed by QuestionKey) for every question in qs.
+// Unanswered questions count as incorrect.
+func GradeSet(qs []db.Question, answers map[string]any) Result {
+	res := Result{Results: make([]db.QuestionResult, 0, len(qs)), Total: len(qs)}
+	for i, q := range qs {
+		key := QuestionKey(q, i)
+		ans, ok := answers[key]
+		var correct bool
+		var score *float64
+		if ok {
+			correct, score = grade(q, ans)
+		}
+		if correct {
+			res.Correct++
+		}
+		res.Results = append(res.Results, db.QuestionResult{
+			QuestionID:    key,
+			Answer:        ans,
+			Correct:       correct,
+			CorrectAnswer: q.CorrectAnswer,
+			Score:         score,
+		})
+	}
+	if res.Total > 0 {
+		res.Score = roundScore(float64(res.Correct) / float64(res.Total))
+	}
+	return res
+}
+
+// Grade reports whether answer is correct for q.
+func Grade(q db.Question, answer any) bool {
+	correct, _ := grade(q, answer)
+	return correct
+}
+
+// grade also returns the partial credit for the types that have it.
+func grade(q db.Question, answer any) (bool, *float64) {
+	if answer == nil {
+		return false, nil
+	}
+	switch q.Type {
+	case db.QuestionMCQ:
+		return gradeMCQ(q, answer), nil
+	case db.QuestionTrueFalse:
+		want, ok1 := asBool(q.CorrectAnswer)
+		got, ok2 := asBool(answer)
+		return ok1 && ok2 && want == got, nil
+	case db.QuestionFillBlank:
+		return gradeFillBlank(q.CorrectAnswer, answer), nil
+	case db.QuestionMultiSelect:
+		return gradeMultiSelect(q, answer), nil
+	case db.QuestionOrdering:
+		return gradeOrdering(q, answer), nil
+	case db.QuestionMatching:
+		return gradeMatching(q, answer)
+	case db.QuestionShortAnswer:
+		return gradeShortAnswer(q, answer)
+	case db.QuestionNumeric:
+		return gradeNumeric(q, answer), nil
+	default:
+		// Unknown/mixed rows saved from the client: best effort text match.
+		return normalizeText(stringify(q.CorrectAnswer)) != "" &&
+			normalizeText(stringify(q.CorrectAnswer)) == normalizeText(stringify(answer)), nil
+	}
+}
+
+// ---------- mcq ----------
+
+// gradeMCQ resolves both sides to an option index. The model answers with
+// either the option text or a letter ("A".."D"); learners may send the text,
+// the letter or the zero-based index.
+func gradeMCQ(q db.Question, answer any) bool {
+	want := OptionIndex(q.Options, q.CorrectAnswer)
+	got := OptionIndex(q.Options, answer)
+	if want >= 0 && got >= 0 {
+		return want == got
+	}
+	return normalizeText(stringify(q.CorrectAnswer)) == normalizeText(stringify(answer))
+}
+
+// OptionIndex resolves an option given as its text, letter or zero-based
+// index to the index, or -1.
+func OptionIndex(options []string, v any) int {
+	switch t := v.(type) {
+	case float64:
+		if i := int(t); float64(i) == t && i >= 0 && i < len(options) {
+			return i
+		}
+		return -1
+	case string:
+		s := normalizeText(t)
+		for i, o := range options {
+			if normalizeText(o) == s {
+				return i
+			}
+		}
+		// letter answer: "A", "b", "C)", "(d)"
+		l := strings.Trim(strings.TrimSpace(t), "().: ")
+		if len(l) == 1 {
+			c := unicode.ToUpper(rune(l[0]))
+			if c >= 'A' && int(c-'A') < len(options) {
+				return int(c - 'A')
+			}
+		}
+	}
+	return -1
+}
+
+// ---------- multi_select ----------
+
+// gradeMultiSelect wants exactly the right set of options, each given like
+// an mcq answer, in any order.
+func gradeMultiSelect(q db.Question, answer any) bool {
+	want, ok1 := optionSet(q.Options, q.CorrectAnswer)
+	got, ok2 := optionSet(q.Options, answer)
+	if !ok1 || !ok2 || len(want) == 0 || len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if !got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func optionSet(options []string, v any) (map[int]bool, bool) {
+	set := map[int]bool{}
+	for _, item := range asList(v) {
+		i := OptionIndex(options, item)
+		if i < 0 {
+			return nil, false
+		}
+		set[i] = true
+	}
+	return set, true
+}
+
+// ---------- ordering ----------
+
+// gradeOrdering wants every option, in the stored order. Items may be given
+// as text, letters or indexes into the (shuffled) options.
+func gradeOrdering(q db.Question, answer any) bool {
+	want := asList(q.CorrectAnswer)
+	got := asList(answer)
+	if len(want) == 0 || len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		w, g := OptionIndex(q.Options, want[i]), OptionIndex(q.Options, got[i])
+		if w < 0 || w != g {
+			return false
+		}
+	}
+	return true
+}
+
+// asList reads a JSON array, or a "|" or comma separated string.
+func asList(v any) []any {
+	switch t := v.(type) {
+	case []any:
+		return t
+	case []string:
+		out := make([]any, len(t))
+		for i, s := range t {
+			out[i] = s
+		}
+		return out
+	case string:
+		sep := "|"
+		if !strings.Contains(t, sep) {
+			sep = ","
+		}
+		var out []any
+		for _, s := range strings.Split(t, sep) {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// ---------- matching ----------
+
+// gradeMatching accepts {"left": "right", ...} or [{"left","right"}, ...]
+// and scores the share of pairs matched; it is correct when all are.
+func gradeMatching(q db.Question, answer any) (bool, *float64) {
+	pairs := q.Pairs
+	if len(pairs) == 0 {
+		// Only the plain copy was saved.
+		if m, ok := q.CorrectAnswer.(map[string]any); ok {
+			for l, r := range m {
+				pairs = append(pairs, db.MatchPair{Left: l, Right: stringify(r)})
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return false, nil
+	}
+
+	got := map[string]string{}
+	switch t := answer.(type) {
+	case map[string]any:
+		for l, r := range t {
+			got[normalizeText(l)] = normalizeText(stringify(r))
+		}
+	case []any:
+		for _, item := range t {
+			if m, ok := item.(map[string]any); ok {
+				got[normalizeText(stringify(m["left"]))] = normalizeText(stringify(m["right"]))
+			}
+		}
+	}
+
+	matched := 0
+	for _, p := range pairs {
+		if r, ok := got[normalizeText(p.Left)]; ok && r == normalizeText(p.Right) {
+			matched++
+		}
+	}
+	score := roundScore(float64(matched) / float64(len(pairs)))
+	return matched == len(pairs), &score
+}
+
+// ---------- short_answer ----------
+
+const defaultPassScore = 0.6
+
+// gradeShortAnswer scores the share of rubric criteria whose keywords the
+// answer mentions. A keyword matches at the start of a word, so "scatter"
+// also finds "scattering". Without a rubric it falls back to a text match
+// against the model answer.
+func gradeShortAnswer(q db.Question, answer any) (bool, *float64) {
+	got := normalizeText(stringify(answer))
+	if got == "" {
+		return false, nil
+	}
+	if q.Rubric == nil || len(q.Rubric.Criteria) == 0 {
+		return got == normalizeText(stringify(q.CorrectAnswer)), nil
+	}
+
+	padded := " " + got
+	met := 0
+	for _, c := range q.Rubric.Criteria {
+		for _, kw := range c.Keywords {
+			if k := normalizeText(kw); k != "" && strings.Contains(padded, " "+k) {
+				met++
+				break
+			}
+		}
+	}
+	score := roundScore(float64(met) / float64(len(q.Rubric.Criteria)))
+	pass := q.Rubric.PassScore
+	if pass <= 0 {
+		pass = defaultPassScore
+	}
+	return score >= pass, &score
+}
+
+// ---------- numeric ----------
+
+// gradeNumeric accepts a number within the tolerance. String answers may
+// carry thousands separators, a decimal comma and a trailing unit
+// ("1,200 m", "3,5 kg", "1200eV").
+func gradeNumeric(q db.Question, answer any) bool {
+	want := db.NumericAnswer{}
+	if q.Numeric != nil {
+		want = *q.Numeric
+	} else if v, ok := asNumber(q.CorrectAnswer); ok {
+		want.Value = v
+	} else {
+		return false
+	}
+	got, ok := asNumber(answer)
+	if !ok {
+		return false
+	}
+	// Leave room for float error at the edge of the tolerance.
+	tol := math.Abs(want.Tolerance) + 1e-9*math.Max(1, math.Abs(want.Value))
+	return math.Abs(got-want.Value) <= tol
+}
+
+// asNumber reads the number a string starts with and ignores the rest. A
+// comma followed by exactly three digits groups thousands ("1,200"), unless
+// the number starts with 0; any other comma is a decimal comma ("3,5",
+// "0,001"). An "e" is an exponent only when
+// digits follow it, so "1200eV" is 1200 and "3e" is 3.
+func asNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case string:
+		s := strings.TrimSpace(t)
+		var num strings.Builder
+		i, digits, point := 0, 0, false
+		var lead byte
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			num.WriteByte(s[i])
+			i++
+		}
+	mantissa:
+		for i < len(s) {
+			switch c := s[i]; {
+			case isDigit(c):
+				if digits == 0 {
+					lead = c
+				}
+				num.WriteByte(c)
+				digits++
+			case c == ',' && !point && digits > 0 && lead != '0' && thousandsGroup(s[i+1:]):
+				// separator: skip it
+			case (c == '.' || c == ',') && !point && i+1 < len(s) && isDigit(s[i+1]):
+				num.WriteByte('.')
+				point = true
+			default:
+				break mantissa
+			}
+			i++
+		}
+		if digits == 0 {
+			return 0, false
+		}
+		if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+			j := i + 1
+			if j < len(s) && (s[j] == '+' || s[j] == '-') {
+				j++
+			}
+			k := j
+			for k < len(s) && isDigit(s[k]) {
+				k++
+			}
+			if k > j {
+				num.WriteString(s[i:k])
+			}
+		}
+		f, err := strconv.ParseFloat(num.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// thousandsGroup reports whether s starts with exactly three digits.
+func thousandsGroup(s string) bool {
+	return len(s) >= 3 && isDigit(s[0]) && isDigit(s[1]) && isDigit(s[2]) && (len(s) == 3 || !isDigit(s[3]))
+}
+
+// ---------- true_false ----------
+
+func asBool(v any) (bool, bool) {
+	switch t := v.(type) {
+	case bool:
+		return t, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "true", "t", "yes", "y", "1":
+			return true, true
+		case "false", "f", "no", "n", "0":
+			return false, true
+		}
+	case float64:
+		if t == 1 {
+			return true, true
+		}
+		if t == 0 {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// ---------- fill_blank ----------
+
+// gradeFillBlank compares normalized text. The stored answer may list
+// alternatives as a JSON array or separated by "|".
+func gradeFillBlank(correct, answer any) bool {
+	got := normalizeText(stringify(answer))
+	if got == "" {
+		return false
+	}
+	for _, alt := range alternatives(correct) {
+		if normalizeText(alt) == got {
+			return true
+		}
+	}
+	return false
+}
+
+func alternatives(v any) []string {
+	switch t := v.(type) {
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, a := range t {
+			out = append(out, stringify(a))
+		}
+		return out
+	case string:
+		return strings.Split(t, "|")
+	default:
+		return []string{stringify(v)}
+	}
+}
+
+// ---------- helpers ----------
+
+func roundScore(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
+
+func stringify(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// normalizeText lowercases, drops punctuation and collapses whitespace, so
+// "The  Moon." and "the moon" compare equal.
+func normalizeText(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r):
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			sb.WriteRune(r)
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			space = true
+		}
+	}
+	return sb.String()
+}
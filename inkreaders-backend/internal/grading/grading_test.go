@@ -0,0 +1,265 @@
+package grading
+
+import (
+	"testing"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+func TestGradeMultiSelect(t *testing.T) {
+	q := db.Question{
+		Type:          db.QuestionMultiSelect,
+		Options:       []string{"Mercury", "Venus", "Earth", "Mars"},
+		CorrectAnswer: []any{"Venus", "Mars"},
+	}
+	tests := []struct {
+		name   string
+		answer any
+		want   bool
+	}{
+		{"texts", []any{"Venus", "Mars"}, true},
+		{"any order", []any{"Mars", "Venus"}, true},
+		{"letters", []any{"B", "d)"}, true},
+		{"indexes", []any{1.0, 3.0}, true},
+		{"mixed forms", []any{"b", 3.0}, true},
+		{"case and punctuation", []any{"venus.", " MARS "}, true},
+		{"comma string", "Venus, Mars", true},
+		{"pipe string", "B|D", true},
+		{"missing one", []any{"Venus"}, false},
+		{"one extra", []any{"Venus", "Mars", "Earth"}, false},
+		{"wrong one", []any{"Venus", "Earth"}, false},
+		{"not an option", []any{"Venus", "Pluto"}, false},
+		{"index out of range", []any{1.0, 4.0}, false},
+		{"fractional index", []any{1.5, 3.0}, false},
+		{"empty", []any{}, false},
+	}
+	for _, tt := range tests {
+		if got := gradeMultiSelect(q, tt.answer); got != tt.want {
+			t.Errorf("%s: gradeMultiSelect(%v) = %v, want %v", tt.name, tt.answer, got, tt.want)
+		}
+	}
+
+	letters := q
+	letters.CorrectAnswer = []any{"B", "D"}
+	if !gradeMultiSelect(letters, []any{"Venus", "Mars"}) {
+		t.Error("letter key did not match text answer")
+	}
+	empty := q
+	empty.CorrectAnswer = []any{}
+	if gradeMultiSelect(empty, []any{}) {
+		t.Error("empty key graded an empty answer correct")
+	}
+}
+
+func TestGradeOrdering(t *testing.T) {
+	// Options as displayed (shuffled); the key is the correct order.
+	q := db.Question{
+		Type:          db.QuestionOrdering,
+		Options:       []string{"Cretaceous", "Triassic", "Jurassic"},
+		CorrectAnswer: []any{"Triassic", "Jurassic", "Cretaceous"},
+	}
+	tests := []struct {
+		name   string
+		answer any
+		want   bool
+	}{
+		{"texts", []any{"Triassic", "Jurassic", "Cretaceous"}, true},
+		{"letters of shuffled options", []any{"B", "C", "A"}, true},
+		{"indexes of shuffled options", []any{1.0, 2.0, 0.0}, true},
+		{"pipe string", "triassic | jurassic | cretaceous", true},
+		{"displayed order", []any{"Cretaceous", "Triassic", "Jurassic"}, false},
+		{"swapped pair", []any{"Jurassic", "Triassic", "Cretaceous"}, false},
+		{"too short", []any{"Triassic", "Jurassic"}, false},
+		{"too long", []any{"Triassic", "Jurassic", "Cretaceous", "Triassic"}, false},
+		{"unknown item", []any{"Triassic", "Jurassic", "Permian"}, false},
+		{"not a list", 3.0, false},
+	}
+	for _, tt := range tests {
+		if got := gradeOrdering(q, tt.answer); got != tt.want {
+			t.Errorf("%s: gradeOrdering(%v) = %v, want %v", tt.name, tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestGradeMatching(t *testing.T) {
+	pairs := []db.MatchPair{
+		{Left: "France", Right: "Paris"},
+		{Left: "Japan", Right: "Tokyo"},
+		{Left: "Kenya", Right: "Nairobi"},
+		{Left: "Peru", Right: "Lima"},
+	}
+	q := db.Question{Type: db.QuestionMatching, Pairs: pairs}
+	tests := []struct {
+		name   string
+		answer any
+		want   bool
+		score  float64
+	}{
+		{
+			"all as map",
+			map[string]any{"France": "Paris", "Japan": "Tokyo", "Kenya": "Nairobi", "Peru": "Lima"},
+			true, 1,
+		},
+		{
+			"all as pair list",
+			[]any{
+				map[string]any{"left": "france", "right": "paris"},
+				map[string]any{"left": "Japan", "right": "Tokyo."},
+				map[string]any{"left": "Kenya", "right": "Nairobi"},
+				map[string]any{"left": "Peru", "right": "Lima"},
+			},
+			true, 1,
+		},
+		{"three of four", map[string]any{"France": "Paris", "Japan": "Tokyo", "Kenya": "Nairobi", "Peru": "Quito"}, false, 0.75},
+		{"half", map[string]any{"France": "Paris", "Japan": "Tokyo"}, false, 0.5},
+		{"swapped", map[string]any{"France": "Tokyo", "Japan": "Paris"}, false, 0},
+		{"unknown shape", "France=Paris", false, 0},
+	}
+	for _, tt := range tests {
+		got, score := gradeMatching(q, tt.answer)
+		if got != tt.want || score == nil || *score != tt.score {
+			t.Errorf("%s: gradeMatching = %v, %v; want %v, %v", tt.name, got, deref(score), tt.want, tt.score)
+		}
+	}
+
+	// Rows saved with only the plain copy of the key.
+	plain := db.Question{Type: db.QuestionMatching, CorrectAnswer: map[string]any{"France": "Paris", "Japan": "Tokyo"}}
+	if got, score := gradeMatching(plain, map[string]any{"France": "Paris", "Japan": "Tokyo"}); !got || *score != 1 {
+		t.Errorf("plain key: got %v, %v", got, deref(score))
+	}
+	if got, score := gradeMatching(db.Question{Type: db.QuestionMatching}, map[string]any{"a": "b"}); got || score != nil {
+		t.Errorf("no pairs: got %v, %v; want false, nil", got, deref(score))
+	}
+}
+
+func TestGradeShortAnswer(t *testing.T) {
+	q := db.Question{
+		Type:          db.QuestionShortAnswer,
+		CorrectAnswer: "Blue light scatters more off air molecules.",
+		Rubric: &db.Rubric{Criteria: []db.RubricCriterion{
+			{Criterion: "names scattering", Keywords: []string{"scatter", "Rayleigh"}},
+			{Criterion: "shorter wavelengths", Keywords: []string{"wavelength", "blue light"}},
+			{Criterion: "air molecules", Keywords: []string{"molecule", "nitrogen", "oxygen"}},
+		}},
+	}
+	tests := []struct {
+		name   string
+		answer string
+		want   bool
+		score  float64
+	}{
+		{"all criteria", "Rayleigh scattering: shorter wavelengths bounce off nitrogen molecules.", true, 1},
+		{"keyword prefix", "Scattering of blue light by molecules", true, 1},
+		{"two of three passes", "Blue light is scattered more.", true, 0.6667},
+		{"one of three fails", "Because of scattering.", false, 0.3333},
+		{"prefix only at word start", "Unscattered, a longwave beam", false, 0},
+		{"multi-word keyword", "BLUE   LIGHT, mostly", false, 0.3333},
+		{"none", "The sky reflects the ocean.", false, 0},
+	}
+	for _, tt := range tests {
+		got, score := gradeShortAnswer(q, tt.answer)
+		if got != tt.want || score == nil || *score != tt.score {
+			t.Errorf("%s: gradeShortAnswer = %v, %v; want %v, %v", tt.name, got, deref(score), tt.want, tt.score)
+		}
+	}
+
+	strict := q
+	strict.Rubric = &db.Rubric{Criteria: q.Rubric.Criteria, PassScore: 1}
+	if got, _ := gradeShortAnswer(strict, "Blue light is scattered more."); got {
+		t.Error("pass score 1 accepted two of three criteria")
+	}
+	if got, score := gradeShortAnswer(q, "   "); got || score != nil {
+		t.Errorf("blank answer: got %v, %v; want false, nil", got, deref(score))
+	}
+
+	noRubric := db.Question{Type: db.QuestionShortAnswer, CorrectAnswer: "Photosynthesis"}
+	if got, score := gradeShortAnswer(noRubric, "photosynthesis."); !got || score != nil {
+		t.Errorf("no rubric: got %v, %v; want a plain text match", got, deref(score))
+	}
+}
+
+func TestGradeNumeric(t *testing.T) {
+	q := func(value, tol float64) db.Question {
+		return db.Question{Type: db.QuestionNumeric, Numeric: &db.NumericAnswer{Value: value, Tolerance: tol}}
+	}
+	tests := []struct {
+		name   string
+		q      db.Question
+		answer any
+		want   bool
+	}{
+		{"exact number", q(9.81, 0), 9.81, true},
+		{"Go int is not a JSON number", q(11, 0), 11, false},
+		{"exact string", q(9.81, 0), "9.81", true},
+		{"off without tolerance", q(9.81, 0), 9.8, false},
+		{"inside tolerance", q(9.81, 0.05), 9.77, true},
+		{"at the tolerance edge", q(0.3, 0.1), 0.2, true},
+		{"at the edge, above", q(1200, 50), "1,250", true},
+		{"just past the edge", q(0.3, 0.1), 0.1999, false},
+		{"negative tolerance counts as positive", q(10, -1), 11.0, true},
+		{"thousands separators", q(1200300, 0), "1,200,300", true},
+		{"trailing unit", q(1200, 0), "1,200 m", true},
+		{"unit glued on", q(1200, 0), "1200eV", true},
+		{"dangling e", q(3, 0), "3e", true},
+		{"exponent", q(3000, 0), "3e3", true},
+		{"signed exponent", q(0.0025, 0), "2.5E-3 s", true},
+		{"decimal comma", q(3.5, 0), "3,5", true},
+		{"decimal comma is not thousands", q(35, 0), "3,5", false},
+		{"leading zero decimal comma", q(0.001, 0), "0,001", true},
+		{"separator then decimal point", q(1200.5, 0), "1,200.5", true},
+		{"negative", q(-40, 0), "-40 °C", true},
+		{"leading point", q(0.5, 0), ".5", true},
+		{"no number", q(0, 1), "about ten", false},
+		{"sign alone", q(0, 1), "-", false},
+		{"not a number type", q(1, 0), true, false},
+	}
+	for _, tt := range tests {
+		if got := gradeNumeric(tt.q, tt.answer); got != tt.want {
+			t.Errorf("%s: gradeNumeric(%v) = %v, want %v", tt.name, tt.answer, got, tt.want)
+		}
+	}
+
+	// Without a Numeric block the plain copy is the key.
+	plain := db.Question{Type: db.QuestionNumeric, CorrectAnswer: "42"}
+	if !gradeNumeric(plain, 42.0) || gradeNumeric(plain, 41.0) {
+		t.Error("plain key not compared exactly")
+	}
+}
+
+func TestAsNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"42", 42, true},
+		{" +7 ", 7, true},
+		{"1200eV", 1200, true},
+		{"3e", 3, true},
+		{"3e+", 3, true},
+		{"1e5", 1e5, true},
+		{"3,5", 3.5, true},
+		{"3,50", 3.5, true},
+		{"3,500", 3500, true},
+		{"1,2345", 1.2345, true},
+		{"0,001", 0.001, true},
+		{"5.", 5, true},
+		{"5.m", 5, true},
+		{"", 0, false},
+		{"e5", 0, false},
+		{"km 5", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := asNumber(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("asNumber(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func deref(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
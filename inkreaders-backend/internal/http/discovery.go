@@ -4,32 +4,39 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
 )
 
+// TrendingBooks - GET /api/discover/trending-books?window=24h|7d|30d&limit=&cursor=
 func (h *Handlers) TrendingBooks(w http.ResponseWriter, r *http.Request) {
-	limit := int32(20)
-	if s := r.URL.Query().Get("limit"); s != "" {
+	q := r.URL.Query()
+	window := q.Get("window")
+	if window == "" {
+		window = "24h"
+	}
+	if !db.TrendingWindows[window] {
+		BadRequest(w, "invalid_window")
+		return
+	}
+	limit := 20
+	if s := q.Get("limit"); s != "" {
 		if v, err := strconv.Atoi(s); err == nil && v > 0 && v <= 100 {
-			limit = int32(v)
+			limit = v
 		}
 	}
-	items, err := h.Store.TrendingBooksLast24h(r.Context(), limit)
+
+	items, next, err := h.Store.TrendingBooks(r.Context(), window, limit, q.Get("cursor"))
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	type outItem struct {
-		BookID  int64    `json:"bookId"`
-		Title   string   `json:"title"`
-		Authors []string `json:"authors"`
-		Link    *string  `json:"link,omitempty"`
-		Count   int64    `json:"count"`
-	}
-	resp := make([]outItem, 0, len(items))
-	for _, it := range items {
-		resp = append(resp, outItem{
-			BookID: it.BookID, Title: it.Title, Authors: it.Authors, Link: it.Link, Count: it.Count,
-		})
+	if items == nil {
+		items = []db.ScoredBook{}
 	}
-	_ = json.NewEncoder(w).Encode(map[string]any{"items": resp})
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"window":     window,
+		"items":      items,
+		"nextCursor": next,
+	})
 }
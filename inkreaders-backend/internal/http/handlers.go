@@ -15,6 +15,8 @@ import (
 	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/extract"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/live"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/types"
 )
 
@@ -30,9 +32,14 @@ type Handlers struct {
 
 	// Exercise-related deps
 	AI      ai.Client
+	Embed   ai.Embedder
+	Live    *live.Hub
 	Pub     Publisher
 	Storage Storage
 	Extract Extractor
+
+	// Quota caps each user's AI token spend.
+	Quota AIQuota
 }
 
 // Interfaces --------------------------------------------------------
@@ -40,12 +47,13 @@ type Handlers struct {
 type Publisher interface {
     PublishExerciseSet(ctx context.Context, s *SessionData, set db.ExerciseSet, allowRemix bool) (string, string, string, error)
     CreateExercisePost(ctx context.Context, s *SessionData, exerciseURI, exerciseCID, title string, previewCount int) error
+    AnnounceEvent(ctx context.Context, s *SessionData, ev db.Event) (string, error)
 }
 
 
 
 type Extractor interface {
-	PlainText(mime string, blob []byte) string
+	Extract(mime string, blob []byte) (extract.Document, error)
 }
 
 type Storage interface {
@@ -55,16 +63,19 @@ type Storage interface {
 
 // Constructor -------------------------------------------------------
 
-func NewHandlers(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Client, pub Publisher, st Storage, ex Extractor) *Handlers {
+func NewHandlers(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Client, emb ai.Embedder, hub *live.Hub, pub Publisher, st Storage, ex Extractor) *Handlers {
 	return &Handlers{
 		agent:   agent,
 		did:     did,
 		Store:   store,
 		DB:      store,
 		AI:      aiClient,
+		Embed:   emb,
+		Live:    hub,
 		Pub:     pub,
 		Storage: st,
 		Extract: ex,
+		Quota:   AIQuotaFromEnv(),
 	}
 }
 
@@ -115,6 +126,7 @@ func (h *Handlers) DbSample(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.Store.Pool.Query(r.Context(), `
 		SELECT uri, collection, created_at, book_id
 		FROM posts
+		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT 20`)
 	if err != nil {
@@ -146,6 +158,17 @@ func (h *Handlers) PostBook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad json", 400)
 		return
 	}
+	uri, cid, err := h.createBookPost(ctx, in)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"uri": uri, "cid": cid})
+}
+
+// createBookPost writes a com.inkreaders.book.post record to the app repo.
+// The indexer picks it up from there.
+func (h *Handlers) createBookPost(ctx context.Context, in types.PostBookIn) (string, string, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	body := map[string]any{
@@ -173,10 +196,9 @@ func (h *Handlers) PostBook(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := h.agent.Do(ctx, xrpc.Procedure, "application/json",
 		"com.atproto.repo.createRecord", nil, body, &out); err != nil {
-		http.Error(w, err.Error(), 500)
-		return
+		return "", "", err
 	}
-	_ = json.NewEncoder(w).Encode(out)
+	return out.URI, out.CID, nil
 }
 
 func (h *Handlers) PostArticle(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,179 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+// getPostsBatch is the most URIs app.bsky.feed.getPosts accepts per call.
+const getPostsBatch = 25
+
+// bookmarkCollection is the only record type that can be bookmarked.
+const bookmarkCollection = syntax.NSID("app.bsky.feed.post")
+
+type bookmarkReq struct {
+	URI string `json:"uri"`
+}
+
+type bookmarkItem struct {
+	PostURI   string          `json:"post_uri"`
+	CreatedAt time.Time       `json:"created_at"`
+	Post      json.RawMessage `json:"post,omitempty"`
+	// Deleted is set when the post no longer resolves upstream; the
+	// bookmark is kept so the user can see and remove it.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// === Bookmarks ===
+
+// BookmarksCreate - POST /api/bookmarks {"uri": "at://..."}
+func (h *Handlers) BookmarksCreate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	var req bookmarkReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	aturi, err := syntax.ParseATURI(strings.TrimSpace(req.URI))
+	if err != nil || aturi.Collection() != bookmarkCollection || aturi.RecordKey() == "" {
+		BadRequest(w, "invalid_uri")
+		return
+	}
+	uri, err := h.postURIByDID(r.Context(), aturi)
+	var xe *xrpc.Error
+	if errors.As(err, &xe) && xe.StatusCode == http.StatusBadRequest {
+		BadRequest(w, "unknown_handle")
+		return
+	} else if err != nil {
+		log.Printf("[bookmarks] resolve %s: %v", aturi.Authority(), err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	b, err := h.Store.AddBookmark(r.Context(), s.UserID, uri)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusCreated, map[string]any{"bookmark": b})
+}
+
+// BookmarksDelete - DELETE /api/bookmarks?uri=at://...
+// The URI may also be sent as a JSON body, like POST.
+func (h *Handlers) BookmarksDelete(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	uri := r.URL.Query().Get("uri")
+	if uri == "" {
+		var req bookmarkReq
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		uri = req.URI
+	}
+	uri = strings.TrimSpace(uri)
+	if uri == "" {
+		BadRequest(w, "uri_required")
+		return
+	}
+	ok, err := h.Store.DeleteBookmark(r.Context(), s.UserID, uri)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	if !ok {
+		NotFound(w)
+		return
+	}
+	NoContent(w)
+}
+
+// BookmarksList - GET /api/bookmarks?limit=&cursor=
+// Each page is hydrated into post views via app.bsky.feed.getPosts.
+func (h *Handlers) BookmarksList(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	limit := 25
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	marks, next, err := h.Store.ListBookmarks(ctx, s.UserID, limit, q.Get("cursor"))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+
+	uris := make([]string, len(marks))
+	for i, m := range marks {
+		uris[i] = m.PostURI
+	}
+	views, err := h.hydratePosts(ctx, uris)
+	if err != nil {
+		log.Printf("[bookmarks] getPosts: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	items := make([]bookmarkItem, len(marks))
+	for i, m := range marks {
+		items[i] = bookmarkItem{PostURI: m.PostURI, CreatedAt: m.CreatedAt}
+		if v, ok := views[m.PostURI]; ok {
+			items[i].Post = v
+		} else {
+			items[i].Deleted = true
+		}
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items, "nextCursor": next})
+}
+
+// postURIByDID returns the post URI with a handle authority swapped for the
+// DID it resolves to, so a post is stored under one URI however it was
+// referred to and stays valid if the author changes handle.
+func (h *Handlers) postURIByDID(ctx context.Context, u syntax.ATURI) (string, error) {
+	id := u.Authority()
+	if id.IsDID() {
+		return u.Normalize().String(), nil
+	}
+	var resp struct {
+		DID string `json:"did"`
+	}
+	params := map[string]any{"handle": id.String()}
+	if err := h.agent.Do(ctx, xrpc.Query, "", "com.atproto.identity.resolveHandle", params, nil, &resp); err != nil {
+		return "", err
+	}
+	did, err := syntax.ParseDID(resp.DID)
+	if err != nil {
+		return "", fmt.Errorf("resolveHandle %s: %w", id, err)
+	}
+	return fmt.Sprintf("at://%s/%s/%s", did, u.Collection(), u.RecordKey()), nil
+}
+
+// hydratePosts resolves post URIs to app.bsky.feed.defs#postView JSON, in
+// batches of getPostsBatch. URIs missing from the result were deleted (or
+// are otherwise unavailable) upstream.
+func (h *Handlers) hydratePosts(ctx context.Context, uris []string) (map[string]json.RawMessage, error) {
+	out := make(map[string]json.RawMessage, len(uris))
+	for start := 0; start < len(uris); start += getPostsBatch {
+		end := min(start+getPostsBatch, len(uris))
+		var resp struct {
+			Posts []json.RawMessage `json:"posts"`
+		}
+		params := map[string]any{"uris": uris[start:end]}
+		if err := h.agent.Do(ctx, xrpc.Query, "", "app.bsky.feed.getPosts", params, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Posts {
+			var head struct {
+				URI string `json:"uri"`
+			}
+			if json.Unmarshal(p, &head) == nil && head.URI != "" {
+				out[head.URI] = p
+			}
+		}
+	}
+	return out, nil
+}
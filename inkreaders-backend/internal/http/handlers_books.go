@@ -0,0 +1,56 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/catalog"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// === Books (catalog) ===
+
+// GetBook - GET /api/books/{id}
+func (h *Handlers) GetBook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(Param(r, "id"), 10, 64)
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return
+	}
+	b, err := h.Store.GetBook(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		NotFound(w)
+		return
+	} else if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"book": b})
+}
+
+// FindBooks - GET /api/books?isbn=
+// Accepts ISBN-10 or ISBN-13 in any formatting.
+func (h *Handlers) FindBooks(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("isbn")
+	if raw == "" {
+		BadRequest(w, "isbn_required")
+		return
+	}
+	isbn10, isbn13, err := catalog.NormalizeISBN(raw)
+	if err != nil {
+		BadRequest(w, "invalid_isbn")
+		return
+	}
+	items := []db.Book{}
+	b, err := h.Store.GetBookByISBN(r.Context(), isbn10, isbn13)
+	switch {
+	case err == nil:
+		items = append(items, b)
+	case !errors.Is(err, pgx.ErrNoRows):
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
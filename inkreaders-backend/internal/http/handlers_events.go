@@ -0,0 +1,391 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ical"
+)
+
+var rsvpStatuses = map[string]bool{db.RSVPGoing: true, db.RSVPMaybe: true, db.RSVPDeclined: true}
+
+// eventReq is shared by create and update; on update only the fields that
+// are present are applied.
+type eventReq struct {
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Location    *string    `json:"location,omitempty"`
+	URL         *string    `json:"url,omitempty"`
+	BookID      *int64     `json:"book_id,omitempty"`
+	TopicID     *uuid.UUID `json:"topic_id,omitempty"`
+	// Announce posts an app.bsky.feed.post linking to the event.
+	Announce bool `json:"announce,omitempty"`
+}
+
+// === Events (book club) ===
+
+// EventsList - GET /api/events?from=&mine=true&limit=&cursor=
+// from defaults to now, so past events drop off the list.
+func (h *Handlers) EventsList(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	q := r.URL.Query()
+	from := time.Now()
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			BadRequest(w, "invalid_from")
+			return
+		}
+		from = t
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	items, next, err := h.Store.ListEvents(r.Context(), s.UserID, from, q.Get("mine") == "true", limit, q.Get("cursor"))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items, "nextCursor": next})
+}
+
+// EventsCreate - POST /api/events
+func (h *Handlers) EventsCreate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	var req eventReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Title == nil || strings.TrimSpace(*req.Title) == "" {
+		BadRequest(w, "title_required")
+		return
+	}
+	if req.StartsAt == nil {
+		BadRequest(w, "starts_at_required")
+		return
+	}
+	ev := db.Event{CreatedBy: s.UserID.String()}
+	if !h.applyEventReq(w, r, s, &ev, req) {
+		return
+	}
+	if err := h.Store.CreateEvent(ctx, &ev); err != nil {
+		ServerError(w, err)
+		return
+	}
+	// A failed announcement leaves the event unannounced; it can be
+	// retried via /announce.
+	if req.Announce {
+		if err := h.announceEvent(ctx, s, &ev); err != nil {
+			log.Printf("[events] announce %s: %v", ev.ID, err)
+		}
+	}
+	WriteJSON(w, http.StatusCreated, map[string]any{"event": ev})
+}
+
+// EventsGet - GET /api/events/{id}
+// Includes the RSVP list.
+func (h *Handlers) EventsGet(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ev, ok := h.loadEvent(w, r, s)
+	if !ok {
+		return
+	}
+	rsvps, err := h.Store.ListRSVPs(r.Context(), ev.ID)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"event": ev, "rsvps": rsvps})
+}
+
+// EventsUpdate - PATCH /api/events/{id} (creator only)
+func (h *Handlers) EventsUpdate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ev, ok := h.loadEvent(w, r, s)
+	if !ok {
+		return
+	}
+	if ev.CreatedBy != s.UserID.String() {
+		Forbidden(w)
+		return
+	}
+	var req eventReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		BadRequest(w, "title_required")
+		return
+	}
+	if !h.applyEventReq(w, r, s, &ev, req) {
+		return
+	}
+	if err := h.Store.UpdateEvent(r.Context(), &ev); err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"event": ev})
+}
+
+// EventsDelete - DELETE /api/events/{id} (creator only)
+func (h *Handlers) EventsDelete(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	id, err := uuid.Parse(Param(r, "id"))
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return
+	}
+	ok, err := h.Store.DeleteEvent(r.Context(), id, s.UserID)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	if !ok {
+		NotFound(w)
+		return
+	}
+	NoContent(w)
+}
+
+// EventsAnnounce - POST /api/events/{id}/announce (creator only)
+func (h *Handlers) EventsAnnounce(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ev, ok := h.loadEvent(w, r, s)
+	if !ok {
+		return
+	}
+	if ev.CreatedBy != s.UserID.String() {
+		Forbidden(w)
+		return
+	}
+	if ev.AnnounceURI != nil {
+		WriteJSON(w, http.StatusConflict, map[string]any{"error": "already_announced", "event": ev})
+		return
+	}
+	if err := h.announceEvent(r.Context(), s, &ev); err != nil {
+		log.Printf("[events] announce %s: %v", ev.ID, err)
+		http.Error(w, "announce failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"event": ev})
+}
+
+// EventsRSVP - PUT /api/events/{id}/rsvp {"status": "going"|"maybe"|"declined"}
+func (h *Handlers) EventsRSVP(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ev, ok := h.loadEvent(w, r, s)
+	if !ok {
+		return
+	}
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if !rsvpStatuses[req.Status] {
+		BadRequest(w, "invalid_status")
+		return
+	}
+	if err := h.Store.SetRSVP(r.Context(), ev.ID, s.UserID, req.Status); err != nil {
+		ServerError(w, err)
+		return
+	}
+	ev, err := h.Store.GetEvent(r.Context(), ev.ID, s.UserID)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"event": ev})
+}
+
+// EventsRSVPDelete - DELETE /api/events/{id}/rsvp
+func (h *Handlers) EventsRSVPDelete(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	id, err := uuid.Parse(Param(r, "id"))
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return
+	}
+	if err := h.Store.DeleteRSVP(r.Context(), id, s.UserID); err != nil {
+		ServerError(w, err)
+		return
+	}
+	NoContent(w)
+}
+
+// EventsCalendarToken - GET /api/events/calendar-token?rotate=true
+// Returns the secret feed URL for calendar apps, which can't send cookies.
+func (h *Handlers) EventsCalendarToken(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	tok, err := h.Store.CalendarToken(r.Context(), s.UserID, r.URL.Query().Get("rotate") == "true")
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"token": tok,
+		"path":  "/api/events/calendar.ics?token=" + tok,
+	})
+}
+
+// EventsCalendar - GET /api/events/calendar.ics[?token=]
+// The user's events (created, going or maybe) as iCalendar. Authenticated
+// by session or by the calendar token.
+func (h *Handlers) EventsCalendar(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	var userID uuid.UUID
+	if tok := r.URL.Query().Get("token"); tok != "" {
+		id, err := h.Store.UserForCalendarToken(ctx, tok)
+		if errors.Is(err, pgx.ErrNoRows) {
+			NotFound(w)
+			return
+		} else if err != nil {
+			ServerError(w, err)
+			return
+		}
+		userID = id
+	} else if s != nil {
+		userID = s.UserID
+	} else {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Keep a month of history so recently past events don't vanish.
+	events, err := h.Store.CalendarEvents(ctx, userID, time.Now().AddDate(0, -1, 0))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	cal := ical.Calendar{ProdID: "-//InkReaders//Book Club//EN", Name: "InkReaders book club"}
+	for _, ev := range events {
+		ce := ical.Event{
+			UID:      ev.ID.String() + "@inkreaders.app",
+			Summary:  ev.Title,
+			Start:    ev.StartsAt,
+			Created:  ev.CreatedAt,
+			Modified: ev.UpdatedAt,
+			URL:      fmt.Sprintf("https://inkreaders.app/events/%s", ev.ID),
+			Status:   "CONFIRMED",
+		}
+		if ev.EndsAt != nil {
+			ce.End = *ev.EndsAt
+		}
+		if ev.Description != nil {
+			ce.Description = *ev.Description
+		}
+		if ev.Location != nil {
+			ce.Location = *ev.Location
+		}
+		if ev.MyRSVP != nil && *ev.MyRSVP == db.RSVPMaybe {
+			ce.Status = "TENTATIVE"
+		}
+		cal.Events = append(cal.Events, ce)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="inkreaders.ics"`)
+	if err := cal.Write(w); err != nil {
+		log.Printf("[events] write calendar: %v", err)
+	}
+}
+
+// loadEvent parses {id} and loads the event, writing the error response
+// when it returns false.
+func (h *Handlers) loadEvent(w http.ResponseWriter, r *http.Request, s *SessionData) (db.Event, bool) {
+	id, err := uuid.Parse(Param(r, "id"))
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return db.Event{}, false
+	}
+	ev, err := h.Store.GetEvent(r.Context(), id, s.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		NotFound(w)
+		return ev, false
+	} else if err != nil {
+		ServerError(w, err)
+		return ev, false
+	}
+	return ev, true
+}
+
+// applyEventReq validates req and copies the present fields onto ev. An
+// attached book must exist in the catalog; an attached topic must belong to
+// the caller.
+func (h *Handlers) applyEventReq(w http.ResponseWriter, r *http.Request, s *SessionData, ev *db.Event, req eventReq) bool {
+	ctx := r.Context()
+	if req.Title != nil {
+		ev.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Description != nil {
+		ev.Description = req.Description
+	}
+	if req.StartsAt != nil {
+		ev.StartsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		ev.EndsAt = req.EndsAt
+	}
+	if req.Location != nil {
+		ev.Location = req.Location
+	}
+	if req.URL != nil {
+		ev.URL = req.URL
+	}
+	if ev.EndsAt != nil && ev.EndsAt.Before(ev.StartsAt) {
+		BadRequest(w, "ends_before_start")
+		return false
+	}
+	if req.BookID != nil {
+		if _, err := h.Store.GetBook(ctx, *req.BookID); errors.Is(err, pgx.ErrNoRows) {
+			BadRequest(w, "unknown_book")
+			return false
+		} else if err != nil {
+			ServerError(w, err)
+			return false
+		}
+		ev.BookID = req.BookID
+	}
+	if req.TopicID != nil {
+		owner, err := h.Store.TopicOwner(ctx, *req.TopicID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			BadRequest(w, "unknown_topic")
+			return false
+		} else if err != nil {
+			ServerError(w, err)
+			return false
+		}
+		if owner != s.AccountID {
+			Forbidden(w)
+			return false
+		}
+		ev.TopicID = req.TopicID
+	}
+	return true
+}
+
+// announceEvent posts the announcement for an event and saves its URI. A
+// publisher that posts nothing (NoopPublisher) leaves the event unannounced.
+func (h *Handlers) announceEvent(ctx context.Context, s *SessionData, ev *db.Event) error {
+	uri, err := h.Pub.AnnounceEvent(ctx, s, *ev)
+	if err != nil {
+		return err
+	}
+	if uri == "" {
+		return nil
+	}
+	ev.AnnounceURI = &uri
+	if err := h.Store.UpdateEvent(ctx, ev); err != nil {
+		return fmt.Errorf("save announce uri: %w", err)
+	}
+	return nil
+}
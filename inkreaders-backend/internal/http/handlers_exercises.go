@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,12 +10,17 @@ import (
 	"io"
 	"bytes"       
 	"path/filepath"
+	"strconv"
 	"strings"
+	"slices"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/extract"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/grading"
 )
 
 type ExercisesGenerateReq struct {
@@ -27,6 +33,8 @@ type ExercisesGenerateReq struct {
 	} `json:"source"`
 	Text       string  `json:"text,omitempty"`
 	FileID     *string `json:"file_id,omitempty"`
+	PageFrom   int     `json:"page_from,omitempty"` // 1-based, inclusive; file sources only
+	PageTo     int     `json:"page_to,omitempty"`
 	Difficulty string  `json:"difficulty"`
 	Language   string  `json:"language"`
 	SeedSetID  *string `json:"seed_set_id,omitempty"`
@@ -49,6 +57,7 @@ func (h *Handlers) ExercisesGenerate(w http.ResponseWriter, r *http.Request, s *
 		BadRequest(w, "invalid_json")
 		return
 	}
+	ctx := h.aiContext(r, s)
 	if req.Count <= 0 || req.Count > 50 {
 		req.Count = 5
 	}
@@ -64,15 +73,86 @@ func (h *Handlers) ExercisesGenerate(w http.ResponseWriter, r *http.Request, s *
 	for i, f := range req.Formats {
 		req.Formats[i] = normalizeFormat(f)
 	}
+	// "mixed" asks for any question type.
+	if slices.Contains(req.Formats, db.FormatMixed) {
+		req.Formats = slices.Clone(db.QuestionTypes)
+	}
+
+	source := db.ExerciseSource{Type: req.Source.Type, Topic: req.Topic}
+	var doc *extract.Document
+	switch {
+	case req.FileID != nil && *req.FileID != "":
+		if _, err := uuid.Parse(*req.FileID); err != nil {
+			BadRequest(w, "invalid_file_id")
+			return
+		}
+		f, err := h.DB.GetFile(r.Context(), *req.FileID, s.UserID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			NotFound(w)
+			return
+		} else if err != nil {
+			ServerError(w, err)
+			return
+		}
+		blob, err := h.Storage.Read(r.Context(), f.StorageKey)
+		if err != nil {
+			log.Printf("[GENERATE] read file %s: %v", f.ID, err)
+			ServerError(w, err)
+			return
+		}
+		d, err := h.Extract.Extract(f.Mime, blob)
+		if err != nil {
+			log.Printf("[GENERATE] extract file %s: %v", f.ID, err)
+			BadRequest(w, "unsupported_or_unreadable_file")
+			return
+		}
+		doc = &d
+		source = db.ExerciseSource{
+			Type:     "file",
+			Topic:    req.Topic,
+			FileID:   parseUUIDPtr(req.FileID),
+			PageFrom: req.PageFrom,
+			PageTo:   req.PageTo,
+		}
+	case strings.TrimSpace(req.Text) != "":
+		doc = &extract.Document{Pages: []string{req.Text}}
+		source.Type = coalesce(source.Type, "text")
+	}
 
-	out, err := h.AI.Generate(r.Context(), ai.GenerateParams{
+	params := ai.GenerateParams{
 		Title:      req.Title,
 		Topic:      req.Topic,
 		Formats:    req.Formats,
 		Count:      req.Count,
 		Language:   req.Language,
 		Difficulty: req.Difficulty,
-	})
+	}
+	var (
+		out ai.GenerateOut
+		err error
+	)
+	if doc != nil {
+		chunks, cerr := doc.Chunks(req.PageFrom, req.PageTo, generateChunkChars)
+		if cerr != nil {
+			BadRequest(w, "invalid_page_range")
+			return
+		}
+		if len(chunks) == 0 {
+			BadRequest(w, "empty_source")
+			return
+		}
+		if source.Type == "file" {
+			source.PageFrom, source.PageTo = chunks[0].PageFrom, chunks[len(chunks)-1].PageTo
+		}
+		out, err = h.generateFromChunks(ctx, params, chunks)
+	} else {
+		out, err = h.AI.Generate(ctx, params)
+	}
+	var qe *quotaError
+	if errors.As(err, &qe) {
+		writeQuotaExceeded(w, qe)
+		return
+	}
 	if err != nil {
 		set := db.ExerciseSet{
 			ID:     uuid.New().String(),
@@ -83,17 +163,21 @@ func (h *Handlers) ExercisesGenerate(w http.ResponseWriter, r *http.Request, s *
 			Meta: db.ExerciseMeta{
 				Difficulty: req.Difficulty,
 				Language:   req.Language,
-				Source: db.ExerciseSource{Type: req.Source.Type, Topic: req.Topic},
+				Source: source,
 				SeedSetID: parseUUIDPtr(req.SeedSetID),
 			},
 			Visibility: "private",
 			CreatedAt:  time.Now(),
 		}
-		WriteJSON(w, http.StatusOK, map[string]any{
+		resp := map[string]any{
 			"exercise_set": set,
 			"error":        "generation_failed",
 			"message":      err.Error(),
-		})
+		}
+		if len(out.Errors) > 0 {
+			resp["question_errors"] = out.Errors
+		}
+		WriteJSON(w, http.StatusOK, resp)
 		return
 	}
 
@@ -106,14 +190,114 @@ func (h *Handlers) ExercisesGenerate(w http.ResponseWriter, r *http.Request, s *
 		Meta: db.ExerciseMeta{
 			Difficulty: req.Difficulty,
 			Language:   req.Language,
-			Source: db.ExerciseSource{Type: req.Source.Type, Topic: req.Topic},
+			Source: source,
 			SeedSetID: parseUUIDPtr(req.SeedSetID),
 		},
 		Visibility: "private",
 		CreatedAt:  time.Now(),
 	}
 
-	WriteJSON(w, http.StatusOK, map[string]any{"exercise_set": set})
+	// Questions that stayed invalid after repair are left out of the set
+	// and reported alongside it.
+	resp := map[string]any{"exercise_set": set}
+	if len(out.Errors) > 0 {
+		resp["question_errors"] = out.Errors
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+const (
+	generateChunkChars = 6000 // source text per model call
+	generateMaxChunks  = 5    // model calls per request
+)
+
+// generateFromChunks spreads the requested question count over up to
+// generateMaxChunks evenly spaced chunks, grounds each call in its chunk and
+// merges the results, including each call's question errors. It only fails
+// if every call fails. A call refused over quota cancels the others, and
+// the quota error is the one returned.
+func (h *Handlers) generateFromChunks(ctx context.Context, p ai.GenerateParams, chunks []extract.Chunk) (ai.GenerateOut, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := len(chunks)
+	if n > generateMaxChunks {
+		n = generateMaxChunks
+	}
+	if n > p.Count {
+		n = p.Count
+	}
+	picked := make([]extract.Chunk, n)
+	for i := range picked {
+		picked[i] = chunks[i*len(chunks)/n]
+	}
+
+	outs := make([]ai.GenerateOut, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range picked {
+		cp := p
+		cp.Count = p.Count / n
+		if i < p.Count%n {
+			cp.Count++
+		}
+		cp.SourceText = picked[i].Text
+		wg.Add(1)
+		go func(i int, cp ai.GenerateParams) {
+			defer wg.Done()
+			outs[i], errs[i] = h.AI.Generate(ctx, cp)
+			var qe *quotaError
+			if errors.As(errs[i], &qe) {
+				cancel()
+			}
+		}(i, cp)
+	}
+	wg.Wait()
+
+	var merged ai.GenerateOut
+	var firstErr error
+	var qe *quotaError
+	for i, o := range outs {
+		merged.Errors = append(merged.Errors, o.Errors...)
+		if errs[i] != nil {
+			log.Printf("[GENERATE] chunk pages %d-%d: %v", picked[i].PageFrom, picked[i].PageTo, errs[i])
+			if firstErr == nil || errors.As(errs[i], &qe) {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		if merged.InferredTitle == "" {
+			merged.InferredTitle = o.InferredTitle
+		}
+		merged.Questions = append(merged.Questions, o.Questions...)
+	}
+	if len(merged.Questions) == 0 {
+		if firstErr == nil {
+			firstErr = errors.New("no questions generated")
+		}
+		return ai.GenerateOut{Errors: merged.Errors}, firstErr
+	}
+	for i := range merged.Questions {
+		merged.Questions[i].ID = "q" + strconv.Itoa(i+1)
+		merged.Questions[i].OrderIndex = i
+	}
+	merged.InferredFormat = questionsFormat(merged.Questions)
+	return merged, nil
+}
+
+// questionsFormat is the set format for qs: their type if they share one,
+// otherwise mixed.
+func questionsFormat(qs []db.Question) string {
+	format := ""
+	for _, q := range qs {
+		switch {
+		case format == "":
+			format = q.Type
+		case q.Type != format:
+			return db.FormatMixed
+		}
+	}
+	return format
 }
 
 func parseUUIDPtr(s *string) *uuid.UUID {
@@ -313,7 +497,7 @@ func (h *Handlers) ExercisesRemix(w http.ResponseWriter, r *http.Request, s *Ses
 		ServerError(w, err)
 		return
 	}
-	derived, err := h.AI.Remix(r.Context(), ai.RemixParams{
+	derived, err := h.AI.Remix(h.aiContext(r, s), ai.RemixParams{
 		Parent:    *parent,
 		Transform: req.Transform.SwitchFormatTo,
 		Harder:    req.Transform.IncreaseDifficulty,
@@ -321,7 +505,7 @@ func (h *Handlers) ExercisesRemix(w http.ResponseWriter, r *http.Request, s *Ses
 		Note:      req.Note,
 	})
 	if err != nil {
-		ServerError(w, err)
+		aiFailed(w, err)
 		return
 	}
 	if u, err := uuid.Parse(parent.ID); err == nil {
@@ -354,16 +538,21 @@ func (h *Handlers) ExercisesUpload(w http.ResponseWriter, r *http.Request, s *Se
 		return
 	}
 	mime := DetectMIME(header.Filename, buf)
+	doc, err := h.Extract.Extract(mime, buf)
+	if err != nil {
+		log.Printf("[UPLOAD] extract failed name=%q mime=%s: %v", header.Filename, mime, err)
+		BadRequest(w, "unsupported_or_unreadable_file")
+		return
+	}
 	key := h.Storage.Put(r.Context(), generateStorageKey(s.UserID.String(), header.Filename), buf)
 
-	text := h.Extract.PlainText(mime, buf)
 	fileRow := db.File{
 		ID:         uuid.New().String(),
 		UserID:     s.UserID,
 		Mime:       mime,
 		StorageKey: key,
-		Pages:      countPages(mime, buf),
-		Chars:      len(text),
+		Pages:      doc.PageCount(),
+		Chars:      doc.Chars(),
 	}
 	if err := h.DB.InsertFile(r.Context(), &fileRow); err != nil {
 		ServerError(w, err)
@@ -372,6 +561,73 @@ func (h *Handlers) ExercisesUpload(w http.ResponseWriter, r *http.Request, s *Se
 	WriteJSON(w, http.StatusOK, map[string]any{"file_id": fileRow.ID, "mime": mime, "pages": fileRow.Pages, "chars": fileRow.Chars})
 }
 
+// === Submit an Attempt ===
+type ExerciseAttemptReq struct {
+	Answers map[string]any `json:"answers"`
+}
+
+func (h *Handlers) ExercisesAttemptCreate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	if s == nil {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	id := Param(r, "id")
+	var req ExerciseAttemptReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Answers == nil {
+		BadRequest(w, "answers_required")
+		return
+	}
+
+	set, err := h.DB.GetVisibleExerciseSet(r.Context(), id, s.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			NotFound(w)
+			return
+		}
+		ServerError(w, err)
+		return
+	}
+
+	graded := grading.GradeSet(set.Questions, req.Answers)
+	attempt := db.ExerciseAttempt{
+		ExerciseID: set.ID,
+		UserID:     s.UserID,
+		Answers:    req.Answers,
+		Results:    graded.Results,
+		Correct:    graded.Correct,
+		Total:      graded.Total,
+		Score:      graded.Score,
+	}
+	if err := h.DB.InsertExerciseAttempt(r.Context(), &attempt); err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusCreated, map[string]any{"attempt": attempt})
+}
+
+// === List my Attempts ===
+func (h *Handlers) ExercisesAttemptsList(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	if s == nil {
+		http.Error(w, "login required", http.StatusUnauthorized)
+		return
+	}
+	id := Param(r, "id")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	items, err := h.DB.ListExerciseAttempts(r.Context(), id, s.UserID, limit)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
 // === Explain a question ===
 func (h *Handlers) ExercisesExplain(w http.ResponseWriter, r *http.Request, s *SessionData) {
 	type reqBody struct {
@@ -388,9 +644,9 @@ func (h *Handlers) ExercisesExplain(w http.ResponseWriter, r *http.Request, s *S
 		BadRequest(w, "prompt_required")
 		return
 	}
-	expl, err := h.AI.Explain(r.Context(), req.QuestionID, req.Prompt, req.Answer)
+	expl, err := h.AI.Explain(h.aiContext(r, s), req.QuestionID, req.Prompt, req.Answer)
 	if err != nil {
-		ServerError(w, err)
+		aiFailed(w, err)
 		return
 	}
 	WriteJSON(w, http.StatusOK, map[string]any{"explanation": expl})
@@ -411,8 +667,18 @@ func normalizeFormat(f string) string {
 		return "true_false"
 	case "fillblank", "fill_blank", "fillBlank":
 		return "fill_blank"
+	case "multiselect", "multi_select", "multiSelect":
+		return db.QuestionMultiSelect
+	case "ordering", "order", "sequence":
+		return db.QuestionOrdering
 	case "match", "matching":
-		return "match"
+		return db.QuestionMatching
+	case "shortanswer", "short_answer", "shortAnswer":
+		return db.QuestionShortAnswer
+	case "numeric", "number":
+		return db.QuestionNumeric
+	case "mixed":
+		return db.FormatMixed
 	default:
 		return "mcq"
 	}
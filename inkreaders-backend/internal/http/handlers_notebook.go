@@ -3,13 +3,18 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
-	"fmt"
 	"github.com/google/uuid"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/jobs"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/render"
 )
 
 type createTopicIn struct {
@@ -60,6 +65,9 @@ func (h *Handlers) CreateTopic(w http.ResponseWriter, r *http.Request, s *Sessio
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
+	if !h.aiAllowed(w, r, s.UserID) {
+		return
+	}
 
 	// 1. Insert Topic
 	topic, err := h.Store.CreateTopic(ctx, s.AccountID, in.Title, in.Prompt, in.Tags, in.Meta)
@@ -87,26 +95,18 @@ func (h *Handlers) CreateTopic(w http.ResponseWriter, r *http.Request, s *Sessio
 		initialResp = db.Response{}
 	}
 
-	// 3. Kick off background AI generation
-	go func(respID uuid.UUID, prompt string) {
-		ctx2 := context.Background()
-		aiResp, err := h.AI.GenerateResponse(ctx2, prompt)
-		if err != nil {
-			fmt.Printf("[AI error] respID=%s prompt=%q err=%v\n", respID, prompt, err)
-			return
+	// 3. Queue AI generation; the job worker fills in the placeholder
+	if initialResp.ID != uuid.Nil {
+		if _, err := h.Store.EnqueueJob(ctx, jobs.TypeTopicResponse, jobs.TopicResponsePayload{
+			ResponseID: initialResp.ID,
+			Prompt:     in.Prompt,
+			UserID:     s.UserID,
+		}, 0); err != nil {
+			log.Printf("[notebook] enqueue response job respID=%s: %v", initialResp.ID, err)
+			_ = h.Store.FailResponse(ctx, initialResp.ID, err.Error())
+			initialResp.Status = "failed"
 		}
-
-		// TODO: sanitize Markdown -> HTML
-		html := aiResp
-
-		if err := h.Store.UpdateResponse(ctx2, respID, aiResp, html, map[string]any{
-			"source": "ai",
-			"prompt": prompt,
-		}); err != nil {
-			fmt.Printf("[AI error] failed to update response: %v\n", err)
-		}
-	}(initialResp.ID, in.Prompt)
-
+	}
 
 	// 4. Return immediately
 	w.Header().Set("Content-Type", "application/json")
@@ -132,10 +132,45 @@ func (h *Handlers) GetTopic(w http.ResponseWriter, r *http.Request, s *SessionDa
 		return
 	}
 
-	_ = json.NewEncoder(w).Encode(map[string]any{
+	out := map[string]any{
 		"topic":     topic,
 		"responses": responses,
-	})
+	}
+	// Related topics are a side panel; don't fail the page over them.
+	if related, err := h.Store.RelatedTopics(ctx, id, s.AccountID, relatedPanelSize); err == nil {
+		out["related"] = related
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[notebook] related topicID=%s: %v", id, err)
+	}
+
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// relatedPanelSize is how many related items GetTopic embeds.
+const relatedPanelSize = 5
+
+// --- GET /api/topics/{id}/related?limit=
+func (h *Handlers) RelatedTopics(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 20 {
+		limit = n
+	}
+
+	related, err := h.Store.RelatedTopics(ctx, id, s.AccountID, limit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(related)
 }
 
 func (h *Handlers) UpdateTopic(w http.ResponseWriter, r *http.Request, s *SessionData) {
@@ -209,6 +244,22 @@ func (h *Handlers) CreateResponse(w http.ResponseWriter, r *http.Request, s *Ses
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
+	if !h.topicOwned(w, r, topicID, s) {
+		return
+	}
+
+	// A follow-up must stay inside the topic's thread.
+	if in.ParentResponseID != nil {
+		parent, err := h.Store.GetResponse(ctx, *in.ParentResponseID)
+		if err != nil || parent.TopicID != topicID {
+			http.Error(w, "invalid parent_response_id", http.StatusBadRequest)
+			return
+		}
+	}
+	followUp := in.ParentResponseID != nil && strings.TrimSpace(in.Content) != ""
+	if followUp && !h.aiAllowed(w, r, s.UserID) {
+		return
+	}
 
 	authorType := "user"
 	raw := map[string]any{}
@@ -216,12 +267,67 @@ func (h *Handlers) CreateResponse(w http.ResponseWriter, r *http.Request, s *Ses
 		_ = json.Unmarshal(in.LLMOptions, &raw)
 	}
 
-	resp, err := h.Store.CreateResponse(ctx, topicID, in.ParentResponseID, authorType, in.Content, "", raw)
+	resp, err := h.Store.CreateResponse(ctx, topicID, in.ParentResponseID, authorType, in.Content, render.Markdown(in.Content), raw)
 	if err != nil {
 		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(resp)
+	h.enqueueEmbed(ctx, resp.ID)
+
+	out := createResponseOut{Response: resp}
+	if followUp {
+		out.Reply = h.queueFollowUp(ctx, topicID, resp, s.UserID)
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// topicOwned reports whether the session's account owns topicID, writing
+// 404 for an unknown topic and 403 for someone else's.
+func (h *Handlers) topicOwned(w http.ResponseWriter, r *http.Request, topicID uuid.UUID, s *SessionData) bool {
+	owner, err := h.Store.TopicOwner(r.Context(), topicID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		NotFound(w)
+		return false
+	} else if err != nil {
+		ServerError(w, err)
+		return false
+	}
+	if owner != s.AccountID {
+		Forbidden(w)
+		return false
+	}
+	return true
+}
+
+// createResponseOut is the created response with, for follow-ups, the AI
+// reply placeholder (stream it via /api/responses/{id}/stream).
+type createResponseOut struct {
+	db.Response
+	Reply *db.Response `json:"reply,omitempty"`
+}
+
+// queueFollowUp creates the AI reply placeholder under a follow-up question
+// and queues its generation with the thread as history, billed to userID.
+func (h *Handlers) queueFollowUp(ctx context.Context, topicID uuid.UUID, question db.Response, userID uuid.UUID) *db.Response {
+	reply, err := h.Store.CreateResponse(ctx, topicID, &question.ID, "ai", "Generating…", "", map[string]any{
+		"note":   "placeholder - follow-up answer will be generated async",
+		"prompt": question.Content,
+	})
+	if err != nil {
+		log.Printf("[notebook] follow-up placeholder respID=%s: %v", question.ID, err)
+		return nil
+	}
+	if _, err := h.Store.EnqueueJob(ctx, jobs.TypeTopicResponse, jobs.TopicResponsePayload{
+		ResponseID: reply.ID,
+		Prompt:     question.Content,
+		FollowUpOf: &question.ID,
+		UserID:     userID,
+	}, 0); err != nil {
+		log.Printf("[notebook] enqueue follow-up respID=%s: %v", reply.ID, err)
+		_ = h.Store.FailResponse(ctx, reply.ID, err.Error())
+		reply.Status = "failed"
+	}
+	return &reply
 }
 
 func (h *Handlers) GetResponse(w http.ResponseWriter, r *http.Request, s *SessionData) {
@@ -241,7 +347,9 @@ func (h *Handlers) GetResponse(w http.ResponseWriter, r *http.Request, s *Sessio
 }
 
 type updateResponseIn struct {
-	Content     string          `json:"content"`
+	Content string `json:"content"`
+	// ContentHTML is ignored; the stored HTML is always rendered from
+	// Content so clients can't store arbitrary markup.
 	ContentHTML string          `json:"content_html"`
 	Raw         json.RawMessage `json:"raw"`
 }
@@ -265,13 +373,24 @@ func (h *Handlers) UpdateResponse(w http.ResponseWriter, r *http.Request, s *Ses
 		_ = json.Unmarshal(in.Raw, &raw)
 	}
 
-	if err := h.Store.UpdateResponse(ctx, id, in.Content, in.ContentHTML, raw); err != nil {
+	if err := h.Store.UpdateResponse(ctx, id, in.Content, render.Markdown(in.Content), raw); err != nil {
 		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.enqueueEmbed(ctx, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// enqueueEmbed queues an embedding for a new or edited response. Failures
+// are only logged; the periodic sweep picks the row up later.
+func (h *Handlers) enqueueEmbed(ctx context.Context, respID uuid.UUID) {
+	if _, err := h.Store.EnqueueJob(ctx, jobs.TypeResponseEmbed, jobs.ResponseEmbedPayload{
+		ResponseIDs: []uuid.UUID{respID},
+	}, 3); err != nil {
+		log.Printf("[notebook] enqueue embed respID=%s: %v", respID, err)
+	}
+}
+
 
 // --- GET /api/responses/{id}/versions
 func (h *Handlers) ListResponseVersions(w http.ResponseWriter, r *http.Request, s *SessionData) {
@@ -0,0 +1,329 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+type progressReq struct {
+	ShelfID        *uuid.UUID `json:"shelf_id,omitempty"`
+	BookID         *int64     `json:"book_id,omitempty"`
+	Page           *int       `json:"page,omitempty"`
+	Percent        *float64   `json:"percent,omitempty"`
+	SessionSeconds *int       `json:"session_seconds,omitempty"`
+	Note           *string    `json:"note,omitempty"`
+	ReadAt         *time.Time `json:"read_at,omitempty"`
+}
+
+// progressClockSkew is how far ahead of the server clock a read_at may be,
+// to allow for a client whose clock runs a little fast.
+const progressClockSkew = 5 * time.Minute
+
+type goalReq struct {
+	Period      string `json:"period"` // "year" | "month"
+	Year        int    `json:"year"`
+	Month       int    `json:"month,omitempty"` // 1-12, month goals only
+	TargetBooks *int   `json:"target_books,omitempty"`
+	TargetPages *int   `json:"target_pages,omitempty"`
+}
+
+// === Reading progress ===
+
+// ProgressCreate - POST /api/progress
+func (h *Handlers) ProgressCreate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	var req progressReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Page == nil && req.Percent == nil {
+		BadRequest(w, "page_or_percent_required")
+		return
+	}
+	if (req.Page != nil && *req.Page < 0) || (req.Percent != nil && (*req.Percent < 0 || *req.Percent > 100)) ||
+		(req.SessionSeconds != nil && *req.SessionSeconds < 0) {
+		BadRequest(w, "invalid_progress")
+		return
+	}
+	if req.ReadAt != nil && req.ReadAt.After(time.Now().Add(progressClockSkew)) {
+		BadRequest(w, "read_at_in_future")
+		return
+	}
+	if req.BookID != nil {
+		if _, err := h.Store.GetBook(ctx, *req.BookID); errors.Is(err, pgx.ErrNoRows) {
+			BadRequest(w, "unknown_book")
+			return
+		} else if err != nil {
+			ServerError(w, err)
+			return
+		}
+	}
+
+	e := db.ProgressEntry{
+		UserID:         s.UserID,
+		ShelfID:        req.ShelfID,
+		BookID:         req.BookID,
+		Page:           req.Page,
+		Percent:        req.Percent,
+		SessionSeconds: req.SessionSeconds,
+		Note:           req.Note,
+	}
+	if req.ReadAt != nil {
+		e.ReadAt = *req.ReadAt
+	}
+
+	// Logging against a shelf item ties the entry to its book, and starts
+	// the book if it was only on the want list.
+	if req.ShelfID != nil {
+		it, err := h.Store.GetShelfItem(ctx, *req.ShelfID, s.UserID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			BadRequest(w, "unknown_shelf_item")
+			return
+		} else if err != nil {
+			ServerError(w, err)
+			return
+		}
+		if e.BookID == nil {
+			e.BookID = it.BookID
+		}
+		if it.Status == db.ShelfWant {
+			it.SetStatus(db.ShelfReading, time.Now())
+			if err := h.Store.UpdateShelfItem(ctx, &it); err != nil {
+				ServerError(w, err)
+				return
+			}
+		}
+	}
+
+	if err := h.Store.InsertProgress(ctx, &e); err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusCreated, map[string]any{"entry": e})
+}
+
+// ProgressList - GET /api/progress?shelf_id=&limit=&cursor=
+func (h *Handlers) ProgressList(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	q := r.URL.Query()
+	var shelfID *uuid.UUID
+	if v := q.Get("shelf_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			BadRequest(w, "invalid_shelf_id")
+			return
+		}
+		shelfID = &id
+	}
+	limit := 50
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 200 {
+		limit = v
+	}
+	items, next, err := h.Store.ListProgress(r.Context(), s.UserID, shelfID, limit, q.Get("cursor"))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items, "nextCursor": next})
+}
+
+// === Reading goals ===
+
+// GoalsPut - PUT /api/goals
+func (h *Handlers) GoalsPut(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	var req goalReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Year < 1900 || req.Year > 9999 {
+		BadRequest(w, "invalid_year")
+		return
+	}
+	g := db.ReadingGoal{Period: req.Period, TargetBooks: req.TargetBooks, TargetPages: req.TargetPages}
+	switch req.Period {
+	case "year":
+		g.PeriodStart = time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+	case "month":
+		if req.Month < 1 || req.Month > 12 {
+			BadRequest(w, "invalid_month")
+			return
+		}
+		g.PeriodStart = time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		BadRequest(w, "invalid_period")
+		return
+	}
+	if g.TargetBooks == nil && g.TargetPages == nil {
+		BadRequest(w, "target_required")
+		return
+	}
+	if (g.TargetBooks != nil && *g.TargetBooks < 0) || (g.TargetPages != nil && *g.TargetPages < 0) {
+		BadRequest(w, "invalid_target")
+		return
+	}
+	if err := h.Store.UpsertReadingGoal(r.Context(), s.UserID, &g); err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"goal": g})
+}
+
+// GoalsList - GET /api/goals?year=
+func (h *Handlers) GoalsList(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	year := time.Now().Year()
+	if v, err := strconv.Atoi(r.URL.Query().Get("year")); err == nil && v >= 1900 && v <= 9999 {
+		year = v
+	}
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	goals, err := h.Store.ListReadingGoals(r.Context(), s.UserID, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": goals})
+}
+
+// === Reading stats ===
+
+type goalProgress struct {
+	db.ReadingGoal
+	Books int `json:"books"`
+	Pages int `json:"pages"`
+}
+
+// ReadingStats - GET /api/stats/reading?year=&tz=
+// Streaks, pages per day and books finished per month, computed from the
+// progress log (and finished shelf items), in the caller's time zone.
+func (h *Handlers) ReadingStats(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	tz := q.Get("tz")
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		BadRequest(w, "invalid_tz")
+		return
+	}
+	now := time.Now().In(loc)
+	year := now.Year()
+	if v, err := strconv.Atoi(q.Get("year")); err == nil && v >= 1900 && v <= 9999 {
+		year = v
+	}
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(1, 0, 0)
+
+	days, err := h.Store.ReadingDays(ctx, s.UserID, tz)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	current, longest := readingStreaks(days, now)
+
+	pages, err := h.Store.DailyPages(ctx, s.UserID, tz, from, to)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	finished, err := h.Store.FinishedPerMonth(ctx, s.UserID, tz, from, to)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	goals, err := h.Store.ListReadingGoals(ctx, s.UserID,
+		time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+
+	// Average over the days elapsed in the year (all of it for past years).
+	totalPages := 0
+	for _, d := range pages {
+		totalPages += d.Count
+	}
+	elapsed := to.Sub(from).Hours() / 24
+	if now.Before(to) {
+		elapsed = now.Sub(from).Hours()/24 + 1
+	}
+	pagesPerDay := 0.0
+	if elapsed >= 1 {
+		pagesPerDay = float64(totalPages) / float64(int(elapsed))
+	}
+
+	progress := make([]goalProgress, 0, len(goals))
+	for _, g := range goals {
+		gp := goalProgress{ReadingGoal: g}
+		for _, m := range finished {
+			if g.Period == "year" || m.Day.Month() == g.PeriodStart.Month() {
+				gp.Books += m.Count
+			}
+		}
+		for _, d := range pages {
+			if g.Period == "year" || d.Day.Month() == g.PeriodStart.Month() {
+				gp.Pages += d.Count
+			}
+		}
+		progress = append(progress, gp)
+	}
+
+	booksFinished := 0
+	for _, m := range finished {
+		booksFinished += m.Count
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"year":                     year,
+		"tz":                       tz,
+		"current_streak":           current,
+		"longest_streak":           longest,
+		"total_pages":              totalPages,
+		"pages_per_day":            pagesPerDay,
+		"daily_pages":              pages,
+		"books_finished":           booksFinished,
+		"books_finished_per_month": finished,
+		"goals":                    progress,
+	})
+}
+
+// readingStreaks takes reading days newest first. The current streak counts
+// back from today, or from yesterday if nothing has been logged yet today.
+func readingStreaks(days []time.Time, now time.Time) (current, longest int) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	dayOf := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	run := 0
+	live := len(days) > 0 && today.Sub(dayOf(days[0])) <= 24*time.Hour
+	var prev time.Time
+	for i, d := range days {
+		d = dayOf(d)
+		if i > 0 && prev.Sub(d) == 24*time.Hour {
+			run++
+		} else {
+			if i > 0 {
+				live = false
+			}
+			run = 1
+		}
+		prev = d
+		if run > longest {
+			longest = run
+		}
+		if live {
+			current = run
+		}
+	}
+	return current, longest
+}
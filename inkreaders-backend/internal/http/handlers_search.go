@@ -0,0 +1,86 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+var searchKinds = map[string]bool{db.SearchTopic: true, db.SearchResponse: true, db.SearchHighlight: true}
+
+// === Search ===
+
+// Search - GET /api/search?q=&type=topic,response,highlight&limit=&cursor=
+// Full-text search over the caller's notebook. Ownership follows the topic
+// handlers: everything is scoped to s.AccountID.
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		BadRequest(w, "q_required")
+		return
+	}
+	var kinds []string
+	if v := q.Get("type"); v != "" {
+		for _, k := range strings.Split(v, ",") {
+			k = strings.TrimSpace(k)
+			if !searchKinds[k] {
+				BadRequest(w, "invalid_type")
+				return
+			}
+			kinds = append(kinds, k)
+		}
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+
+	items, next, err := h.Store.Search(r.Context(), s.AccountID, query, kinds, limit, q.Get("cursor"))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items, "nextCursor": next})
+}
+
+// SemanticSearch - GET /api/search/semantic?q=&weight=&limit=&cursor=
+// Ranks the caller's responses by embedding similarity blended with the
+// text rank. weight (0..1, default 0.7) is the share given to similarity.
+func (h *Handlers) SemanticSearch(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		BadRequest(w, "q_required")
+		return
+	}
+	weight := 0.7
+	if v := q.Get("weight"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			BadRequest(w, "invalid_weight")
+			return
+		}
+		weight = f
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+
+	vecs, err := h.Embed.Embed(r.Context(), []string{query})
+	if err != nil || len(vecs) != 1 {
+		log.Printf("[search] embed query: %v", err)
+		http.Error(w, "embedding failed", http.StatusBadGateway)
+		return
+	}
+	items, next, err := h.Store.SemanticSearch(r.Context(), s.AccountID, vecs[0], h.Embed.Model(), query, weight, limit, q.Get("cursor"))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items, "nextCursor": next})
+}
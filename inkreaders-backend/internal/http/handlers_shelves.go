@@ -0,0 +1,251 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/catalog"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/types"
+)
+
+var shelfStatuses = map[string]bool{db.ShelfWant: true, db.ShelfReading: true, db.ShelfFinished: true}
+
+type shelfCreateReq struct {
+	BookID *int64       `json:"book_id,omitempty"`
+	Book   types.BookIn `json:"book"`
+	Status string       `json:"status"`
+	Note   *string      `json:"note,omitempty"`
+}
+
+type shelfUpdateReq struct {
+	Status *string  `json:"status,omitempty"`
+	Note   *string  `json:"note,omitempty"`
+	Rating *float64 `json:"rating,omitempty"`
+	// Publish posts a com.inkreaders.book.post with the rating and Text
+	// for a finished book. It can be sent with the move to "finished" or
+	// later, but only once per item.
+	Publish bool   `json:"publish,omitempty"`
+	Text    string `json:"text,omitempty"`
+}
+
+// === Shelves ===
+
+// ShelvesList - GET /api/shelves?status=&limit=&cursor=
+func (h *Handlers) ShelvesList(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status != "" && !shelfStatuses[status] {
+		BadRequest(w, "invalid_status")
+		return
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	items, next, err := h.Store.ListShelf(r.Context(), s.UserID, status, limit, q.Get("cursor"))
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"items": items, "nextCursor": next})
+}
+
+// ShelvesCreate - POST /api/shelves
+func (h *Handlers) ShelvesCreate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	var req shelfCreateReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Status == "" {
+		req.Status = db.ShelfWant
+	}
+	if !shelfStatuses[req.Status] {
+		BadRequest(w, "invalid_status")
+		return
+	}
+
+	it := db.ShelfItem{UserID: s.UserID, Note: req.Note}
+	if req.BookID != nil {
+		b, err := h.Store.GetBook(ctx, *req.BookID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			BadRequest(w, "unknown_book")
+			return
+		} else if err != nil {
+			ServerError(w, err)
+			return
+		}
+		it.BookID, it.Title, it.Authors, it.ISBN = &b.ID, b.Title, b.Authors, b.ISBN13
+	} else {
+		it.Title = catalog.CleanTitle(req.Book.Title)
+		if it.Title == "" {
+			BadRequest(w, "title_required")
+			return
+		}
+		it.Authors = catalog.CleanAuthors(req.Book.Authors)
+		if _, isbn13 := catalog.ResolveISBNs(req.Book.ISBN10, req.Book.ISBN13); isbn13 != "" {
+			it.ISBN = &isbn13
+		}
+		// Link to the catalog when the book is already known there.
+		if id, err := h.Store.FindBookID(ctx, it.Title, it.Authors, req.Book.ISBN10, req.Book.ISBN13); err == nil {
+			it.BookID = &id
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			ServerError(w, err)
+			return
+		}
+	}
+	it.SetStatus(req.Status, time.Now())
+
+	if err := h.Store.CreateShelfItem(ctx, &it); errors.Is(err, db.ErrShelfExists) {
+		http.Error(w, "already_on_shelf", http.StatusConflict)
+		return
+	} else if err != nil {
+		ServerError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusCreated, map[string]any{"item": it})
+}
+
+// ShelvesGet - GET /api/shelves/{id}
+func (h *Handlers) ShelvesGet(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	it, ok := h.loadShelfItem(w, r, s)
+	if !ok {
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"item": it})
+}
+
+// ShelvesUpdate - PATCH /api/shelves/{id}
+func (h *Handlers) ShelvesUpdate(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	it, ok := h.loadShelfItem(w, r, s)
+	if !ok {
+		return
+	}
+	var req shelfUpdateReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid_json")
+		return
+	}
+	if req.Rating != nil && (*req.Rating < 0 || *req.Rating > 5) {
+		BadRequest(w, "invalid_rating")
+		return
+	}
+
+	if req.Status != nil {
+		if !shelfStatuses[*req.Status] {
+			BadRequest(w, "invalid_status")
+			return
+		}
+		it.SetStatus(*req.Status, time.Now())
+	}
+	if req.Note != nil {
+		it.Note = req.Note
+	}
+	if req.Rating != nil {
+		it.Rating = req.Rating
+	}
+
+	// The update is saved before posting, so a failed save never leaves a
+	// post behind; if posting fails, the client can retry the publish.
+	if req.Publish {
+		if it.Status != db.ShelfFinished {
+			BadRequest(w, "publish_requires_finished")
+			return
+		}
+		if it.PostURI != nil {
+			http.Error(w, "already_published", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.Store.UpdateShelfItem(ctx, &it); err != nil {
+		ServerError(w, err)
+		return
+	}
+
+	if req.Publish {
+		// Claim the publish so a concurrent request for the same item
+		// can't post a second time.
+		claimed, err := h.Store.ClaimShelfPublish(ctx, it.ID, s.UserID)
+		if err != nil {
+			ServerError(w, err)
+			return
+		}
+		if !claimed {
+			http.Error(w, "already_published", http.StatusConflict)
+			return
+		}
+		in := types.PostBookIn{
+			Text:   strings.TrimSpace(req.Text),
+			Rating: it.Rating,
+			Book:   types.BookIn{Title: it.Title, Authors: it.Authors},
+		}
+		if it.ISBN != nil {
+			in.Book.ISBN13 = *it.ISBN
+		}
+		done := 100.0
+		in.Progress = &done
+		uri, _, err := h.createBookPost(ctx, in)
+		if err != nil {
+			log.Printf("[shelves] publish %s: %v", it.ID, err)
+			if rerr := h.Store.ReleaseShelfPublish(context.WithoutCancel(ctx), it.ID, s.UserID); rerr != nil {
+				log.Printf("[shelves] release publish %s: %v", it.ID, rerr)
+			}
+			ServerError(w, err)
+			return
+		}
+		if err := h.Store.SetShelfPostURI(context.WithoutCancel(ctx), &it, uri); err != nil {
+			log.Printf("[shelves] save post uri %s (%s): %v", it.ID, uri, err)
+			ServerError(w, err)
+			return
+		}
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"item": it})
+}
+
+// ShelvesDelete - DELETE /api/shelves/{id}
+func (h *Handlers) ShelvesDelete(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	id, err := uuid.Parse(Param(r, "id"))
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return
+	}
+	ok, err := h.Store.DeleteShelfItem(r.Context(), id, s.UserID)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	if !ok {
+		NotFound(w)
+		return
+	}
+	NoContent(w)
+}
+
+func (h *Handlers) loadShelfItem(w http.ResponseWriter, r *http.Request, s *SessionData) (db.ShelfItem, bool) {
+	id, err := uuid.Parse(Param(r, "id"))
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return db.ShelfItem{}, false
+	}
+	it, err := h.Store.GetShelfItem(r.Context(), id, s.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		NotFound(w)
+		return it, false
+	} else if err != nil {
+		ServerError(w, err)
+		return it, false
+	}
+	return it, true
+}
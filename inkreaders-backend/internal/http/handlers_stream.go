@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/live"
+)
+
+const (
+	// streamPoll is how often the stream re-reads the response row, which
+	// catches completions the in-process hub never saw.
+	streamPoll = 2 * time.Second
+	// streamPing keeps proxies from closing an idle connection.
+	streamPing = 15 * time.Second
+)
+
+// === Response streaming (SSE) ===
+
+// StreamResponse - GET /api/responses/{id}/stream
+// Server-Sent Events for a response being generated:
+//
+//	event: delta  data: {"text": "..."}    next chunk
+//	event: reset  data: {}                 attempt failed, retrying; clear text
+//	event: done   data: <response JSON>    final persisted response
+//	event: error  data: <response JSON>    generation failed for good
+//
+// A finished response is sent as a single done event.
+func (h *Handlers) StreamResponse(w http.ResponseWriter, r *http.Request, s *SessionData) {
+	ctx := r.Context()
+	id, err := uuid.Parse(Param(r, "id"))
+	if err != nil {
+		BadRequest(w, "invalid_id")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	resp, err := h.Store.GetResponse(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		NotFound(w)
+		return
+	} else if err != nil {
+		ServerError(w, err)
+		return
+	}
+	if !h.topicOwned(w, r, resp.TopicID, s) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v any) {
+		b, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		flusher.Flush()
+	}
+	finish := func(resp db.Response) {
+		if resp.Status == "failed" {
+			send(live.EventError, resp)
+		} else {
+			send(live.EventDone, resp)
+		}
+	}
+
+	// Only AI responses are generated; user-written ones never leave pending.
+	if resp.Status != "pending" || resp.AuthorType != "ai" {
+		finish(resp)
+		return
+	}
+
+	replay, events, cancel := h.Live.Subscribe(id)
+	defer func() { cancel() }()
+	if replay != "" {
+		send(live.EventDelta, map[string]string{"text": replay})
+	}
+
+	poll := time.NewTicker(streamPoll)
+	defer poll.Stop()
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, open := <-events:
+			if !open {
+				// Finished, or we fell behind and were dropped.
+				if resp, err := h.Store.GetResponse(ctx, id); err == nil && resp.Status != "pending" {
+					finish(resp)
+					return
+				}
+				cancel()
+				replay, events, cancel = h.Live.Subscribe(id)
+				send(live.EventReset, map[string]string{})
+				if replay != "" {
+					send(live.EventDelta, map[string]string{"text": replay})
+				}
+				continue
+			}
+			switch ev.Type {
+			case live.EventDelta:
+				send(live.EventDelta, map[string]string{"text": ev.Text})
+			case live.EventReset:
+				send(live.EventReset, map[string]string{})
+			}
+		case <-poll.C:
+			if resp, err := h.Store.GetResponse(ctx, id); err == nil && resp.Status != "pending" {
+				finish(resp)
+				return
+			}
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
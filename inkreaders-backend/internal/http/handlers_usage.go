@@ -0,0 +1,187 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
+)
+
+// AIQuota caps the model tokens a user may spend per UTC day and month.
+// Zero means no cap.
+type AIQuota struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+}
+
+// AIQuotaFromEnv reads AI_QUOTA_DAILY_TOKENS and AI_QUOTA_MONTHLY_TOKENS.
+func AIQuotaFromEnv() AIQuota {
+	var q AIQuota
+	q.DailyTokens, _ = strconv.ParseInt(os.Getenv("AI_QUOTA_DAILY_TOKENS"), 10, 64)
+	q.MonthlyTokens, _ = strconv.ParseInt(os.Getenv("AI_QUOTA_MONTHLY_TOKENS"), 10, 64)
+	return q
+}
+
+// aiContext is the request context for AI calls: billed to the session's
+// user, past the result cache when the request has ?fresh=true, and gated
+// on the user's quota. Cached results are served even over quota.
+//
+// The quota is read once, by the first call that reaches the gate, and the
+// answer holds for the rest of the request: calls made in parallel (see
+// generateFromChunks) would otherwise each pass the same stale check.
+func (h *Handlers) aiContext(r *http.Request, s *SessionData) context.Context {
+	ctx := ai.WithUser(r.Context(), s.UserID)
+	if r.URL.Query().Get("fresh") == "true" {
+		ctx = ai.WithFresh(ctx)
+	}
+	var (
+		once sync.Once
+		qerr error
+	)
+	return ai.WithGate(ctx, func(ctx context.Context) error {
+		once.Do(func() {
+			if qe := h.quotaExceeded(ctx, s.UserID); qe != nil {
+				qerr = qe
+			}
+		})
+		return qerr
+	})
+}
+
+// quotaError is a user's spent token allowance for period.
+type quotaError struct {
+	Period  string
+	Used    int64
+	Limit   int64
+	ResetAt time.Time
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("ai quota exceeded: %d of %d tokens this %s", e.Used, e.Limit, e.Period)
+}
+
+// quotaExceeded returns the exhausted period if the user has no quota left
+// for another AI call, or nil. Usage that cannot be read does not block
+// the call.
+func (h *Handlers) quotaExceeded(ctx context.Context, userID uuid.UUID) *quotaError {
+	if h.Quota.DailyTokens <= 0 && h.Quota.MonthlyTokens <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	day, month, err := h.Store.AIUsageDayMonth(ctx, userID, now)
+	if err != nil {
+		log.Printf("[quota] usage user=%s: %v", userID, err)
+		return nil
+	}
+
+	switch {
+	case h.Quota.MonthlyTokens > 0 && month >= h.Quota.MonthlyTokens:
+		return &quotaError{Period: "month", Used: month, Limit: h.Quota.MonthlyTokens,
+			ResetAt: time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)}
+	case h.Quota.DailyTokens > 0 && day >= h.Quota.DailyTokens:
+		return &quotaError{Period: "day", Used: day, Limit: h.Quota.DailyTokens,
+			ResetAt: time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)}
+	}
+	return nil
+}
+
+// writeQuotaExceeded writes a 429 with Retry-After set to when the
+// exhausted period rolls over.
+func writeQuotaExceeded(w http.ResponseWriter, e *quotaError) {
+	retryAfter := int(time.Until(e.ResetAt).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	WriteJSON(w, http.StatusTooManyRequests, map[string]any{
+		"error":       "ai_quota_exceeded",
+		"period":      e.Period,
+		"used":        e.Used,
+		"limit":       e.Limit,
+		"retry_after": retryAfter,
+		"resets_at":   e.ResetAt,
+	})
+}
+
+// aiAllowed reports whether the user has quota left for another AI call,
+// writing the 429 if not. It is for calls that always reach the model;
+// cacheable ones are gated through aiContext instead.
+func (h *Handlers) aiAllowed(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if qe := h.quotaExceeded(r.Context(), userID); qe != nil {
+		writeQuotaExceeded(w, qe)
+		return false
+	}
+	return true
+}
+
+// aiFailed writes the response for a failed AI call: the 429 if the quota
+// gate stopped it, else a server error.
+func aiFailed(w http.ResponseWriter, err error) {
+	var qe *quotaError
+	if errors.As(err, &qe) {
+		writeQuotaExceeded(w, qe)
+		return
+	}
+	ServerError(w, err)
+}
+
+// AdminAIUsage - GET /api/admin/ai-usage?from=YYYY-MM-DD&to=YYYY-MM-DD
+// Totals by operation, model, day and top users. to is inclusive; the
+// default range is the last 30 days. Requires X-Admin-Token, like
+// /api/admin/needs-reauth.
+func (h *Handlers) AdminAIUsage(w http.ResponseWriter, r *http.Request) {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		http.Error(w, "admin endpoint not enabled", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Admin-Token") != adminToken {
+		Forbidden(w)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -30)
+	q := r.URL.Query()
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			BadRequest(w, "invalid_from")
+			return
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			BadRequest(w, "invalid_to")
+			return
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		BadRequest(w, "empty_range")
+		return
+	}
+	top := 20
+	if n, err := strconv.Atoi(q.Get("top")); err == nil && n > 0 && n <= 200 {
+		top = n
+	}
+
+	summary, err := h.Store.SummarizeAIUsage(r.Context(), from, to, top)
+	if err != nil {
+		ServerError(w, err)
+		return
+	}
+	summary.To = to.AddDate(0, 0, -1)
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"summary": summary,
+		"quota":   map[string]int64{"daily_tokens": h.Quota.DailyTokens, "monthly_tokens": h.Quota.MonthlyTokens},
+	})
+}
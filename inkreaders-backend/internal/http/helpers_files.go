@@ -2,28 +2,31 @@
 package http
 
 import (
-	"bytes"
 	"net/http"
+	"path/filepath"
 	"strings"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/extract"
 )
 
-// DetectMIME – naive MIME detection from filename or content
+// DetectMIME – MIME detection from the file extension, falling back to content sniffing
 func DetectMIME(filename string, data []byte) string {
-	if strings.HasSuffix(strings.ToLower(filename), ".pdf") {
-		return "application/pdf"
-	}
-	if strings.HasSuffix(strings.ToLower(filename), ".txt") {
-		return "text/plain"
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return extract.MimePDF
+	case ".docx":
+		return extract.MimeDOCX
+	case ".epub":
+		return extract.MimeEPUB
+	case ".html", ".htm":
+		return extract.MimeHTML
+	case ".xhtml":
+		return extract.MimeXHTML
+	case ".md", ".markdown":
+		return extract.MimeMarkdown
+	case ".txt":
+		return extract.MimeText
 	}
 	// Fallback
 	return http.DetectContentType(data)
 }
-
-// countPages – dummy PDF/text page counter (replace with real later)
-func countPages(mime string, data []byte) int {
-	if mime == "application/pdf" {
-		// naive: count "%Page" markers
-		return bytes.Count(data, []byte("/Page"))
-	}
-	return 1
-}
@@ -165,6 +165,63 @@ func (p *AtprotoPublisher) CreateExercisePost(
 	return nil
 }
 
+// AnnounceEvent posts an app.bsky.feed.post for a book-club event, with the
+// event link as a facet like PublishExerciseSet.
+func (p *AtprotoPublisher) AnnounceEvent(ctx context.Context, s *SessionData, ev db.Event) (string, error) {
+	link := fmt.Sprintf("https://inkreaders.app/events/%s", ev.ID)
+
+	feedText := fmt.Sprintf("📅 Book club: %s\n%s", ev.Title, ev.StartsAt.UTC().Format("Mon 2 Jan 2006, 15:04 MST"))
+	if ev.Location != nil && *ev.Location != "" {
+		feedText += " · " + *ev.Location
+	}
+	feedText += "\n\nRSVP: " + link
+
+	start := len(feedText) - len(link)
+	end := len(feedText)
+	facets := []map[string]any{
+		{
+			"index": map[string]any{
+				"byteStart": start,
+				"byteEnd":   end,
+			},
+			"features": []map[string]any{
+				{
+					"$type": "app.bsky.richtext.facet#link",
+					"uri":   link,
+				},
+			},
+		},
+	}
+
+	body := map[string]any{
+		"repo":       repoFor(s, p.appDID),
+		"collection": "app.bsky.feed.post",
+		"record": map[string]any{
+			"$type":     "app.bsky.feed.post",
+			"createdAt": time.Now().UTC().Format(time.RFC3339),
+			"text":      feedText,
+			"facets":    facets,
+		},
+	}
+
+	var out struct {
+		URI string `json:"uri"`
+		CID string `json:"cid"`
+	}
+	var err error
+	if s != nil {
+		err = doXrpcAuth(ctx, s, "com.atproto.repo.createRecord", body, &out)
+	} else {
+		err = p.agent.Do(ctx, xrpc.Procedure, "application/json",
+			"com.atproto.repo.createRecord", nil, body, &out)
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Printf("[DEBUG] Event announced: %s (cid=%s)", out.URI, out.CID)
+	return out.URI, nil
+}
+
 // helper: choose repo DID
 func repoFor(s *SessionData, appDID string) string {
 	if s != nil {
@@ -14,3 +14,7 @@ func (NoopPublisher) PublishExerciseSet(ctx context.Context, s *SessionData, set
 func (NoopPublisher) CreateExercisePost(ctx context.Context, s *SessionData, exerciseURI, title string, previewCount int) error {
     return nil
 }
+
+func (NoopPublisher) AnnounceEvent(ctx context.Context, s *SessionData, ev db.Event) (string, error) {
+    return "", nil
+}
@@ -13,9 +13,10 @@ import (
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/crypto"
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/live"
 )
 
-func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Client, pub Publisher, storage Storage, extract Extractor) *chi.Mux {
+func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Client, embedder ai.Embedder, hub *live.Hub, pub Publisher, storage Storage, extract Extractor) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(cors.Handler(cors.Options{
@@ -28,7 +29,7 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	}))
 
 	// Build handlers
-	h := NewHandlers(agent, did, store, aiClient, pub, storage, extract)
+	h := NewHandlers(agent, did, store, aiClient, embedder, hub, pub, storage, extract)
 
 	// --- Auth bootstrap ---
 	box, err := crypto.NewSecretBox(os.Getenv("APP_ENC_KEY"))
@@ -60,7 +61,9 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	// --- InkReaders custom posts ---
 	r.Post("/api/ink/post-book", h.PostBook)
 	r.Post("/api/ink/post-article", h.PostArticle)
-	// 🚨 Removed h.TrendingBooks (was missing in handlers.go)
+	r.Get("/api/discover/trending-books", h.TrendingBooks)
+	r.Get("/api/books", h.FindBooks)
+	r.Get("/api/books/{id}", h.GetBook)
 	r.Get("/healthz", h.Healthz)
 	r.Get("/api/ink/my-book-posts", h.MyBookPosts)
 	r.Get("/api/debug/db-sample", h.DbSample)
@@ -75,6 +78,23 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	r.Get("/api/bsky/timeline", auth.WithSessionOptional(h.Timeline))
 	r.Get("/api/debug/who", auth.WithSessionOptional(h.Who))
 
+	// --- Bookmarks ---
+	r.Get("/api/bookmarks", auth.WithSession(h.BookmarksList))
+	r.Post("/api/bookmarks", auth.WithSession(h.BookmarksCreate))
+	r.Delete("/api/bookmarks", auth.WithSession(h.BookmarksDelete))
+
+	// --- Events (book club) ---
+	r.Get("/api/events", auth.WithSession(h.EventsList))
+	r.Post("/api/events", auth.WithSession(h.EventsCreate))
+	r.Get("/api/events/calendar.ics", auth.WithSessionOptional(h.EventsCalendar))
+	r.Get("/api/events/calendar-token", auth.WithSession(h.EventsCalendarToken))
+	r.Get("/api/events/{id}", auth.WithSession(h.EventsGet))
+	r.Patch("/api/events/{id}", auth.WithSession(h.EventsUpdate))
+	r.Delete("/api/events/{id}", auth.WithSession(h.EventsDelete))
+	r.Post("/api/events/{id}/announce", auth.WithSession(h.EventsAnnounce))
+	r.Put("/api/events/{id}/rsvp", auth.WithSession(h.EventsRSVP))
+	r.Delete("/api/events/{id}/rsvp", auth.WithSession(h.EventsRSVPDelete))
+
 	// --- Profile & Prefs ---
 	r.Get("/api/profile", auth.WithSession(h.ProfileGet))
 	r.Put("/api/profile", auth.WithSession(h.ProfileUpdate))
@@ -83,9 +103,9 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	r.Post("/api/profile/sync-from-remote", auth.WithSession(h.ProfileSyncFromRemote))
 
 	// --- Exercises ---
-	r.Post("/api/exercises/generate", auth.WithSessionOptional(h.ExercisesGenerate))
-	// AI explanation for a single question (non-persistent). Session optional.
-	r.Post("/api/exercises/explain", auth.WithSessionOptional(h.ExercisesExplain))
+	r.Post("/api/exercises/generate", auth.WithSession(h.ExercisesGenerate))
+	// AI explanation for a single question (non-persistent). Metered per user.
+	r.Post("/api/exercises/explain", auth.WithSession(h.ExercisesExplain))
 	r.Post("/api/exercises/save", auth.WithSession(h.ExercisesSave))
 	r.Get("/api/exercises/mine", auth.WithSession(h.ExercisesMine))
 	r.Get("/api/exercises/{id}", auth.WithSession(h.ExercisesGet))
@@ -93,18 +113,36 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	r.Post("/api/exercises/{id}/publish", auth.WithSession(h.ExercisesPublish))
 	r.Post("/api/exercises/{id}/remix", auth.WithSession(h.ExercisesRemix))
 	r.Post("/api/exercises/uploads", auth.WithSession(h.ExercisesUpload))
+	r.Post("/api/exercises/{id}/attempts", auth.WithSession(h.ExercisesAttemptCreate))
+	r.Get("/api/exercises/{id}/attempts", auth.WithSession(h.ExercisesAttemptsList))
+
 
+	// --- Shelves ---
+	r.Get("/api/shelves", auth.WithSession(h.ShelvesList))
+	r.Post("/api/shelves", auth.WithSession(h.ShelvesCreate))
+	r.Get("/api/shelves/{id}", auth.WithSession(h.ShelvesGet))
+	r.Patch("/api/shelves/{id}", auth.WithSession(h.ShelvesUpdate))
+	r.Delete("/api/shelves/{id}", auth.WithSession(h.ShelvesDelete))
+
+	// --- Reading progress, goals & stats ---
+	r.Get("/api/progress", auth.WithSession(h.ProgressList))
+	r.Post("/api/progress", auth.WithSession(h.ProgressCreate))
+	r.Get("/api/goals", auth.WithSession(h.GoalsList))
+	r.Put("/api/goals", auth.WithSession(h.GoalsPut))
+	r.Get("/api/stats/reading", auth.WithSession(h.ReadingStats))
 
 	// --- Notebook (Topics) ---
 	r.Get("/api/topics", auth.WithSession(h.ListTopics))
 	r.Post("/api/topics", auth.WithSession(h.CreateTopic))
 	r.Get("/api/topics/{id}", auth.WithSession(h.GetTopic))
 	r.Patch("/api/topics/{id}", auth.WithSession(h.UpdateTopic))
+	r.Get("/api/topics/{id}/related", auth.WithSession(h.RelatedTopics))
 
 	// Responses
 	r.Get("/api/topics/{topic_id}/responses", auth.WithSessionOptional(h.ListResponses))
 	r.Post("/api/topics/{topic_id}/responses", auth.WithSession(h.CreateResponse))
 	r.Get("/api/responses/{id}", auth.WithSessionOptional(h.GetResponse))
+	r.Get("/api/responses/{id}/stream", auth.WithSession(h.StreamResponse))
 	r.Patch("/api/responses/{id}", auth.WithSession(h.UpdateResponse))
 
 	// Responses version
@@ -119,6 +157,10 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	r.Patch("/api/highlights/{id}", auth.WithSession(h.UpdateHighlight))
 	r.Delete("/api/highlights/{id}", auth.WithSession(h.DeleteHighlight))
 
+	// Search
+	r.Get("/api/search", auth.WithSession(h.Search))
+	r.Get("/api/search/semantic", auth.WithSession(h.SemanticSearch))
+
 	// in router.go — Auth routes (add these)
 	r.Get("/api/auth/oauth/{provider}/start", auth.OAuthStart)
 	r.Get("/api/auth/oauth/{provider}/callback", auth.OAuthCallback)
@@ -126,6 +168,7 @@ func NewRouter(agent *xrpc.Client, did string, store *db.Store, aiClient ai.Clie
 	r.Get("/api/auth/accounts", auth.ListAccounts)
 	r.Post("/api/auth/accounts/unlink", auth.UnlinkAccount)
 	r.Get("/api/admin/needs-reauth", auth.ListNeedsReauth)
+	r.Get("/api/admin/ai-usage", h.AdminAIUsage)
 
 	return r
 }
@@ -27,14 +27,3 @@ func (s *LocalStorage) Read(ctx context.Context, key string) ([]byte, error) {
     path := filepath.Join(s.base, key)
     return os.ReadFile(path)
 }
-
-// Extractor stub — just treats everything as plain text
-type SimpleExtractor struct{}
-
-func NewExtractor() *SimpleExtractor {
-    return &SimpleExtractor{}
-}
-
-func (e *SimpleExtractor) PlainText(mime string, blob []byte) string {
-    return string(blob) // naive fallback
-}
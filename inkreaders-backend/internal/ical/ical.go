@@ -0,0 +1,114 @@
+// Package ical writes minimal RFC 5545 calendars: one VCALENDAR holding
+// VEVENTs, with text escaping, 75-octet line folding and CRLF endings.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Start       time.Time
+	// End is optional; zero means a one-hour event.
+	End      time.Time
+	Created  time.Time
+	Modified time.Time
+	// Status is TENTATIVE for maybe-RSVPs, otherwise CONFIRMED.
+	Status string
+}
+
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+const stampLayout = "20060102T150405Z"
+
+// Write renders cal to w.
+func (cal Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", cal.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		line("X-WR-CALNAME", Escape(cal.Name))
+	}
+	now := time.Now()
+	for _, ev := range cal.Events {
+		end := ev.End
+		if end.IsZero() || end.Before(ev.Start) {
+			end = ev.Start.Add(time.Hour)
+		}
+		line("BEGIN", "VEVENT")
+		line("UID", ev.UID)
+		line("DTSTAMP", stamp(now))
+		line("DTSTART", stamp(ev.Start))
+		line("DTEND", stamp(end))
+		line("SUMMARY", Escape(ev.Summary))
+		if ev.Description != "" {
+			line("DESCRIPTION", Escape(ev.Description))
+		}
+		if ev.Location != "" {
+			line("LOCATION", Escape(ev.Location))
+		}
+		if ev.URL != "" {
+			line("URL", ev.URL)
+		}
+		if !ev.Created.IsZero() {
+			line("CREATED", stamp(ev.Created))
+		}
+		if !ev.Modified.IsZero() {
+			line("LAST-MODIFIED", stamp(ev.Modified))
+		}
+		if ev.Status != "" {
+			line("STATUS", ev.Status)
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format(stampLayout)
+}
+
+// Escape escapes a TEXT value: backslash, semicolon, comma and newlines.
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(s)
+}
+
+// writeFolded writes one content line, folding it so no physical line is
+// longer than 75 octets. Continuation lines start with a single space and
+// never split a UTF-8 sequence.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // the leading space counts
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
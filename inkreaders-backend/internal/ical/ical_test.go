@@ -0,0 +1,119 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"one\ntwo", `one\ntwo`},
+		{"one\r\ntwo\rthree", `one\ntwo\nthree`},
+		{`\;,`, `\\\;\,`},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.want {
+			t.Errorf("Escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func fold(s string) string {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeFolded(w, s)
+	w.Flush()
+	return buf.String()
+}
+
+func TestWriteFolded(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"short", "SUMMARY:hi", "SUMMARY:hi\r\n"},
+		{"exactly 75", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{"76", strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{
+			"continuations hold 74",
+			strings.Repeat("a", 75+74+1),
+			strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a\r\n",
+		},
+		{
+			// é is two octets and would straddle octet 75.
+			"no split rune",
+			strings.Repeat("a", 74) + "é",
+			strings.Repeat("a", 74) + "\r\n é\r\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := fold(tt.in); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFoldedLineLengths(t *testing.T) {
+	in := "DESCRIPTION:" + strings.Repeat("日本語のテキスト, ", 20)
+	out := fold(in)
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	for i, l := range lines {
+		if len(l) > 75 {
+			t.Errorf("line %d is %d octets", i, len(l))
+		}
+		if !utf8.ValidString(l) {
+			t.Errorf("line %d splits a rune: %q", i, l)
+		}
+		if i > 0 && !strings.HasPrefix(l, " ") {
+			t.Errorf("continuation line %d lacks leading space", i)
+		}
+	}
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", "")
+	if unfolded != in {
+		t.Errorf("unfolding does not round-trip")
+	}
+}
+
+func TestCalendarWrite(t *testing.T) {
+	start := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	cal := Calendar{
+		ProdID: "-//InkReaders//Events//EN",
+		Name:   "Club, Fiction",
+		Events: []Event{{
+			UID:      "ev1@inkreaders",
+			Summary:  "Dune; part 1",
+			Location: "Room 2\nLibrary",
+			Start:    start,
+			Status:   "CONFIRMED",
+		}},
+	}
+	var buf bytes.Buffer
+	if err := cal.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Club\\, Fiction\r\n",
+		"SUMMARY:Dune\\; part 1\r\n",
+		"LOCATION:Room 2\\nLibrary\r\n",
+		"DTSTART:20250301T180000Z\r\n",
+		"DTEND:20250301T190000Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(out, "\r\n", ""), "\n") {
+		t.Error("bare LF in output")
+	}
+}
@@ -0,0 +1,95 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/data"
+	"github.com/bluesky-social/indigo/events"
+	"github.com/bluesky-social/indigo/events/schedulers/sequential"
+	"github.com/bluesky-social/indigo/repo"
+	"github.com/gorilla/websocket"
+)
+
+const DefaultFirehoseHost = "wss://bsky.network"
+
+// RunFirehose indexes com.inkreaders.* records from every repo via
+// com.atproto.sync.subscribeRepos on a relay or PDS, resuming from the
+// "stream:firehose" cursor (a sequence number). It reconnects until ctx is
+// cancelled.
+func (ix *Indexer) RunFirehose(ctx context.Context, host string) error {
+	if host == "" {
+		host = DefaultFirehoseHost
+	}
+	saver := &cursorSaver{ix: ix, name: "stream:firehose", interval: 5 * time.Second}
+	return runForever(ctx, "firehose", func(ctx context.Context) error {
+		defer saver.Flush(context.Background())
+
+		u, err := url.Parse(strings.TrimRight(host, "/") + "/xrpc/com.atproto.sync.subscribeRepos")
+		if err != nil {
+			return err
+		}
+		if cursor, _ := ix.DB.GetCursor(ctx, "stream:firehose"); cursor != "" {
+			q := u.Query()
+			q.Set("cursor", cursor)
+			u.RawQuery = q.Encode()
+		}
+		con, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("[indexer] firehose connected: %s", u)
+
+		rsc := &events.RepoStreamCallbacks{
+			RepoCommit: func(evt *comatproto.SyncSubscribeRepos_Commit) error {
+				ix.applyCommit(ctx, evt)
+				saver.Set(ctx, strconv.FormatInt(evt.Seq, 10))
+				return nil
+			},
+		}
+		return events.HandleRepoStream(ctx, con, sequential.NewScheduler("indexer", rsc.EventHandler), nil)
+	})
+}
+
+// applyCommit applies the com.inkreaders.* ops of one commit. Record bodies
+// come from the CAR diff in evt.Blocks, which is only parsed when needed.
+func (ix *Indexer) applyCommit(ctx context.Context, evt *comatproto.SyncSubscribeRepos_Commit) {
+	var r *repo.Repo
+	for _, op := range evt.Ops {
+		collection, rkey, ok := strings.Cut(op.Path, "/")
+		if !ok || !strings.HasPrefix(collection, CollectionPrefix) {
+			continue
+		}
+		ev := Event{DID: evt.Repo, Collection: collection, RKey: rkey, Op: op.Action}
+		if op.Action != OpDelete {
+			if op.Cid != nil {
+				ev.CID = op.Cid.String()
+			}
+			if r == nil {
+				var err error
+				if r, err = repo.ReadRepoFromCar(ctx, bytes.NewReader(evt.Blocks)); err != nil {
+					log.Printf("[indexer] firehose seq=%d: read car: %v", evt.Seq, err)
+					return
+				}
+			}
+			_, rec, err := r.GetRecordBytes(ctx, op.Path)
+			if err != nil || rec == nil {
+				log.Printf("[indexer] firehose %s: record missing: %v", ev.URI(), err)
+				continue
+			}
+			if ev.Record, err = data.UnmarshalCBOR(*rec); err != nil {
+				log.Printf("[indexer] firehose %s: decode: %v", ev.URI(), err)
+				continue
+			}
+		}
+		if err := ix.Apply(ctx, ev); err != nil {
+			log.Printf("[indexer] apply %s %s: %v", ev.Op, ev.URI(), err)
+		}
+	}
+}
@@ -11,71 +11,59 @@ import (
 	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
 )
 
+// Store is the part of *db.Store the indexer writes through.
+type Store interface {
+	GetCursor(ctx context.Context, name string) (string, error)
+	SetCursor(ctx context.Context, name, value string) error
+	UpsertBook(ctx context.Context, title string, authors []string, isbn10, isbn13, link string) (int64, error)
+	UpsertBookPost(ctx context.Context, uri, cid, did string, createdAt time.Time, text string, bookID *int64, rating, progress *float64) error
+	UpsertArticlePost(ctx context.Context, uri, cid, did string, createdAt time.Time, text, url, title, source string) error
+	DeletePost(ctx context.Context, uri string) error
+	LivePostCIDs(ctx context.Context, did, collection string) (map[string]string, error)
+}
+
+var _ Store = (*db.Store)(nil)
+
 type Indexer struct {
 	Agent *xrpc.Client
 	DID   string
-	DB    *db.Store
+	DB    Store
 }
 
-func New(agent *xrpc.Client, did string, store *db.Store) *Indexer {
+func New(agent *xrpc.Client, did string, store Store) *Indexer {
 	return &Indexer{Agent: agent, DID: did, DB: store}
 }
 
-// replace the function body of PollBookPosts with this:
-// replace the function body of PollBookPosts with this:
+// Poll your own repo for book posts, paging forward from the "poll:book"
+// cursor so each tick only reads records written since the last one.
 func (ix *Indexer) PollBookPosts(ctx context.Context, pageSize int) error {
-    // stateless: always fetch newest N
-    out, err := listRecordsRaw(ctx, ix.Agent, ix.DID, "com.inkreaders.book.post", "", int64(pageSize), true /*reverse*/ )
-    if err != nil {
-        return err
-    }
-    if len(out.Records) == 0 {
-        return nil
-    }
+	cursor, _ := ix.DB.GetCursor(ctx, "poll:book")
 
-    for _, rec := range out.Records {
-        uri := rec.Uri
-        cid := rec.Cid
-        did := ix.DID
-        val := rec.Value
-
-        // createdAt (robust)
-        tstr := get(val, "createdAt")
-        createdAt, err := time.Parse(time.RFC3339, tstr)
-        if err != nil || tstr == "" {
-            if t2, e2 := time.Parse(time.RFC3339Nano, tstr); e2 == nil {
-                createdAt = t2
-            } else {
-                createdAt = time.Now().UTC()
-            }
-        }
-
-        text := get(val, "text")
-        title := get(val, "book.title")
-        authors := getStringSlice(val, "book.authors")
-        isbn10 := get(val, "book.isbn10")
-        isbn13 := get(val, "book.isbn13")
-        link := get(val, "book.link")
-
-        var bookID *int64
-        if title != "" {
-            id, err := ix.DB.UpsertBook(ctx, title, authors, isbn10, isbn13, link)
-            if err == nil { bookID = &id }
-        }
-
-        var ratingPtr, progressPtr *float64
-        if v, ok := getFloat(val, "rating"); ok { ratingPtr = &v }
-        if v, ok := getFloat(val, "progress"); ok { progressPtr = &v }
-
-        if err := ix.DB.UpsertBookPost(ctx, uri, cid, did, createdAt, text, bookID, ratingPtr, progressPtr); err == nil {
-            // optional debug
-            // log.Printf("[indexer] indexed book[%d]: %s", i, uri)
-        }
-    }
-    return nil
-}
+	for {
+		out, err := listRecordsRaw(ctx, ix.Agent, ix.DID, "com.inkreaders.book.post", cursor, int64(pageSize), true)
+		if err != nil {
+			return err
+		}
+		if len(out.Records) == 0 {
+			return nil
+		}
 
+		for _, rec := range out.Records {
+			if err := ix.indexBookPost(ctx, rec.Uri, rec.Cid, ix.DID, rec.Value); err != nil {
+				log.Println("UpsertBookPost:", err)
+			}
+		}
 
+		if out.Cursor != nil {
+			cursor = *out.Cursor
+			if err := ix.DB.SetCursor(ctx, "poll:book", cursor); err != nil {
+				log.Println("SetCursor book:", err)
+			}
+		} else {
+			return nil
+		}
+	}
+}
 
 // Poll your own repo for latest article posts
 func (ix *Indexer) PollArticlePosts(ctx context.Context, pageSize int) error {
@@ -91,19 +79,7 @@ func (ix *Indexer) PollArticlePosts(ctx context.Context, pageSize int) error {
 		}
 
 		for _, rec := range out.Records {
-			uri := rec.Uri
-			cid := rec.Cid
-			did := ix.DID
-
-			val := rec.Value
-
-			createdAt, _ := time.Parse(time.RFC3339, get(val, "createdAt"))
-			text := get(val, "text")
-			title := get(val, "article.title")
-			url := get(val, "article.url")
-			source := get(val, "article.source")
-
-			if err := ix.DB.UpsertArticlePost(ctx, uri, cid, did, createdAt, text, url, title, source); err != nil {
+			if err := ix.indexArticlePost(ctx, rec.Uri, rec.Cid, ix.DID, rec.Value); err != nil {
 				log.Println("UpsertArticlePost:", err)
 			}
 		}
@@ -120,6 +96,43 @@ func (ix *Indexer) PollArticlePosts(ctx context.Context, pageSize int) error {
 }
 
 
+// indexBookPost writes one com.inkreaders.book.post record (and its book).
+func (ix *Indexer) indexBookPost(ctx context.Context, uri, cid, did string, val map[string]any) error {
+	text := get(val, "text")
+	title := get(val, "book.title")
+	authors := getStringSlice(val, "book.authors")
+	isbn10 := get(val, "book.isbn10")
+	isbn13 := get(val, "book.isbn13")
+	link := get(val, "book.link")
+
+	var bookID *int64
+	if title != "" {
+		id, err := ix.DB.UpsertBook(ctx, title, authors, isbn10, isbn13, link)
+		if err == nil { bookID = &id }
+	}
+
+	var ratingPtr, progressPtr *float64
+	if v, ok := getFloat(val, "rating"); ok { ratingPtr = &v }
+	if v, ok := getFloat(val, "progress"); ok { progressPtr = &v }
+
+	return ix.DB.UpsertBookPost(ctx, uri, cid, did, createdAtOf(val), text, bookID, ratingPtr, progressPtr)
+}
+
+// indexArticlePost writes one com.inkreaders.article.post record.
+func (ix *Indexer) indexArticlePost(ctx context.Context, uri, cid, did string, val map[string]any) error {
+	return ix.DB.UpsertArticlePost(ctx, uri, cid, did, createdAtOf(val),
+		get(val, "text"), get(val, "article.url"), get(val, "article.title"), get(val, "article.source"))
+}
+
+// createdAtOf reads the record's createdAt, falling back to now.
+func createdAtOf(val map[string]any) time.Time {
+	tstr := get(val, "createdAt")
+	if t, err := time.Parse(time.RFC3339Nano, tstr); err == nil {
+		return t
+	}
+	return time.Now().UTC()
+}
+
 // helpers to extract nested fields from map[string]any (decoded JSON)
 func get(m map[string]any, path string) string {
 	cur := any(m)
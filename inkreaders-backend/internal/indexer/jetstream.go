@@ -0,0 +1,106 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const DefaultJetstreamURL = "wss://jetstream2.us-east.bsky.network/subscribe"
+
+// jetstreamRewind is how far back (in µs) a reconnect replays from the saved
+// cursor; the upserts are idempotent, so replaying is safe and covers events
+// that were received but not yet saved.
+const jetstreamRewind = int64(5 * time.Second / time.Microsecond)
+
+type jetstreamMsg struct {
+	DID    string `json:"did"`
+	TimeUS int64  `json:"time_us"`
+	Kind   string `json:"kind"`
+	Commit *struct {
+		Operation  string         `json:"operation"`
+		Collection string         `json:"collection"`
+		RKey       string         `json:"rkey"`
+		CID        string         `json:"cid"`
+		Record     map[string]any `json:"record"`
+	} `json:"commit"`
+}
+
+// RunJetstream indexes com.inkreaders.* records from every repo via a
+// Jetstream JSON websocket, resuming from the "stream:jetstream" cursor
+// (a time_us value). It reconnects until ctx is cancelled.
+func (ix *Indexer) RunJetstream(ctx context.Context, endpoint string) error {
+	if endpoint == "" {
+		endpoint = DefaultJetstreamURL
+	}
+	saver := &cursorSaver{ix: ix, name: "stream:jetstream", interval: 5 * time.Second}
+	return runForever(ctx, "jetstream", func(ctx context.Context) error {
+		defer saver.Flush(context.Background())
+
+		cursor, _ := ix.DB.GetCursor(ctx, "stream:jetstream")
+		u, err := jetstreamURL(endpoint, cursor)
+		if err != nil {
+			return err
+		}
+		con, _, err := websocket.DefaultDialer.DialContext(ctx, u, nil)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			<-ctx.Done()
+			con.Close()
+		}()
+		log.Printf("[indexer] jetstream connected: %s", u)
+
+		for {
+			_, data, err := con.ReadMessage()
+			if err != nil {
+				return err
+			}
+			var msg jetstreamMsg
+			if err := json.Unmarshal(data, &msg); err != nil {
+				log.Printf("[indexer] jetstream: bad message: %v", err)
+				continue
+			}
+			if msg.Kind == "commit" && msg.Commit != nil {
+				ev := Event{
+					DID:        msg.DID,
+					Collection: msg.Commit.Collection,
+					RKey:       msg.Commit.RKey,
+					Op:         msg.Commit.Operation,
+					CID:        msg.Commit.CID,
+					Record:     msg.Commit.Record,
+				}
+				if err := ix.Apply(ctx, ev); err != nil {
+					log.Printf("[indexer] apply %s %s: %v", ev.Op, ev.URI(), err)
+				}
+			}
+			if msg.TimeUS > 0 {
+				saver.Set(ctx, strconv.FormatInt(msg.TimeUS, 10))
+			}
+		}
+	})
+}
+
+func jetstreamURL(endpoint, cursor string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("jetstream url: %w", err)
+	}
+	q := u.Query()
+	q.Set("wantedCollections", CollectionPrefix+"*")
+	if c, err := strconv.ParseInt(cursor, 10, 64); err == nil && c > 0 {
+		q.Set("cursor", strconv.FormatInt(c-jetstreamRewind, 10))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
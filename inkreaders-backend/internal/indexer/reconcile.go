@@ -0,0 +1,69 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Reconcile diffs the posts indexed from the app account's repo against a
+// full listRecords of the collection: URIs that are gone upstream are
+// tombstoned, and records whose CID differs from the indexed one are
+// re-indexed. It catches edits and deletes the poller can't see, since the
+// poll cursor only moves forward.
+func (ix *Indexer) Reconcile(ctx context.Context, collection string) error {
+	var index func(ctx context.Context, uri, cid, did string, val map[string]any) error
+	switch collection {
+	case CollectionBookPost:
+		index = ix.indexBookPost
+	case CollectionArticlePost:
+		index = ix.indexArticlePost
+	default:
+		return fmt.Errorf("reconcile: unsupported collection %s", collection)
+	}
+
+	indexed, err := ix.DB.LivePostCIDs(ctx, ix.DID, collection)
+	if err != nil {
+		return err
+	}
+
+	var cursor string
+	var updated, deleted int
+	for {
+		out, err := listRecordsRaw(ctx, ix.Agent, ix.DID, collection, cursor, 100, false)
+		if err != nil {
+			// A partial listing can't tell us what's missing; leave tombstones
+			// for the next pass.
+			return err
+		}
+		for _, rec := range out.Records {
+			cid, ok := indexed[rec.Uri]
+			delete(indexed, rec.Uri)
+			if ok && cid == rec.Cid {
+				continue
+			}
+			if err := index(ctx, rec.Uri, rec.Cid, ix.DID, rec.Value); err != nil {
+				log.Printf("[indexer] reconcile %s: %v", rec.Uri, err)
+				continue
+			}
+			updated++
+		}
+		if out.Cursor == nil || *out.Cursor == "" || len(out.Records) == 0 {
+			break
+		}
+		cursor = *out.Cursor
+	}
+
+	// Whatever is left was indexed but no longer exists upstream.
+	for uri := range indexed {
+		if err := ix.DB.DeletePost(ctx, uri); err != nil {
+			log.Printf("[indexer] reconcile delete %s: %v", uri, err)
+			continue
+		}
+		deleted++
+	}
+	if updated > 0 || deleted > 0 {
+		log.Printf("[indexer] reconcile %s: %d updated, %d deleted", collection, updated, deleted)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package indexer
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// CollectionPrefix selects the records the streaming modes index.
+const CollectionPrefix = "com.inkreaders."
+
+const (
+	CollectionBookPost    = "com.inkreaders.book.post"
+	CollectionArticlePost = "com.inkreaders.article.post"
+)
+
+// Op kinds, as named by both the firehose and Jetstream.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Event is one record operation from any repo, normalised from either a
+// firehose commit or a Jetstream message.
+type Event struct {
+	DID        string
+	Collection string
+	RKey       string
+	Op         string
+	CID        string
+	Record     map[string]any // nil for deletes
+}
+
+func (ev Event) URI() string {
+	return "at://" + ev.DID + "/" + ev.Collection + "/" + ev.RKey
+}
+
+// Apply writes a single event to the index. Collections under
+// CollectionPrefix that the index has no table for are ignored.
+func (ix *Indexer) Apply(ctx context.Context, ev Event) error {
+	if !strings.HasPrefix(ev.Collection, CollectionPrefix) {
+		return nil
+	}
+	switch ev.Op {
+	case OpDelete:
+		return ix.DB.DeletePost(ctx, ev.URI())
+	case OpCreate, OpUpdate:
+		if ev.Record == nil {
+			return nil
+		}
+		switch ev.Collection {
+		case CollectionBookPost:
+			return ix.indexBookPost(ctx, ev.URI(), ev.CID, ev.DID, ev.Record)
+		case CollectionArticlePost:
+			return ix.indexArticlePost(ctx, ev.URI(), ev.CID, ev.DID, ev.Record)
+		}
+	}
+	return nil
+}
+
+// cursorSaver persists a stream cursor at most once per interval, so a busy
+// stream doesn't turn into one UPDATE per event.
+type cursorSaver struct {
+	ix       *Indexer
+	name     string
+	interval time.Duration
+	last     time.Time
+	pending  string
+}
+
+func (c *cursorSaver) Set(ctx context.Context, value string) {
+	c.pending = value
+	if time.Since(c.last) >= c.interval {
+		c.Flush(ctx)
+	}
+}
+
+func (c *cursorSaver) Flush(ctx context.Context) {
+	if c.pending == "" {
+		return
+	}
+	if err := c.ix.DB.SetCursor(ctx, c.name, c.pending); err != nil {
+		log.Printf("[indexer] SetCursor %s: %v", c.name, err)
+		return
+	}
+	c.last = time.Now()
+}
+
+// runForever calls connect until ctx is done, backing off between failed
+// connections. connect should block for as long as the connection is healthy.
+func runForever(ctx context.Context, name string, connect func(ctx context.Context) error) error {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := connect(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("[indexer] %s disconnected: %v (retry in %s)", name, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/data"
+	atrepo "github.com/bluesky-social/indigo/atproto/repo"
+	"github.com/bluesky-social/indigo/events"
+	lexutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/repo"
+	"github.com/gorilla/websocket"
+)
+
+// fakeStore records the writes the indexer makes.
+type fakeStore struct {
+	mu      sync.Mutex
+	cursors map[string]string
+	ops     []string
+}
+
+func newFakeStore() *fakeStore { return &fakeStore{cursors: map[string]string{}} }
+
+func (f *fakeStore) record(op string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ops = append(f.ops, op)
+}
+
+func (f *fakeStore) Ops() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.ops...)
+}
+
+func (f *fakeStore) GetCursor(_ context.Context, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cursors[name], nil
+}
+
+func (f *fakeStore) SetCursor(_ context.Context, name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cursors[name] = value
+	return nil
+}
+
+func (f *fakeStore) UpsertBook(context.Context, string, []string, string, string, string) (int64, error) {
+	return 1, nil
+}
+
+func (f *fakeStore) UpsertBookPost(_ context.Context, uri, cid, _ string, _ time.Time, text string, _ *int64, _, _ *float64) error {
+	f.record("book " + uri + " " + text)
+	return nil
+}
+
+func (f *fakeStore) UpsertArticlePost(_ context.Context, uri, cid, _ string, _ time.Time, text, _, _, _ string) error {
+	f.record("article " + uri + " " + text)
+	return nil
+}
+
+func (f *fakeStore) DeletePost(_ context.Context, uri string) error {
+	f.record("delete " + uri)
+	return nil
+}
+
+func (f *fakeStore) LivePostCIDs(context.Context, string, string) (map[string]string, error) {
+	return nil, nil
+}
+
+// replayServer is a websocket stand-in for a stream. The first connection
+// is sent frames and closed; later connections stay open until the client
+// goes away. Each connection's cursor query parameter is reported on
+// cursors ("" when absent).
+func replayServer(t *testing.T, frameType int, frames [][]byte) (*httptest.Server, <-chan string) {
+	t.Helper()
+	cursors := make(chan string, 4)
+	var mu sync.Mutex
+	conns := 0
+	up := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		con, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer con.Close()
+		mu.Lock()
+		conns++
+		first := conns == 1
+		mu.Unlock()
+		cursors <- r.URL.Query().Get("cursor")
+		if !first {
+			for {
+				if _, _, err := con.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		for _, f := range frames {
+			if err := con.WriteMessage(frameType, f); err != nil {
+				t.Errorf("write frame: %v", err)
+				return
+			}
+		}
+		_ = con.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, cursors
+}
+
+// runUntilReconnect runs a stream until it has connected twice, returning
+// the cursor each connection asked for.
+func runUntilReconnect(t *testing.T, cursors <-chan string, run func(ctx context.Context) error) (first, second string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+
+	wait := func() string {
+		select {
+		case c := <-cursors:
+			return c
+		case <-time.After(10 * time.Second):
+			cancel()
+			t.Fatal("timed out waiting for a connection")
+			return ""
+		}
+	}
+	first = wait()
+	second = wait()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run returned %v, want context.Canceled", err)
+	}
+	return first, second
+}
+
+const testDID = "did:plc:reader"
+
+func TestRunJetstream(t *testing.T) {
+	msg := func(timeUS int64, op, cid, text string) []byte {
+		m := map[string]any{"did": testDID, "time_us": timeUS, "kind": "commit"}
+		commit := map[string]any{"operation": op, "collection": CollectionBookPost, "rkey": "3kabc", "cid": cid}
+		if text != "" {
+			commit["record"] = map[string]any{"text": text, "createdAt": "2025-01-02T03:04:05Z"}
+		}
+		m["commit"] = commit
+		b, _ := json.Marshal(m)
+		return b
+	}
+	identity, _ := json.Marshal(map[string]any{"did": testDID, "time_us": 1700000000000004, "kind": "identity"})
+	frames := [][]byte{
+		msg(1700000000000001, OpCreate, "bafy1", "first"),
+		msg(1700000000000002, OpUpdate, "bafy2", "edited"),
+		msg(1700000000000003, OpDelete, "", ""),
+		identity,
+	}
+	srv, cursors := replayServer(t, websocket.TextMessage, frames)
+	store := newFakeStore()
+	ix := &Indexer{DID: testDID, DB: store}
+
+	endpoint := "ws" + strings.TrimPrefix(srv.URL, "http")
+	first, second := runUntilReconnect(t, cursors, func(ctx context.Context) error {
+		return ix.RunJetstream(ctx, endpoint)
+	})
+
+	uri := "at://" + testDID + "/" + CollectionBookPost + "/3kabc"
+	want := []string{"book " + uri + " first", "book " + uri + " edited", "delete " + uri}
+	if got := store.Ops(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ops = %q, want %q", got, want)
+	}
+	if saved, _ := store.GetCursor(context.Background(), "stream:jetstream"); saved != "1700000000000004" {
+		t.Errorf("saved cursor = %q, want 1700000000000004", saved)
+	}
+	if first != "" {
+		t.Errorf("first connection cursor = %q, want none", first)
+	}
+	if want := strconv.FormatInt(1700000000000004-jetstreamRewind, 10); second != want {
+		t.Errorf("reconnect cursor = %q, want %q", second, want)
+	}
+}
+
+// recordingBlockstore keeps every block written, to build CAR slices.
+type recordingBlockstore struct {
+	*atrepo.TinyBlockstore
+	all []blocks.Block
+}
+
+func (r *recordingBlockstore) Put(ctx context.Context, b blocks.Block) error {
+	r.all = append(r.all, b)
+	return r.TinyBlockstore.Put(ctx, b)
+}
+
+type rawCBOR []byte
+
+func (r rawCBOR) MarshalCBOR(w io.Writer) error {
+	_, err := w.Write(r)
+	return err
+}
+
+func carFile(t *testing.T, root cid.Cid, blks []blocks.Block) []byte {
+	t.Helper()
+	header, err := cbor.DumpObject(map[string]any{"roots": []cid.Cid{root}, "version": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	section := func(parts ...[]byte) {
+		n := 0
+		for _, p := range parts {
+			n += len(p)
+		}
+		buf.Write(binary.AppendUvarint(nil, uint64(n)))
+		for _, p := range parts {
+			buf.Write(p)
+		}
+	}
+	section(header)
+	for _, b := range blks {
+		section(b.Cid().Bytes(), b.RawData())
+	}
+	return buf.Bytes()
+}
+
+func commitFrame(t *testing.T, evt *comatproto.SyncSubscribeRepos_Commit) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (&events.EventHeader{Op: events.EvtKindMessage, MsgType: "#commit"}).MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := evt.MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRunFirehose(t *testing.T) {
+	ctx := context.Background()
+	bs := &recordingBlockstore{TinyBlockstore: atrepo.NewTinyBlockstore()}
+	r := repo.NewRepo(ctx, testDID, bs)
+	signer := func(context.Context, string, []byte) ([]byte, error) { return []byte("sig"), nil }
+	path := CollectionBookPost + "/3kabc"
+
+	// commit writes one record version and returns its commit event.
+	commit := func(seq int64, action, text string) *comatproto.SyncSubscribeRepos_Commit {
+		t.Helper()
+		rec, err := data.MarshalCBOR(map[string]any{
+			"$type": CollectionBookPost, "text": text, "createdAt": "2025-01-02T03:04:05Z",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rc cid.Cid
+		if action == OpCreate {
+			rc, err = r.PutRecord(ctx, path, rawCBOR(rec))
+		} else {
+			rc, err = r.UpdateRecord(ctx, path, rawCBOR(rec))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, rev, err := r.Commit(ctx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link := lexutil.LexLink(rc)
+		return &comatproto.SyncSubscribeRepos_Commit{
+			Repo:   testDID,
+			Seq:    seq,
+			Rev:    rev,
+			Time:   "2025-01-02T03:04:05Z",
+			Commit: lexutil.LexLink(root),
+			Blocks: carFile(t, root, bs.all),
+			Ops:    []*comatproto.SyncSubscribeRepos_RepoOp{{Action: action, Path: path, Cid: &link}},
+			Blobs:  []lexutil.LexLink{},
+		}
+	}
+	del := &comatproto.SyncSubscribeRepos_Commit{
+		Repo:   testDID,
+		Seq:    3,
+		Time:   "2025-01-02T03:04:05Z",
+		Ops:    []*comatproto.SyncSubscribeRepos_RepoOp{{Action: OpDelete, Path: path}},
+		Blobs:  []lexutil.LexLink{},
+		Blocks: []byte{},
+	}
+	create := commit(1, OpCreate, "first")
+	update := commit(2, OpUpdate, "edited")
+	del.Commit = update.Commit
+	frames := [][]byte{commitFrame(t, create), commitFrame(t, update), commitFrame(t, del)}
+
+	srv, cursors := replayServer(t, websocket.BinaryMessage, frames)
+	store := newFakeStore()
+	ix := &Indexer{DID: testDID, DB: store}
+
+	host := "ws" + strings.TrimPrefix(srv.URL, "http")
+	first, second := runUntilReconnect(t, cursors, func(ctx context.Context) error {
+		return ix.RunFirehose(ctx, host)
+	})
+
+	uri := "at://" + testDID + "/" + path
+	want := []string{"book " + uri + " first", "book " + uri + " edited", "delete " + uri}
+	if got := store.Ops(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ops = %q, want %q", got, want)
+	}
+	if first != "" {
+		t.Errorf("first connection cursor = %q, want none", first)
+	}
+	if second != "3" {
+		t.Errorf("reconnect cursor = %q, want 3", second)
+	}
+	if saved, _ := store.GetCursor(ctx, "stream:firehose"); saved != "3" {
+		t.Errorf("saved cursor = %q, want 3", saved)
+	}
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/enrich"
+)
+
+// TypeBookEnrich fills in catalog metadata for books that don't have it yet.
+const TypeBookEnrich = "book.enrich"
+
+type BookEnrichPayload struct {
+	Limit int `json:"limit,omitempty"` // books per run; default 200
+}
+
+func BookEnrich(store *db.Store, src enrich.Source) Handler {
+	return Handler{
+		Run: func(ctx context.Context, job db.Job) error {
+			var p BookEnrichPayload
+			if len(job.Payload) > 0 {
+				if err := json.Unmarshal(job.Payload, &p); err != nil {
+					return fmt.Errorf("bad payload: %w", err)
+				}
+			}
+			if p.Limit <= 0 {
+				p.Limit = 200
+			}
+			_, err := enrich.Run(ctx, store, src, p.Limit)
+			return err
+		},
+		// A dump source streams the whole file.
+		Timeout: 8 * time.Minute,
+	}
+}
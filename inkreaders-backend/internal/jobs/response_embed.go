@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// TypeResponseEmbed computes embeddings for new and edited responses.
+const TypeResponseEmbed = "response.embed"
+
+// embedBatch is how many responses are sent to the embedder per call.
+const embedBatch = 32
+
+type ResponseEmbedPayload struct {
+	// ResponseIDs limits the run to these rows; empty sweeps every
+	// response still missing an embedding from the current model.
+	ResponseIDs []uuid.UUID `json:"response_ids,omitempty"`
+	Limit       int         `json:"limit,omitempty"` // sweep size; default 256
+}
+
+func ResponseEmbed(store *db.Store, emb ai.Embedder) Handler {
+	return Handler{
+		Run: func(ctx context.Context, job db.Job) error {
+			var p ResponseEmbedPayload
+			if len(job.Payload) > 0 {
+				if err := json.Unmarshal(job.Payload, &p); err != nil {
+					return fmt.Errorf("bad payload: %w", err)
+				}
+			}
+			if p.Limit <= 0 {
+				p.Limit = 256
+			}
+			items, err := store.ResponsesToEmbed(ctx, p.ResponseIDs, emb.Model(), p.Limit)
+			if err != nil {
+				return err
+			}
+			for start := 0; start < len(items); start += embedBatch {
+				batch := items[start:min(start+embedBatch, len(items))]
+				texts := make([]string, len(batch))
+				for i, it := range batch {
+					texts[i] = it.Content
+				}
+				vecs, err := emb.Embed(ctx, texts)
+				if err != nil {
+					return err
+				}
+				for i, it := range batch {
+					if err := store.SetResponseEmbedding(ctx, it, vecs[i], emb.Model()); err != nil {
+						return err
+					}
+				}
+			}
+			if len(items) > 0 {
+				log.Printf("[embed] embedded %d response(s) model=%s", len(items), emb.Model())
+			}
+			return nil
+		},
+	}
+}
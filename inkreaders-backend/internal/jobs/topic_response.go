@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/ai"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/live"
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/render"
+)
+
+// TypeTopicResponse fills in the placeholder AI response created with a topic.
+// The text is streamed through hub as it is generated so
+// /api/responses/{id}/stream can show it live.
+const TypeTopicResponse = "topic.response"
+
+type TopicResponsePayload struct {
+	ResponseID uuid.UUID `json:"response_id"`
+	Prompt     string    `json:"prompt"`
+	// FollowUpOf is the user response being answered in a thread. Its
+	// ancestors are sent as chat history.
+	FollowUpOf *uuid.UUID `json:"follow_up_of,omitempty"`
+	// UserID is who the model call is billed to.
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func TopicResponse(store *db.Store, client ai.Client, hub *live.Hub) Handler {
+	return Handler{
+		Run: func(ctx context.Context, job db.Job) (err error) {
+			var p TopicResponsePayload
+			if err := json.Unmarshal(job.Payload, &p); err != nil {
+				return fmt.Errorf("bad payload: %w", err)
+			}
+			ctx = ai.WithUser(ctx, p.UserID)
+			defer func() {
+				// Subscribers drop the partial text; a retry streams again.
+				if err != nil {
+					hub.Reset(p.ResponseID)
+				}
+			}()
+			onDelta := func(delta string) { hub.Publish(p.ResponseID, delta) }
+			raw := map[string]any{
+				"source": "ai",
+				"prompt": p.Prompt,
+			}
+			var aiResp string
+			if p.FollowUpOf != nil {
+				thread, err := store.ResponseThread(ctx, *p.FollowUpOf)
+				if err != nil {
+					return err
+				}
+				// The last entry is the question itself.
+				history := threadTurns(thread[:max(len(thread)-1, 0)])
+				raw["follow_up_of"] = *p.FollowUpOf
+				raw["history_turns"] = len(history)
+				aiResp, err = client.GenerateFollowUp(ctx, history, p.Prompt, onDelta)
+				if err != nil {
+					return err
+				}
+			} else {
+				aiResp, err = client.GenerateResponseStream(ctx, p.Prompt, onDelta)
+				if err != nil {
+					return err
+				}
+			}
+
+			html := render.Markdown(aiResp)
+
+			if err := store.UpdateResponse(ctx, p.ResponseID, aiResp, html, raw); err != nil {
+				return err
+			}
+			hub.Finish(p.ResponseID, nil)
+			if _, err := store.EnqueueJob(ctx, TypeResponseEmbed, ResponseEmbedPayload{
+				ResponseIDs: []uuid.UUID{p.ResponseID},
+			}, 3); err != nil {
+				log.Printf("[jobs] enqueue embed respID=%s: %v", p.ResponseID, err)
+			}
+			return nil
+		},
+		GiveUp: func(ctx context.Context, job db.Job, err error) {
+			var p TopicResponsePayload
+			if json.Unmarshal(job.Payload, &p) != nil || p.ResponseID == uuid.Nil {
+				return
+			}
+			_ = store.FailResponse(ctx, p.ResponseID, err.Error())
+			hub.Finish(p.ResponseID, err)
+		},
+		// Streamed answers may run well past the default job timeout.
+		Timeout: 5 * time.Minute,
+	}
+}
+
+// threadTurns turns a thread (oldest first) into chat history. Failed
+// responses and unfinished AI placeholders are skipped; a root AI response
+// is preceded by the prompt that produced it.
+func threadTurns(thread []db.Response) []ai.Turn {
+	var turns []ai.Turn
+	for i, r := range thread {
+		if r.Status == "failed" || (r.AuthorType == "ai" && r.Status == "pending") {
+			continue
+		}
+		if strings.TrimSpace(r.Content) == "" {
+			continue
+		}
+		if r.AuthorType == "ai" {
+			if prompt, _ := r.Raw["prompt"].(string); i == 0 && prompt != "" {
+				turns = append(turns, ai.Turn{Role: ai.RoleUser, Content: prompt})
+			}
+			turns = append(turns, ai.Turn{Role: ai.RoleAssistant, Content: r.Content})
+		} else {
+			turns = append(turns, ai.Turn{Role: ai.RoleUser, Content: r.Content})
+		}
+	}
+	return turns
+}
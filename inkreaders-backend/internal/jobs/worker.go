@@ -0,0 +1,221 @@
+// Package jobs runs background work queued in the app.jobs table. Rows are
+// claimed with SELECT … FOR UPDATE SKIP LOCKED, so several workers (or
+// several server processes) can share one queue.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/wedaa-tech/inkreaders-social-hub/inkreaders-backend/internal/db"
+)
+
+// Handler processes one job type.
+type Handler struct {
+	// Run does the work. A returned error schedules a retry with backoff
+	// until the job's max_attempts is used up.
+	Run func(ctx context.Context, job db.Job) error
+	// GiveUp, if set, is called once after the final failed attempt so the
+	// handler can record the failure on its own rows.
+	GiveUp func(ctx context.Context, job db.Job, err error)
+	// Timeout overrides the worker's JobTimeout for this job type. Keep it
+	// under StaleAfter or the job will be requeued while still running.
+	Timeout time.Duration
+}
+
+type Worker struct {
+	Store        *db.Store
+	Concurrency  int
+	PollInterval time.Duration
+	JobTimeout   time.Duration
+	// StaleAfter is how long a job may stay 'running' before it is assumed
+	// orphaned by a crashed process and requeued.
+	StaleAfter time.Duration
+	Backoff    func(attempt int) time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewWorker(store *db.Store) *Worker {
+	return &Worker{
+		Store:        store,
+		Concurrency:  2,
+		PollInterval: 2 * time.Second,
+		JobTimeout:   2 * time.Minute,
+		StaleAfter:   10 * time.Minute,
+		Backoff:      ExponentialBackoff(5*time.Second, 10*time.Minute),
+		handlers:     map[string]Handler{},
+	}
+}
+
+func (w *Worker) Register(jobType string, h Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[jobType] = h
+}
+
+// Run processes jobs until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	types := w.jobTypes()
+	if len(types) == 0 {
+		log.Println("[jobs] no handlers registered; worker idle")
+		return
+	}
+	log.Printf("[jobs] worker started types=%v concurrency=%d", types, w.Concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.requeueLoop(ctx)
+	}()
+	for i := 0; i < max(w.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, types)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context, types []string) {
+	for {
+		job, err := w.Store.ClaimJob(ctx, types)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[jobs] claim failed: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.PollInterval):
+			}
+			continue
+		}
+		w.process(ctx, *job)
+	}
+}
+
+func (w *Worker) requeueLoop(ctx context.Context) {
+	ticker := time.NewTicker(w.StaleAfter / 2)
+	defer ticker.Stop()
+	for {
+		n, failed, err := w.Store.RequeueStaleJobs(ctx, w.StaleAfter)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[jobs] requeue stale failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[jobs] requeued %d stale job(s)", n)
+		}
+		for _, job := range failed {
+			log.Printf("[jobs] id=%d type=%s stale after final attempt %d/%d, giving up",
+				job.ID, job.JobType, job.Attempts, job.MaxAttempts)
+			w.mu.RLock()
+			h, ok := w.handlers[job.JobType]
+			w.mu.RUnlock()
+			if ok && h.GiveUp != nil {
+				h.GiveUp(ctx, job, errors.New("job went stale on its final attempt"))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// bookkeepingTimeout bounds the job-state writes made after a run, which
+// must land even when the worker's ctx has been cancelled by shutdown.
+const bookkeepingTimeout = 10 * time.Second
+
+func (w *Worker) process(ctx context.Context, job db.Job) {
+	bctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bookkeepingTimeout)
+	defer cancel()
+
+	w.mu.RLock()
+	h, ok := w.handlers[job.JobType]
+	w.mu.RUnlock()
+	if !ok {
+		_ = w.Store.FailJob(bctx, job.ID, "no handler for job type "+job.JobType)
+		return
+	}
+
+	err := w.runOnce(ctx, h, job)
+	if err == nil {
+		if cerr := w.Store.CompleteJob(bctx, job.ID); cerr != nil {
+			log.Printf("[jobs] complete id=%d: %v", job.ID, cerr)
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		// Shutdown, not the job, cut the run short: hand the attempt back.
+		log.Printf("[jobs] id=%d type=%s interrupted by shutdown, requeued: %v", job.ID, job.JobType, err)
+		if rerr := w.Store.ReleaseJob(bctx, job.ID); rerr != nil {
+			log.Printf("[jobs] release id=%d: %v", job.ID, rerr)
+		}
+		return
+	}
+
+	if job.Attempts < job.MaxAttempts {
+		next := time.Now().Add(w.Backoff(job.Attempts))
+		log.Printf("[jobs] id=%d type=%s attempt %d/%d failed, retry at %s: %v",
+			job.ID, job.JobType, job.Attempts, job.MaxAttempts, next.Format(time.RFC3339), err)
+		if rerr := w.Store.RetryJob(bctx, job.ID, err.Error(), next); rerr != nil {
+			log.Printf("[jobs] retry id=%d: %v", job.ID, rerr)
+		}
+		return
+	}
+
+	log.Printf("[jobs] id=%d type=%s giving up after %d attempts: %v", job.ID, job.JobType, job.Attempts, err)
+	if ferr := w.Store.FailJob(bctx, job.ID, err.Error()); ferr != nil {
+		log.Printf("[jobs] fail id=%d: %v", job.ID, ferr)
+	}
+	if h.GiveUp != nil {
+		h.GiveUp(bctx, job, err)
+	}
+}
+
+// runOnce runs the handler with a timeout and turns panics into errors so a
+// bad job cannot take the worker down.
+func (w *Worker) runOnce(ctx context.Context, h Handler, job db.Job) (err error) {
+	timeout := w.JobTimeout
+	if h.Timeout > 0 {
+		timeout = h.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h.Run(ctx, job)
+}
+
+func (w *Worker) jobTypes() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := make([]string, 0, len(w.handlers))
+	for t := range w.handlers {
+		out = append(out, t)
+	}
+	return out
+}
+
+// ExponentialBackoff doubles the delay per attempt starting at base, capped.
+func ExponentialBackoff(base, maxDelay time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < maxDelay; i++ {
+			d *= 2
+		}
+		return min(d, maxDelay)
+	}
+}
@@ -0,0 +1,128 @@
+// Package live fans out in-progress AI response text from the job worker to
+// SSE subscribers in the same process. Subscribers that join late get the
+// text so far as a replay; callers fall back to the database for anything
+// the hub doesn't see (e.g. a worker running in another process).
+package live
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventDelta = "delta" // Text holds the next chunk
+	EventReset = "reset" // attempt failed and will be retried; drop text so far
+	EventDone  = "done"  // final text is persisted
+	EventError = "error" // Text holds the error; no more retries
+)
+
+type Event struct {
+	Type string
+	Text string
+}
+
+// subBuffer is how far a subscriber may fall behind before it is dropped.
+const subBuffer = 256
+
+type stream struct {
+	text strings.Builder
+	subs map[chan Event]struct{}
+}
+
+type Hub struct {
+	mu      sync.Mutex
+	streams map[uuid.UUID]*stream
+}
+
+func NewHub() *Hub {
+	return &Hub{streams: map[uuid.UUID]*stream{}}
+}
+
+func (h *Hub) get(id uuid.UUID) *stream {
+	st := h.streams[id]
+	if st == nil {
+		st = &stream{subs: map[chan Event]struct{}{}}
+		h.streams[id] = st
+	}
+	return st
+}
+
+// Subscribe returns the text streamed so far and a channel of further
+// events. The channel is closed after done/error, or early if the
+// subscriber falls too far behind. cancel must be called when finished.
+func (h *Hub) Subscribe(id uuid.UUID) (replay string, events <-chan Event, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.get(id)
+	ch := make(chan Event, subBuffer)
+	st.subs[ch] = struct{}{}
+	return st.text.String(), ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if cur := h.streams[id]; cur == st {
+			if _, ok := st.subs[ch]; ok {
+				delete(st.subs, ch)
+				close(ch)
+			}
+			if len(st.subs) == 0 && st.text.Len() == 0 {
+				delete(h.streams, id)
+			}
+		}
+	}
+}
+
+// Publish appends a chunk and forwards it to subscribers.
+func (h *Hub) Publish(id uuid.UUID, delta string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.get(id)
+	st.text.WriteString(delta)
+	st.send(Event{Type: EventDelta, Text: delta})
+}
+
+// Reset clears the text after a failed attempt that will be retried.
+func (h *Hub) Reset(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.streams[id]
+	if !ok {
+		return
+	}
+	st.text.Reset()
+	st.send(Event{Type: EventReset})
+}
+
+// Finish ends the stream with done (err nil) or error, closes every
+// subscriber and forgets the stream.
+func (h *Hub) Finish(id uuid.UUID, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.streams[id]
+	if !ok {
+		return
+	}
+	ev := Event{Type: EventDone}
+	if err != nil {
+		ev = Event{Type: EventError, Text: err.Error()}
+	}
+	st.send(ev)
+	for ch := range st.subs {
+		close(ch)
+	}
+	delete(h.streams, id)
+}
+
+// send delivers ev without blocking; a full subscriber is dropped and must
+// recover from the database. Caller holds h.mu.
+func (st *stream) send(ev Event) {
+	for ch := range st.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(st.subs, ch)
+			close(ch)
+		}
+	}
+}
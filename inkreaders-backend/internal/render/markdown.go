@@ -0,0 +1,57 @@
+// Package render turns response Markdown into the HTML stored in
+// responses.content_html. Output is limited to an allow-listed subset:
+// raw HTML in the source is dropped by the Markdown parser and whatever is
+// left passes through a sanitizer. TeX math is passed through untouched in
+// KaTeX-ready elements for the client to typeset.
+package render
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM), // tables, strikethrough, autolinks, task lists
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	// No html.WithUnsafe: raw HTML and javascript: links are omitted.
+	goldmark.WithRendererOptions(html.WithXHTML()),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^math math-(inline|display)$`)).OnElements("span", "div")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\w-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("type", "checked", "disabled").OnElements("input")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|right|center)$`)).OnElements("th", "td")
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Markdown renders src to sanitized HTML.
+func Markdown(src string) string {
+	if strings.TrimSpace(src) == "" {
+		return ""
+	}
+	text, math := extractMath(src)
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(text), &buf); err != nil {
+		// goldmark only fails on writer errors; fall back to escaped text.
+		return policy.Sanitize("<p>" + htmlEscape(src) + "</p>")
+	}
+	return policy.Sanitize(math.restore(buf.String()))
+}
+
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;").Replace(s)
+}
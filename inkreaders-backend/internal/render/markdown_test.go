@@ -0,0 +1,142 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		want   []string // substrings the output must contain
+		absent []string // substrings it must not
+	}{
+		{
+			name:   "script block dropped",
+			src:    "<script>alert(1)</script>\n\nhi",
+			want:   []string{"<p>hi</p>"},
+			absent: []string{"<script", "alert"},
+		},
+		{
+			name:   "javascript link dropped",
+			src:    "[x](javascript:alert(1))",
+			want:   []string{"<p>x</p>"},
+			absent: []string{"javascript:", "href"},
+		},
+		{
+			name:   "raw inline html dropped",
+			src:    `a <b onclick="x">bold</b> <img src=x onerror=alert(1)>`,
+			want:   []string{"bold"},
+			absent: []string{"<b", "<img", "onclick", "onerror"},
+		},
+		{
+			name: "links get target and rel",
+			src:  "<https://example.com>",
+			want: []string{`href="https://example.com"`, `target="_blank"`, "noopener"},
+		},
+		{
+			name:   "currency is not math",
+			src:    "costs $5 and $10 today",
+			want:   []string{"costs $5 and $10 today"},
+			absent: []string{"math"},
+		},
+		{
+			name:   "escaped dollar",
+			src:    `a \$x$ b`,
+			absent: []string{"math"},
+		},
+		{
+			name: "dollar in code span",
+			src:  "`$x$` and $y$",
+			want: []string{"<code>$x$</code>", `<span class="math math-inline">y</span>`},
+		},
+		{
+			name:   "dollar in fenced code",
+			src:    "```\n$a$ and $$b$$\n```\n\n$c$",
+			want:   []string{"<pre><code>$a$ and $$b$$\n</code></pre>", `<span class="math math-inline">c</span>`},
+			absent: []string{`math-inline">a`, `math-display">b`},
+		},
+		{
+			name:   "tilde fence",
+			src:    "~~~\n$a$\n~~~",
+			want:   []string{"$a$"},
+			absent: []string{"math"},
+		},
+		{
+			name: "display block on its own lines",
+			src:  "$$\nx_1 + y^2\n$$",
+			want: []string{`<div class="math math-display">x_1 + y^2</div>`},
+		},
+		{
+			name:   "display inline with text stays a span",
+			src:    "text $$a*b$$ more",
+			want:   []string{`<p>text <span class="math math-display">a*b</span> more</p>`},
+			absent: []string{"<em>", "<div"},
+		},
+		{
+			name: "latex delimiters",
+			src:  `see \(a_b\) and \[c\]`,
+			want: []string{`<span class="math math-inline">a_b</span>`, `<span class="math math-display">c</span>`},
+		},
+		{
+			name:   "tex is escaped",
+			src:    "$a<b$ and $</span><script>x</script>$",
+			want:   []string{`<span class="math math-inline">a&lt;b</span>`},
+			absent: []string{"<script"},
+		},
+		{
+			name:   "unclosed display is text",
+			src:    "$$\nx + y",
+			absent: []string{"math"},
+		},
+		{
+			name: "tables, code classes, headings, task lists",
+			src:  "# Title\n\n| a | b |\n|---|:-:|\n| 1 | 2 |\n\n```go\nfmt.Println()\n```\n\n- [x] done",
+			want: []string{
+				`<h1 id="title">`, "<table>", `<td align="center">2</td>`,
+				`<code class="language-go">`, `type="checkbox"`,
+			},
+		},
+		{
+			name:   "foreign classes stripped",
+			src:    "```go onclick\nx\n```",
+			absent: []string{"onclick="},
+		},
+	}
+	for _, tt := range tests {
+		got := Markdown(tt.src)
+		for _, w := range tt.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: output lacks %q:\n%s", tt.name, w, got)
+			}
+		}
+		for _, a := range tt.absent {
+			if strings.Contains(got, a) {
+				t.Errorf("%s: output contains %q:\n%s", tt.name, a, got)
+			}
+		}
+	}
+}
+
+func TestMarkdownEmpty(t *testing.T) {
+	if got := Markdown("  \n "); got != "" {
+		t.Errorf("Markdown(blank) = %q, want empty", got)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`<span class="math math-inline">x</span>`, `<span class="math math-inline">x</span>`},
+		{`<span class="evil">x</span>`, `<span>x</span>`},
+		{`<div class="math math-display" onclick="x">y</div>`, `<div class="math math-display">y</div>`},
+		{`<code class="language-c++">z</code>`, `<code class="language-c++">z</code>`},
+		{`<a href="javascript:alert(1)">x</a>`, `x`},
+		{`<iframe src="https://x"></iframe>`, ``},
+	}
+	for _, tt := range tests {
+		if got := policy.Sanitize(tt.in); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Math is lifted out of the source before Markdown parsing, so `_` and `*`
+// in TeX aren't read as emphasis, and swapped back in afterwards as
+//
+//	<span class="math math-inline">…</span>   for $…$ and \(…\)
+//	<div class="math math-display">…</div>    for $$…$$ and \[…\]
+//
+// with the TeX HTML-escaped. A display formula inside running text gets a
+// span with the display class instead. Code spans and fenced blocks are
+// left alone.
+
+type mathSpan struct {
+	tex     string
+	display bool
+}
+
+type mathTable []mathSpan
+
+// Placeholders are plain letters and digits so Markdown leaves them be.
+func placeholder(i int) string { return fmt.Sprintf("MATHxPH%dxEND", i) }
+
+func (mt mathTable) restore(html string) string {
+	for i := len(mt) - 1; i >= 0; i-- {
+		m, ph := mt[i], placeholder(i)
+		if m.display {
+			// A display block on its own line replaces its paragraph; one
+			// inside running text stays a span so the HTML nests validly.
+			html = strings.ReplaceAll(html, "<p>"+ph+"</p>", `<div class="math math-display">`+htmlEscape(m.tex)+`</div>`)
+			html = strings.ReplaceAll(html, ph, `<span class="math math-display">`+htmlEscape(m.tex)+`</span>`)
+		} else {
+			html = strings.ReplaceAll(html, ph, `<span class="math math-inline">`+htmlEscape(m.tex)+`</span>`)
+		}
+	}
+	return html
+}
+
+// extractMath replaces math with placeholders, skipping code.
+func extractMath(src string) (string, mathTable) {
+	var (
+		out   strings.Builder
+		table mathTable
+		fence string // open code fence marker, if any
+	)
+	add := func(tex string, display bool) string {
+		table = append(table, mathSpan{tex: strings.TrimSpace(tex), display: display})
+		return placeholder(len(table) - 1)
+	}
+
+	lines := strings.SplitAfter(src, "\n")
+	for li := 0; li < len(lines); li++ {
+		line := lines[li]
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			out.WriteString(line)
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			out.WriteString(line)
+			continue
+		}
+
+		// Multi-line display math: a line opening $$ or \[ without closing it.
+		if open, close := displayOpen(trimmed); open != "" {
+			rest := strings.TrimPrefix(trimmed, open)
+			if !strings.Contains(rest, close) {
+				if end := findClose(lines[li+1:], close); end >= 0 {
+					var tex strings.Builder
+					tex.WriteString(rest + "\n")
+					for _, l := range lines[li+1 : li+1+end] {
+						tex.WriteString(l)
+					}
+					last := lines[li+1+end]
+					idx := strings.Index(last, close)
+					tex.WriteString(last[:idx])
+					out.WriteString(add(tex.String(), true))
+					out.WriteString(last[idx+len(close):])
+					li += 1 + end
+					continue
+				}
+			}
+		}
+
+		out.WriteString(inlineMath(line, add))
+	}
+	return out.String(), table
+}
+
+func displayOpen(trimmed string) (open, close string) {
+	switch {
+	case strings.HasPrefix(trimmed, "$$"):
+		return "$$", "$$"
+	case strings.HasPrefix(trimmed, `\[`):
+		return `\[`, `\]`
+	}
+	return "", ""
+}
+
+func findClose(lines []string, close string) int {
+	for i, l := range lines {
+		if strings.Contains(l, close) {
+			return i
+		}
+	}
+	return -1
+}
+
+// inlineMath handles math within one line, skipping `code` spans.
+func inlineMath(line string, add func(string, bool) string) string {
+	var out strings.Builder
+	emit := func(s string) { out.WriteString(s) }
+
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '`':
+			// Copy the code span verbatim.
+			n := countRun(line[i:], '`')
+			if end := strings.Index(line[i+n:], strings.Repeat("`", n)); end >= 0 {
+				emit(line[i : i+n+end+n])
+				i += n + end + n
+				continue
+			}
+			emit(line[i : i+n])
+			i += n
+			continue
+		case c == '\\' && i+1 < len(line):
+			switch line[i+1] {
+			case '$':
+				emit(`\$`)
+				i += 2
+				continue
+			case '(', '[':
+				close := `\)`
+				display := line[i+1] == '['
+				if display {
+					close = `\]`
+				}
+				if end := strings.Index(line[i+2:], close); end >= 0 {
+					emit(add(line[i+2:i+2+end], display))
+					i += 2 + end + 2
+					continue
+				}
+			}
+			emit(line[i : i+2])
+			i += 2
+			continue
+		case c == '$':
+			if strings.HasPrefix(line[i:], "$$") {
+				if end := strings.Index(line[i+2:], "$$"); end > 0 {
+					emit(add(line[i+2:i+2+end], true))
+					i += 2 + end + 2
+					continue
+				}
+				emit("$$")
+				i += 2
+				continue
+			}
+			if end := inlineDollarEnd(line, i); end > 0 {
+				emit(add(line[i+1:end], false))
+				i = end + 1
+				continue
+			}
+		}
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+// inlineDollarEnd finds the closing $ for an opening $ at start, using the
+// pandoc rule so prices don't turn into math: the opener is followed by a
+// non-space, the closer preceded by a non-space and not followed by a digit.
+func inlineDollarEnd(line string, start int) int {
+	if start+1 >= len(line) || line[start+1] == ' ' || line[start+1] == '\t' {
+		return -1
+	}
+	for j := start + 1; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case '\n':
+			return -1
+		case '$':
+			if line[j-1] == ' ' || line[j-1] == '\t' {
+				continue
+			}
+			if j+1 < len(line) && line[j+1] >= '0' && line[j+1] <= '9' {
+				continue
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
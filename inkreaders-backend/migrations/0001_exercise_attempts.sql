@@ -0,0 +1,15 @@
+-- 0001: attempts are recorded against exercise_sets (not the legacy exercises
+-- table) and keep the per-question grading result next to the raw answers.
+
+ALTER TABLE exercise_attempts DROP CONSTRAINT IF EXISTS exercise_attempts_exercise_id_fkey;
+ALTER TABLE exercise_attempts
+    ADD CONSTRAINT exercise_attempts_exercise_id_fkey
+    FOREIGN KEY (exercise_id) REFERENCES exercise_sets(id) ON DELETE CASCADE;
+
+ALTER TABLE exercise_attempts
+    ADD COLUMN IF NOT EXISTS results jsonb DEFAULT '[]'::jsonb NOT NULL,
+    ADD COLUMN IF NOT EXISTS correct integer DEFAULT 0 NOT NULL,
+    ADD COLUMN IF NOT EXISTS total integer DEFAULT 0 NOT NULL;
+
+CREATE INDEX IF NOT EXISTS exercise_attempts_exercise_user_idx
+    ON exercise_attempts USING btree (exercise_id, user_id, created_at DESC);
@@ -0,0 +1,7 @@
+-- 0002: cursor rows for the indexer's streaming modes. An empty value means
+-- "start from live".
+
+INSERT INTO cursors (name, value) VALUES
+    ('stream:jetstream', ''),
+    ('stream:firehose', '')
+ON CONFLICT (name) DO NOTHING;
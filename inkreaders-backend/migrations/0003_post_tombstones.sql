@@ -0,0 +1,15 @@
+-- 0003: edits and deletions of indexed records. A post's revision goes up each
+-- time its CID changes; deleted records keep their row with deleted_at set so
+-- a late replay of the same CID can't resurrect them. A new CID at the same
+-- URI is a fresh record and clears deleted_at.
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS revision integer DEFAULT 1 NOT NULL,
+    ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS posts_live_collection_created_idx
+    ON posts USING btree (collection, created_at DESC) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS posts_did_collection_idx
+    ON posts USING btree (did, collection);
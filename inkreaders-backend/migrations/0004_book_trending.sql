@@ -0,0 +1,45 @@
+-- 0004: trending-books rollup. One row per (span, book) with a precomputed
+-- score, so /api/discover/trending-books reads a handful of indexed rows
+-- instead of aggregating posts. Refreshed periodically by the indexer
+-- (REFRESH MATERIALIZED VIEW CONCURRENTLY book_trending).
+--
+-- Each post counts 0.5^(age / half_life), nudged up or down by its rating
+-- (0-5, centred on 2.5) and up for a progress update; the decayed sum is then
+-- multiplied by 1 + ln(distinct reviewers) so one account posting repeatedly
+-- can't carry a book on its own.
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS book_trending AS
+WITH spans(span, len, half_life) AS (
+    VALUES ('24h', interval '24 hours', interval '6 hours'),
+           ('7d',  interval '7 days',   interval '42 hours'),
+           ('30d', interval '30 days',  interval '180 hours')
+),
+scored AS (
+    SELECT s.span, p.book_id, p.did, p.rating, p.progress,
+           power(0.5, extract(epoch FROM now() - p.created_at) / extract(epoch FROM s.half_life)) AS decay
+    FROM posts p
+    JOIN spans s ON p.created_at >= now() - s.len
+    WHERE p.collection = 'com.inkreaders.book.post'
+      AND p.deleted_at IS NULL
+      AND p.book_id IS NOT NULL
+)
+SELECT span,
+       book_id,
+       count(*)                    AS posts,
+       count(DISTINCT did)         AS reviewers,
+       avg(rating)::real           AS avg_rating,
+       count(progress)             AS progress_updates,
+       (sum(decay * (1
+                     + coalesce((rating - 2.5) / 5.0, 0)
+                     + CASE WHEN progress IS NOT NULL THEN 0.25 ELSE 0 END))
+        * (1 + ln(count(DISTINCT did))))::double precision AS score,
+       now()                       AS computed_at
+FROM scored
+GROUP BY span, book_id
+WITH DATA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS book_trending_span_book_idx
+    ON book_trending USING btree (span, book_id);
+
+CREATE INDEX IF NOT EXISTS book_trending_span_score_idx
+    ON book_trending USING btree (span, score DESC);
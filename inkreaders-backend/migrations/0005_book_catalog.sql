@@ -0,0 +1,16 @@
+-- 0005: match keys for the book catalog. title_key/authors_key are filled by
+-- UpsertBook and backfilled by `go run ./cmd/books-dedupe`, which also merges
+-- rows that turn out to be the same book.
+
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS title_key text,
+    ADD COLUMN IF NOT EXISTS authors_key text;
+
+CREATE INDEX IF NOT EXISTS books_title_authors_key_idx
+    ON books USING btree (title_key, authors_key);
+
+CREATE INDEX IF NOT EXISTS books_isbn10_idx
+    ON books USING btree (isbn10);
+
+CREATE INDEX IF NOT EXISTS posts_book_id_idx
+    ON posts USING btree (book_id);
@@ -0,0 +1,15 @@
+-- 0006: metadata filled in by the book.enrich job from Open Library.
+-- enriched_at is set even when the source had nothing, so misses are only
+-- retried after a while (see BooksToEnrich).
+
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS cover_url text,
+    ADD COLUMN IF NOT EXISTS publisher text,
+    ADD COLUMN IF NOT EXISTS page_count integer,
+    ADD COLUMN IF NOT EXISTS subjects text[] DEFAULT '{}'::text[] NOT NULL,
+    ADD COLUMN IF NOT EXISTS publish_year integer,
+    ADD COLUMN IF NOT EXISTS enrich_source text,
+    ADD COLUMN IF NOT EXISTS enriched_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS books_enriched_at_idx
+    ON books USING btree (enriched_at NULLS FIRST) WHERE isbn13 IS NOT NULL;
@@ -0,0 +1,18 @@
+-- 0007: reading shelves. Rows get a stable id for the API, a link to the
+-- catalog, the reading dates the status transitions set, and the rating and
+-- post published when a book is finished.
+
+ALTER TABLE shelves
+    ADD COLUMN IF NOT EXISTS id uuid DEFAULT gen_random_uuid() NOT NULL,
+    ADD COLUMN IF NOT EXISTS book_id bigint REFERENCES books(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS started_at timestamp with time zone,
+    ADD COLUMN IF NOT EXISTS finished_at timestamp with time zone,
+    ADD COLUMN IF NOT EXISTS rating real,
+    ADD COLUMN IF NOT EXISTS post_uri text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS shelves_id_key ON shelves USING btree (id);
+
+CREATE INDEX IF NOT EXISTS shelves_user_status_updated_idx
+    ON shelves USING btree (user_id, status, updated_at DESC);
+
+CREATE INDEX IF NOT EXISTS shelves_book_id_idx ON shelves USING btree (book_id);
@@ -0,0 +1,34 @@
+-- 0008: reading progress log and reading goals. Each progress row is a
+-- snapshot (page and/or percent) at read_at; pages read are the deltas
+-- between consecutive snapshots of the same book.
+
+CREATE TABLE IF NOT EXISTS reading_progress (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    user_id uuid NOT NULL,
+    shelf_id uuid REFERENCES shelves(id) ON DELETE SET NULL,
+    book_id bigint REFERENCES books(id) ON DELETE SET NULL,
+    page integer CHECK (page >= 0),
+    percent real CHECK (percent >= 0 AND percent <= 100),
+    session_seconds integer CHECK (session_seconds >= 0),
+    note text,
+    read_at timestamp with time zone DEFAULT now() NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT reading_progress_page_or_percent CHECK (page IS NOT NULL OR percent IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS reading_progress_user_read_at_idx
+    ON reading_progress USING btree (user_id, read_at DESC);
+
+CREATE INDEX IF NOT EXISTS reading_progress_shelf_idx
+    ON reading_progress USING btree (shelf_id, read_at);
+
+CREATE TABLE IF NOT EXISTS reading_goals (
+    user_id uuid NOT NULL,
+    period text NOT NULL CHECK (period IN ('year', 'month')),
+    period_start date NOT NULL,
+    target_books integer CHECK (target_books >= 0),
+    target_pages integer CHECK (target_pages >= 0),
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (user_id, period, period_start)
+);
@@ -0,0 +1,32 @@
+-- 0009: book-club events. events.created_by holds the creator's user id
+-- (as text, matching the existing column). An event can point at a catalog
+-- book or a notebook topic, and remembers the feed post that announced it.
+
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS description text,
+    ADD COLUMN IF NOT EXISTS book_id bigint REFERENCES books(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS topic_id uuid REFERENCES topics(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS announce_uri text,
+    ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone DEFAULT now() NOT NULL;
+
+CREATE INDEX IF NOT EXISTS events_starts_at_idx ON events USING btree (starts_at);
+CREATE INDEX IF NOT EXISTS events_created_by_idx ON events USING btree (created_by);
+
+CREATE TABLE IF NOT EXISTS event_rsvps (
+    event_id uuid NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL,
+    status text NOT NULL CHECK (status IN ('going', 'maybe', 'declined')),
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (event_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS event_rsvps_user_idx ON event_rsvps USING btree (user_id);
+
+-- Secret per-user token for the subscribable .ics feed (calendar apps can't
+-- send the session cookie).
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id uuid PRIMARY KEY,
+    token text NOT NULL UNIQUE,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
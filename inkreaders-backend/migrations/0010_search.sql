@@ -0,0 +1,63 @@
+-- 0010: full-text search. The tsvector triggers existed only in the original
+-- dump; (re)create them here so fresh databases get them too, add tags to the
+-- topic vector, give highlights their own vector, and backfill rows that were
+-- written before the triggers.
+
+CREATE OR REPLACE FUNCTION update_topics_search_vector() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('english', coalesce(NEW.title,'')), 'A') ||
+    setweight(to_tsvector('english', coalesce(NEW.description,'')), 'B') ||
+    setweight(to_tsvector('english', coalesce(NEW.meta->>'summary','')), 'C') ||
+    setweight(to_tsvector('english', coalesce(
+      (SELECT string_agg(t, ' ') FROM jsonb_array_elements_text(
+        CASE WHEN jsonb_typeof(NEW.tags) = 'array' THEN NEW.tags ELSE '[]'::jsonb END) t), '')), 'C');
+  RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION update_responses_search_vector() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  NEW.search_vector := setweight(to_tsvector('english', coalesce(NEW.content, '')), 'B');
+  RETURN NEW;
+END;
+$$;
+
+ALTER TABLE highlights ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION update_highlights_search_vector() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('english', coalesce(NEW.excerpt, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(NEW.note, '')), 'A');
+  RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_topics_search_vector ON topics;
+CREATE TRIGGER trg_topics_search_vector BEFORE INSERT OR UPDATE ON topics
+    FOR EACH ROW EXECUTE FUNCTION update_topics_search_vector();
+
+DROP TRIGGER IF EXISTS trg_responses_search_vector ON responses;
+CREATE TRIGGER trg_responses_search_vector BEFORE INSERT OR UPDATE OF content ON responses
+    FOR EACH ROW EXECUTE FUNCTION update_responses_search_vector();
+
+DROP TRIGGER IF EXISTS trg_highlights_search_vector ON highlights;
+CREATE TRIGGER trg_highlights_search_vector BEFORE INSERT OR UPDATE OF excerpt, note ON highlights
+    FOR EACH ROW EXECUTE FUNCTION update_highlights_search_vector();
+
+CREATE INDEX IF NOT EXISTS idx_topics_search_gin ON topics USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_responses_search_gin ON responses USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_highlights_search_gin ON highlights USING gin (search_vector);
+
+-- Backfill: the no-op updates fire the triggers above. Topics are always
+-- refreshed since their vector now includes tags.
+UPDATE topics SET title = title;
+UPDATE responses SET content = content WHERE search_vector IS NULL;
+UPDATE highlights SET excerpt = excerpt WHERE search_vector IS NULL;
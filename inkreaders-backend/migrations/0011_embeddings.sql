@@ -0,0 +1,33 @@
+-- 0011: response embeddings for semantic search. embedding_model records
+-- which embedder produced the vector; editing the content clears it so the
+-- embed job picks the row up again.
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+ALTER TABLE responses
+    ADD COLUMN IF NOT EXISTS embedding_model text,
+    ADD COLUMN IF NOT EXISTS embedded_at timestamp with time zone;
+
+CREATE OR REPLACE FUNCTION clear_response_embedding() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  IF NEW.content IS DISTINCT FROM OLD.content THEN
+    NEW.embedding := NULL;
+    NEW.embedding_model := NULL;
+    NEW.embedded_at := NULL;
+  END IF;
+  RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_clear_response_embedding ON responses;
+CREATE TRIGGER trg_clear_response_embedding BEFORE UPDATE OF content ON responses
+    FOR EACH ROW EXECUTE FUNCTION clear_response_embedding();
+
+CREATE INDEX IF NOT EXISTS idx_responses_embedding_hnsw
+    ON responses USING hnsw (embedding vector_cosine_ops);
+
+-- Rows still needing an embedding.
+CREATE INDEX IF NOT EXISTS idx_responses_unembedded
+    ON responses USING btree (updated_at) WHERE embedding IS NULL;
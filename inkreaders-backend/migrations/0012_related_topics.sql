@@ -0,0 +1,13 @@
+-- 0012: cached "related topics" per (topic, viewer). A row is reused while
+-- the topic's source response (the canonical one, else the first) is the
+-- same row at the same updated_at.
+
+CREATE TABLE IF NOT EXISTS topic_related_cache (
+    topic_id uuid NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    viewer_id uuid NOT NULL,
+    source_response_id uuid,
+    source_updated_at timestamp with time zone,
+    result jsonb NOT NULL,
+    computed_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (topic_id, viewer_id)
+);
@@ -0,0 +1,22 @@
+-- 0013: content_html is now rendered from content on every write, so a
+-- change to content_html alone (e.g. the render-responses backfill) is not
+-- a new version.
+
+CREATE OR REPLACE FUNCTION create_response_version_trigger() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+  ver integer;
+BEGIN
+  -- only create a version when content or raw changes:
+  IF (OLD.content IS NOT DISTINCT FROM NEW.content AND
+      OLD.raw IS NOT DISTINCT FROM NEW.raw) THEN
+    RETURN NEW; -- nothing changed relevant, skip
+  END IF;
+
+  ver := next_response_version_num(OLD.id);
+  INSERT INTO response_versions(response_id, version_number, content, content_html, raw, created_at)
+    VALUES (OLD.id, ver, OLD.content, OLD.content_html, OLD.raw, now());
+  RETURN NEW;
+END;
+$$;
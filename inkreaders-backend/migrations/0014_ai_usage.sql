@@ -0,0 +1,17 @@
+-- 0014: metered model calls. One row per call with its token counts and
+-- cost; user_id is null for calls nobody is billed for. Quotas sum the
+-- user's tokens over the current UTC day and month.
+
+CREATE TABLE IF NOT EXISTS ai_usage (
+    id bigserial PRIMARY KEY,
+    user_id uuid,
+    op text NOT NULL,
+    model text NOT NULL,
+    prompt_tokens integer DEFAULT 0 NOT NULL,
+    completion_tokens integer DEFAULT 0 NOT NULL,
+    cost_usd numeric(12,6) DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ai_usage_user_created_idx ON ai_usage USING btree (user_id, created_at);
+CREATE INDEX IF NOT EXISTS ai_usage_created_idx ON ai_usage USING btree (created_at);
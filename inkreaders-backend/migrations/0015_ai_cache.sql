@@ -0,0 +1,14 @@
+-- 0015: cached results of deterministic AI calls (exercise generation,
+-- remix, explanations), keyed by a SHA-256 of the operation, model, system
+-- prompt and input. Expired rows are ignored on read and purged by the
+-- server.
+
+CREATE TABLE IF NOT EXISTS ai_cache (
+    key text PRIMARY KEY,
+    op text NOT NULL,
+    value jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ai_cache_expires_at_idx ON ai_cache USING btree (expires_at);
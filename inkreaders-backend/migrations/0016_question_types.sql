@@ -0,0 +1,9 @@
+-- 0016: exercise sets may now be made of multi_select, ordering, matching,
+-- short_answer and numeric questions as well.
+
+ALTER TABLE exercise_sets DROP CONSTRAINT IF EXISTS exercise_sets_format_check;
+ALTER TABLE exercise_sets
+    ADD CONSTRAINT exercise_sets_format_check CHECK (format = ANY (ARRAY[
+        'mcq', 'fill_blank', 'true_false', 'multi_select', 'ordering',
+        'matching', 'short_answer', 'numeric', 'mixed'
+    ]));
@@ -0,0 +1,6 @@
+-- 0017: a shelf item being published is claimed first, so two concurrent
+-- publish requests can't both post. The claim is the time it was taken;
+-- an old one is treated as abandoned.
+
+ALTER TABLE shelves
+    ADD COLUMN IF NOT EXISTS publishing_at timestamp with time zone;